@@ -0,0 +1,524 @@
+package dns
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheSize is the number of entries a Cache holds if no size
+	// is given.
+	DefaultCacheSize = 10000
+
+	// DefaultMaxTTL caps the time records stay in a Cache.
+	DefaultMaxTTL = 24 * time.Hour
+
+	// DefaultMaxNegativeTTL caps the time negative answers stay in a
+	// Cache. RFC 2308 section 5 recommends one to three hours.
+	DefaultMaxNegativeTTL = 3 * time.Hour
+
+	// DefaultStaleTTL is the TTL of expired records served by a Cache
+	// (RFC 8767 section 4).
+	DefaultStaleTTL = 30 * time.Second
+
+	// cacheShards is the number of independently locked parts of a Cache.
+	cacheShards = 32
+
+	// prefetchDivisor defines the end of the lifetime of an entry in which
+	// popular entries are refreshed: the last tenth of the TTL.
+	prefetchDivisor = 10
+
+	// refreshTimeout limits background refreshes.
+	refreshTimeout = 10 * time.Second
+)
+
+// Cache answers queries from the responses of an Exchanger. Answer
+// records are stored as RRsets keyed by owner name, type and class, so
+// the parts of a CNAME chain are shared between queries. Negative answers
+// are cached as described in RFC 2308: NXDOMAIN for the name, NODATA for
+// the name and type, both for the TTL given by the SOA record in the
+// authority section. Responses without SOA are not cached negatively.
+//
+// The TTLs of served records count down from the time they were stored.
+// With MaxStale set, expired entries are served with StaleTTL while a
+// background query refreshes them (RFC 8767), and with PrefetchHits set,
+// popular entries are refreshed shortly before they expire. A Cache is
+// safe for concurrent use; entries are spread over shards with their own
+// lock and least recently used entries are evicted first.
+//
+// A zero Cache with an Exchanger is ready to use and holds
+// DefaultCacheSize entries; NewCache sets up a Cache of another size.
+type Cache struct {
+	// Exchanger is asked on cache misses.
+	Exchanger Exchanger
+
+	// MaxTTL caps the TTL of cached records. It defaults to
+	// DefaultMaxTTL.
+	MaxTTL time.Duration
+
+	// MaxNegativeTTL caps the TTL of cached negative answers. It defaults
+	// to DefaultMaxNegativeTTL.
+	MaxNegativeTTL time.Duration
+
+	// MaxStale is how long entries are served after they expired. Zero
+	// disables serving stale data.
+	MaxStale time.Duration
+
+	// StaleTTL is the TTL of stale records in responses. It defaults to
+	// DefaultStaleTTL.
+	StaleTTL time.Duration
+
+	// PrefetchHits is the number of hits after which an entry is refreshed
+	// when it is served in the last tenth of its TTL. Zero disables
+	// prefetching.
+	PrefetchHits int
+
+	// refreshing holds the questions that are refreshed in the
+	// background.
+	refreshing sync.Map
+	refreshes  sync.WaitGroup
+
+	// once sets up the shards on first use with size entries.
+	once   sync.Once
+	size   int
+	seed   maphash.Seed
+	shards [cacheShards]cacheShard
+
+	// now returns the current time and is replaced by tests.
+	now func() time.Time
+}
+
+// cacheKey identifies an RRset or a negative answer. The name is in
+// canonical form. NXDOMAIN entries use type 0, since they apply to every
+// type of the name.
+type cacheKey struct {
+	name  DNSName
+	typ   Type
+	class Class
+}
+
+// cacheEntry is an RRset or a negative answer.
+type cacheEntry struct {
+	key cacheKey
+
+	// records is the RRset or, for negative answers, the SOA record of
+	// the authority section.
+	records  []*ResourceRecord
+	negative bool
+
+	stored  time.Time
+	expires time.Time
+
+	// hits counts the lookups that used the entry.
+	hits int
+
+	elem *list.Element
+}
+
+// cacheShard is a part of a Cache with its own lock and LRU list.
+type cacheShard struct {
+	mu         sync.Mutex
+	entries    map[cacheKey]*cacheEntry
+	lru        list.List
+	maxEntries int
+}
+
+// NewCache returns a Cache in front of ex that holds about size entries.
+// DefaultCacheSize is used if size is not positive.
+func NewCache(ex Exchanger, size int) *Cache {
+	c := &Cache{Exchanger: ex, size: size}
+	c.init()
+	return c
+}
+
+// init sets up the shards, so that a zero Cache holds DefaultCacheSize
+// entries.
+func (c *Cache) init() {
+	c.once.Do(func() {
+		size := c.size
+		if size <= 0 {
+			size = DefaultCacheSize
+		}
+		if c.now == nil {
+			c.now = time.Now
+		}
+		c.seed = maphash.MakeSeed()
+		perShard := max((size+cacheShards-1)/cacheShards, 1)
+		for i := range c.shards {
+			c.shards[i].entries = make(map[cacheKey]*cacheEntry)
+			c.shards[i].maxEntries = perShard
+		}
+	})
+}
+
+func (c *Cache) shard(key cacheKey) *cacheShard {
+	c.init()
+	var h maphash.Hash
+	h.SetSeed(c.seed)
+	h.WriteString(string(key.name))
+	h.WriteByte(byte(key.typ >> 8))
+	h.WriteByte(byte(key.typ))
+	return &c.shards[h.Sum64()%cacheShards]
+}
+
+// Len returns the number of cached RRsets and negative answers, including
+// expired ones that were not removed yet.
+func (c *Cache) Len() int {
+	c.init()
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += len(s.entries)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Flush removes all entries.
+func (c *Cache) Flush() {
+	c.init()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		clear(s.entries)
+		s.lru.Init()
+		s.mu.Unlock()
+	}
+}
+
+// Exchange answers q from the cache or, on a miss, with the Exchanger and
+// stores the response. Stale and prefetched entries are refreshed in the
+// background.
+func (c *Cache) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	if resp, refresh := c.lookup(q, c.MaxStale, false); resp != nil {
+		if refresh {
+			c.refresh(q)
+		}
+		return resp, nil
+	}
+
+	resp, err := c.Exchanger.Exchange(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	c.Insert(resp)
+	return resp, nil
+}
+
+// refresh queries q in the background unless it is already being
+// refreshed. Failed refreshes keep the old entries.
+func (c *Cache) refresh(q *Message) {
+	question := q.Question[0]
+	key := cacheKey{name: question.Name.Canonical(), typ: question.Type, class: question.Class}
+	if _, busy := c.refreshing.LoadOrStore(key, true); busy {
+		return
+	}
+
+	hdr := *q.Header
+	rq := &Message{Header: &hdr, Question: q.Question}
+	if opt := q.OPT(); opt != nil {
+		rq.Additional = []*ResourceRecord{opt}
+	}
+
+	c.refreshes.Add(1)
+	go func() {
+		defer c.refreshes.Done()
+		defer c.refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		if resp, err := c.Exchanger.Exchange(ctx, rq); err == nil {
+			c.Insert(resp)
+		}
+	}()
+}
+
+// get returns the entry for key if it has not expired more than maxStale
+// ago. refresh reports whether the entry is stale or due for a prefetch.
+// With peek, expired entries are skipped instead of removed and the entry
+// is not counted as used.
+func (c *Cache) get(key cacheKey, now time.Time, maxStale time.Duration, peek bool) (e *cacheEntry, refresh bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e = s.entries[key]
+	if e == nil {
+		return nil, false
+	}
+	if peek {
+		if !now.Before(e.expires) {
+			return nil, false
+		}
+		return e, false
+	}
+	if !now.Before(e.expires.Add(maxStale)) {
+		s.remove(e)
+		return nil, false
+	}
+	s.lru.MoveToFront(e.elem)
+	e.hits++
+
+	if !now.Before(e.expires) {
+		return e, true
+	}
+	lifetime := e.expires.Sub(e.stored)
+	prefetch := c.PrefetchHits > 0 && e.hits >= c.PrefetchHits &&
+		e.expires.Sub(now) < lifetime/prefetchDivisor
+	return e, prefetch
+}
+
+func (c *Cache) put(e *cacheEntry) {
+	if !e.expires.After(e.stored) {
+		// Records with TTL 0 must not be cached.
+		return
+	}
+
+	s := c.shard(e.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old := s.entries[e.key]; old != nil {
+		s.remove(old)
+	}
+	e.elem = s.lru.PushFront(e)
+	s.entries[e.key] = e
+	for len(s.entries) > s.maxEntries {
+		s.remove(s.lru.Back().Value.(*cacheEntry))
+	}
+}
+
+func (c *Cache) delete(key cacheKey) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.entries[key]; e != nil {
+		s.remove(e)
+	}
+}
+
+func (s *cacheShard) remove(e *cacheEntry) {
+	s.lru.Remove(e.elem)
+	delete(s.entries, e.key)
+}
+
+// Lookup answers q from the cache. It reports false if the cache does not
+// hold everything needed for a complete answer. Expired entries are not
+// used but kept for Exchange to serve stale, and the entries used do not
+// count as hits for prefetching.
+func (c *Cache) Lookup(q *Message) (*Message, bool) {
+	resp, _ := c.lookup(q, 0, true)
+	return resp, resp != nil
+}
+
+// lookup answers q from entries that expired less than maxStale ago.
+// refresh reports whether an entry of the answer should be refreshed. With
+// peek, the entries are only read (see get).
+func (c *Cache) lookup(q *Message, maxStale time.Duration, peek bool) (resp *Message, refresh bool) {
+	if len(q.Question) != 1 || q.Header.Opcode() != OpcodeQuery {
+		return nil, false
+	}
+	c.init()
+	question := q.Question[0]
+	now := c.now()
+
+	var answer []*ResourceRecord
+	name := question.Name
+	for hops := 0; hops <= maxCNAMEChain; hops++ {
+		key := cacheKey{name: name.Canonical(), class: question.Class}
+		if e, r := c.get(key, now, maxStale, peek); e != nil {
+			return c.response(q, RCodeNameError, answer, c.served(e, now)), refresh || r
+		}
+
+		key.typ = question.Type
+		if e, r := c.get(key, now, maxStale, peek); e != nil {
+			refresh = refresh || r
+			if e.negative {
+				return c.response(q, RCodeNoError, answer, c.served(e, now)), refresh
+			}
+			return c.response(q, RCodeNoError, append(answer, c.served(e, now)...), nil), refresh
+		}
+
+		if question.Type == TypeCNAME {
+			return nil, false
+		}
+		key.typ = TypeCNAME
+		e, r := c.get(key, now, maxStale, peek)
+		if e == nil {
+			return nil, false
+		}
+		refresh = refresh || r
+		answer = append(answer, c.served(e, now)...)
+		data, err := e.records[0].Rdata()
+		if err != nil {
+			return nil, false
+		}
+		name = data.(*CNAME).Target
+	}
+	return nil, false
+}
+
+// served returns copies of the records of e with the remaining TTL, or
+// StaleTTL if e has expired.
+func (c *Cache) served(e *cacheEntry, now time.Time) []*ResourceRecord {
+	ttl := uint32(e.expires.Sub(now) / time.Second)
+	if !now.Before(e.expires) {
+		stale := c.StaleTTL
+		if stale <= 0 {
+			stale = DefaultStaleTTL
+		}
+		ttl = uint32(stale / time.Second)
+	}
+	records := make([]*ResourceRecord, len(e.records))
+	for i, rr := range e.records {
+		cp := *rr
+		cp.TTL = ttl
+		cp.Data = append([]byte(nil), rr.Data...)
+		records[i] = &cp
+	}
+	return records
+}
+
+// response builds the answer to q from cached records.
+func (c *Cache) response(q *Message, rcode Rcode, answer, authority []*ResourceRecord) *Message {
+	resp := errorResponse(q, rcode)
+	resp.Header.SetRecursionAvailable(true)
+	resp.Answer = answer
+	resp.Authority = authority
+	if opt := q.OPT(); opt != nil {
+		resp.SetEDNS0(DefaultEDNSUDPSize, opt.TTL&flagDNSSECOK != 0)
+	}
+	return resp
+}
+
+// Insert stores the answer records of resp and, for NXDOMAIN and NODATA
+// responses, the negative answer. Only the records of the CNAME chain
+// starting at the query name are stored, other answer records are
+// unsolicited. Truncated responses and responses with other response
+// codes are ignored.
+func (c *Cache) Insert(resp *Message) {
+	if !resp.Header.IsResponse() || resp.Header.IsTruncated() || len(resp.Question) != 1 {
+		return
+	}
+	rcode := resp.Header.ResponseCode()
+	if rcode != RCodeNoError && rcode != RCodeNameError {
+		return
+	}
+	c.init()
+	question := resp.Question[0]
+	now := c.now()
+
+	chain := cnameChain(resp.Answer, question.Name, question.Type)
+	for _, set := range rrsets(resp.Answer) {
+		if set[0].Class != question.Class || !slices.ContainsFunc(chain, set[0].Name.Equal) {
+			continue
+		}
+		key := cacheKey{name: set[0].Name.Canonical(), class: set[0].Class}
+		// The name exists now.
+		c.delete(key)
+		key.typ = set[0].Type
+		c.put(&cacheEntry{
+			key:     key,
+			records: set,
+			stored:  now,
+			expires: now.Add(c.ttl(minTTL(set), c.MaxTTL, DefaultMaxTTL)),
+		})
+	}
+
+	// The negative answer applies to the end of the CNAME chain.
+	_, name, _ := followCNAMEs(resp.Answer, question.Name, question.Type, 0)
+	key := cacheKey{name: name.Canonical(), typ: question.Type, class: question.Class}
+	switch {
+	case rcode == RCodeNameError:
+		key.typ = 0
+	case hasRecords(resp.Answer, name, question.Type):
+		return
+	}
+
+	soa := negativeSOA(resp, name)
+	if soa == nil {
+		return
+	}
+	c.put(&cacheEntry{
+		key:      key,
+		records:  []*ResourceRecord{soa},
+		negative: true,
+		stored:   now,
+		expires:  now.Add(c.ttl(negativeTTL(soa), c.MaxNegativeTTL, DefaultMaxNegativeTTL)),
+	})
+}
+
+// ttl converts ttl to a duration capped by limit or its default.
+func (c *Cache) ttl(ttl uint32, limit, def time.Duration) time.Duration {
+	if limit <= 0 {
+		limit = def
+	}
+	return min(time.Duration(ttl)*time.Second, limit)
+}
+
+// rrsets groups records by owner name, type and class.
+func rrsets(records []*ResourceRecord) [][]*ResourceRecord {
+	var sets [][]*ResourceRecord
+	index := make(map[cacheKey]int)
+	for _, rr := range records {
+		if rr.Type == TypeOPT {
+			continue
+		}
+		key := cacheKey{name: rr.Name.Canonical(), typ: rr.Type, class: rr.Class}
+		i, ok := index[key]
+		if !ok {
+			i = len(sets)
+			index[key] = i
+			sets = append(sets, nil)
+		}
+		sets[i] = append(sets[i], rr)
+	}
+	return sets
+}
+
+// minTTL returns the smallest TTL of records. RFC 2181 section 5.2 treats
+// differing TTLs within an RRset as the lowest of them.
+func minTTL(records []*ResourceRecord) uint32 {
+	ttl := records[0].TTL
+	for _, rr := range records[1:] {
+		ttl = min(ttl, rr.TTL)
+	}
+	return ttl
+}
+
+func hasRecords(records []*ResourceRecord, name DNSName, t Type) bool {
+	for _, rr := range records {
+		if rr.Type == t && rr.Name.Equal(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// negativeSOA returns the SOA record of the authority section of a
+// negative response for name. The SOA has to be owned by an ancestor of
+// name.
+func negativeSOA(resp *Message, name DNSName) *ResourceRecord {
+	for _, rr := range resp.Authority {
+		if rr.Type == TypeSOA && name.IsSubdomainOf(rr.Name) {
+			return rr
+		}
+	}
+	return nil
+}
+
+// negativeTTL is the TTL of a negative answer: the minimum of the SOA TTL
+// and the SOA MINIMUM field (RFC 2308 section 5).
+func negativeTTL(soa *ResourceRecord) uint32 {
+	data, err := soa.Rdata()
+	if err != nil {
+		return 0
+	}
+	return min(soa.TTL, data.(*SOA).Minimum)
+}
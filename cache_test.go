@@ -0,0 +1,313 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingExchanger answers queries from a testZone and counts them.
+// Negative answers carry the SOA record of the zone.
+type countingExchanger struct {
+	zone  *testZone
+	soa   *ResourceRecord
+	count atomic.Int32
+}
+
+func (e *countingExchanger) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	e.count.Add(1)
+	resp := e.zone.handle(q, "test")
+	if e.soa != nil && len(followedRecords(resp, q)) == 0 {
+		resp.Authority = append(resp.Authority, e.soa)
+	}
+	return resp, nil
+}
+
+// followedRecords returns the records of the queried type in resp.
+func followedRecords(resp, q *Message) []*ResourceRecord {
+	records, _, _ := followCNAMEs(resp.Answer, q.Question[0].Name, q.Question[0].Type, 0)
+	return records
+}
+
+var testSOA = NewResourceRecord("noteip.de", ClassIN, 3600, &SOA{
+	MName: "ns.noteip.de", RName: "hostmaster.noteip.de",
+	Serial: 1, Refresh: 7200, Retry: 900, Expire: 86400, Minimum: 300,
+})
+
+// testClock is a settable clock for caches.
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestCache(size int) (*Cache, *countingExchanger, *testClock) {
+	ex := &countingExchanger{zone: testLookupZone, soa: testSOA}
+	clock := &testClock{t: time.Unix(1700000000, 0)}
+	c := NewCache(ex, size)
+	c.now = clock.now
+	return c, ex, clock
+}
+
+func cacheQuery(t *testing.T, c *Cache, name string, qtype Type) *Message {
+	t.Helper()
+	q, err := NewQuery(name, qtype, ClassIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCachePositive(t *testing.T) {
+	c, ex, clock := newTestCache(0)
+
+	cacheQuery(t, c, "www.noteip.de", TypeA)
+	clock.advance(25 * time.Second)
+	resp := cacheQuery(t, c, "www.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 1 {
+		t.Fatalf("Expected 1 upstream query but got %d", n)
+	}
+	if len(resp.Answer) != 2 || resp.Answer[0].Type != TypeCNAME || resp.Answer[1].TTL != 35 {
+		t.Fatalf("Unexpected cached answer %v", resp.Answer)
+	}
+
+	// The target of the CNAME is shared with direct queries.
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 1 {
+		t.Fatalf("Expected the A RRset to be cached but got %d queries", n)
+	}
+
+	clock.advance(35 * time.Second)
+	cacheQuery(t, c, "www.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 2 {
+		t.Fatalf("Expected the entry to expire but got %d queries", n)
+	}
+}
+
+func TestCacheZero(t *testing.T) {
+	ex := &countingExchanger{zone: testLookupZone, soa: testSOA}
+	c := &Cache{Exchanger: ex}
+
+	cacheQuery(t, c, "www.noteip.de", TypeA)
+	cacheQuery(t, c, "www.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 1 || c.Len() == 0 {
+		t.Fatalf("Expected the zero Cache to cache, got %d queries", n)
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	c, ex, clock := newTestCache(0)
+
+	resp := cacheQuery(t, c, "missing.noteip.de", TypeA)
+	if resp.Header.ResponseCode() != RCodeNameError {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+	// NXDOMAIN applies to all types of the name.
+	clock.advance(100 * time.Second)
+	resp = cacheQuery(t, c, "missing.noteip.de", TypeMX)
+	if ex.count.Load() != 1 || resp.Header.ResponseCode() != RCodeNameError {
+		t.Fatalf("Expected a cached NXDOMAIN but got %v", resp)
+	}
+	if len(resp.Authority) != 1 || resp.Authority[0].TTL != 200 {
+		t.Fatalf("Expected the SOA with the remaining negative TTL but got %v", resp.Authority)
+	}
+
+	// NODATA only applies to the type.
+	cacheQuery(t, c, "v4.noteip.de", TypeAAAA)
+	resp = cacheQuery(t, c, "v4.noteip.de", TypeAAAA)
+	if ex.count.Load() != 2 || resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) != 0 {
+		t.Fatalf("Expected a cached NODATA but got %v", resp)
+	}
+	cacheQuery(t, c, "v4.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 3 {
+		t.Fatalf("Expected a query for another type but got %d queries", n)
+	}
+
+	clock.advance(200 * time.Second)
+	cacheQuery(t, c, "missing.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 4 {
+		t.Fatalf("Expected the negative entry to expire but got %d queries", n)
+	}
+}
+
+func TestCacheNegativeWithoutSOA(t *testing.T) {
+	c, ex, _ := newTestCache(0)
+	ex.soa = nil
+
+	cacheQuery(t, c, "missing.noteip.de", TypeA)
+	cacheQuery(t, c, "missing.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 2 {
+		t.Fatalf("Expected negative answers without SOA not to be cached but got %d queries", n)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	var records []*ResourceRecord
+	for i := range 1000 {
+		name := DNSName(fmt.Sprintf("host%d.noteip.de", i))
+		records = append(records, NewResourceRecord(name, ClassIN, 60, &A{Addr: netip.AddrFrom4([4]byte{192, 0, 2, byte(i)})}))
+	}
+	c, ex, _ := newTestCache(64)
+	ex.zone = &testZone{records: records}
+
+	for i := range 1000 {
+		cacheQuery(t, c, fmt.Sprintf("host%d.noteip.de", i), TypeA)
+	}
+	if n := c.Len(); n > 64 {
+		t.Fatalf("Cache holds %d entries", n)
+	}
+
+	c.Flush()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Cache holds %d entries after Flush", n)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c, _, _ := newTestCache(0)
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				name := []string{"www.noteip.de", "git.noteip.de", "missing.noteip.de"}[(i+j)%3]
+				q, _ := NewQuery(name, TypeA, ClassIN)
+				if _, err := c.Exchange(context.Background(), q); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCacheServeStale(t *testing.T) {
+	c, ex, clock := newTestCache(0)
+	c.MaxStale = time.Hour
+
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	clock.advance(2 * time.Minute)
+	ex.zone = &testZone{fail: map[DNSName]bool{"git.noteip.de": true}}
+
+	resp := cacheQuery(t, c, "git.noteip.de", TypeA)
+	if len(resp.Answer) != 1 || resp.Answer[0].TTL != uint32(DefaultStaleTTL/time.Second) {
+		t.Fatalf("Expected a stale answer but got %v", resp)
+	}
+	c.refreshes.Wait()
+	if n := ex.count.Load(); n != 2 {
+		t.Fatalf("Expected a background refresh but got %d queries", n)
+	}
+
+	// The failed refresh keeps the stale entry.
+	resp = cacheQuery(t, c, "git.noteip.de", TypeA)
+	c.refreshes.Wait()
+	if len(resp.Answer) != 1 {
+		t.Fatalf("Expected a stale answer but got %v", resp)
+	}
+
+	// A successful refresh replaces it.
+	ex.zone = testLookupZone
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	c.refreshes.Wait()
+	resp = cacheQuery(t, c, "git.noteip.de", TypeA)
+	if resp.Answer[0].TTL != 60 {
+		t.Fatalf("Expected a refreshed answer but got %v", resp)
+	}
+
+	// Beyond MaxStale the entry is gone.
+	clock.advance(2 * time.Hour)
+	before := ex.count.Load()
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	if n := ex.count.Load(); n != before+1 {
+		t.Fatalf("Expected a synchronous query but got %d queries", n-before)
+	}
+	c.refreshes.Wait()
+}
+
+func TestCacheLookupStale(t *testing.T) {
+	c, ex, clock := newTestCache(0)
+	c.MaxStale = time.Hour
+
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	if _, ok := c.Lookup(q); !ok {
+		t.Fatal("Expected a cached answer")
+	}
+
+	// Lookup skips the expired entry but keeps it for Exchange.
+	clock.advance(2 * time.Minute)
+	if resp, ok := c.Lookup(q); ok {
+		t.Fatalf("Expected no answer from an expired entry but got %v", resp)
+	}
+	ex.zone = &testZone{fail: map[DNSName]bool{"git.noteip.de": true}}
+	resp := cacheQuery(t, c, "git.noteip.de", TypeA)
+	c.refreshes.Wait()
+	if len(resp.Answer) != 1 || resp.Answer[0].TTL != uint32(DefaultStaleTTL/time.Second) {
+		t.Fatalf("Expected a stale answer but got %v", resp)
+	}
+}
+
+func TestCachePrefetch(t *testing.T) {
+	c, ex, clock := newTestCache(0)
+	c.PrefetchHits = 2
+
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	clock.advance(55 * time.Second)
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	c.refreshes.Wait()
+	if n := ex.count.Load(); n != 1 {
+		t.Fatalf("Expected no prefetch after one hit but got %d queries", n)
+	}
+
+	resp := cacheQuery(t, c, "git.noteip.de", TypeA)
+	c.refreshes.Wait()
+	if n := ex.count.Load(); n != 2 {
+		t.Fatalf("Expected a prefetch but got %d queries", n)
+	}
+	if resp.Answer[0].TTL != 5 {
+		t.Fatalf("Expected the cached answer but got %v", resp)
+	}
+
+	clock.advance(10 * time.Second)
+	cacheQuery(t, c, "git.noteip.de", TypeA)
+	if n := ex.count.Load(); n != 2 {
+		t.Fatalf("Expected the prefetched entry to be used but got %d queries", n)
+	}
+}
+
+func TestCacheNXDomainReplaced(t *testing.T) {
+	c, ex, clock := newTestCache(0)
+	c.MaxStale = time.Hour
+
+	cacheQuery(t, c, "new.noteip.de", TypeA)
+	clock.advance(10 * time.Minute)
+	ex.zone = &testZone{records: []*ResourceRecord{testA("new.noteip.de", 192, 0, 2, 9)}}
+	cacheQuery(t, c, "new.noteip.de", TypeA)
+	c.refreshes.Wait()
+
+	resp := cacheQuery(t, c, "new.noteip.de", TypeA)
+	if resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) != 1 {
+		t.Fatalf("Expected the new records but got %v", resp)
+	}
+}
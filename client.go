@@ -0,0 +1,246 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port name servers listen on.
+const DefaultPort = "53"
+
+// DefaultTLSPort is the port of DNS over TLS (RFC 7858).
+const DefaultTLSPort = "853"
+
+// maxMessageLen is the largest message that fits into a TCP frame or UDP
+// datagram.
+const maxMessageLen = 0xFFFF
+
+// Client sends queries to a name server over UDP, TCP or TLS.
+type Client struct {
+	// Net is "udp", "tcp" or "tcp-tls" for DNS over TLS. UDP is used if it
+	// is empty; truncated UDP responses are retried over TCP.
+	Net string
+
+	// TLSConfig is used for DNS over TLS. The server name is taken from
+	// the server address if it is not set.
+	TLSConfig *tls.Config
+
+	// Timeout limits a single exchange including a TCP retry. It defaults
+	// to 5 seconds.
+	Timeout time.Duration
+
+	// Dialer is used to open connections if set.
+	Dialer *net.Dialer
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 5 * time.Second
+}
+
+func (c *Client) dial(ctx context.Context, network string, server string) (net.Conn, error) {
+	d := c.Dialer
+	if d == nil {
+		d = new(net.Dialer)
+	}
+	if network != "tcp-tls" {
+		return d.DialContext(ctx, network, withDefaultPort(server, DefaultPort))
+	}
+
+	server = withDefaultPort(server, DefaultTLSPort)
+	conf := new(tls.Config)
+	if c.TLSConfig != nil {
+		conf = c.TLSConfig.Clone()
+	}
+	if conf.ServerName == "" {
+		conf.ServerName, _, _ = net.SplitHostPort(server)
+	}
+	td := &tls.Dialer{NetDialer: d, Config: conf}
+	return td.DialContext(ctx, "tcp", server)
+}
+
+// withDefaultPort appends port to server if it has none.
+func withDefaultPort(server string, port string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), port)
+}
+
+// Exchange sends q to server and returns the response. server is a host
+// with optional port. Datagrams that do not answer q are ignored.
+func (c *Client) Exchange(ctx context.Context, q *Message, server string) (*Message, error) {
+	return c.exchange(ctx, q, server, matchResponse)
+}
+
+// exchange is Exchange with verify deciding which responses answer q.
+// Datagrams it rejects are ignored; if no other response arrives, the
+// error of the last one is returned.
+func (c *Client) exchange(ctx context.Context, q *Message, server string, verify func(q, resp *Message) error) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	if c.Net == "tcp" || c.Net == "tcp-tls" {
+		return c.exchangeTCP(ctx, q, server, verify)
+	}
+
+	resp, err := c.exchangeUDP(ctx, q, server, verify)
+	if err == nil && resp.Header.IsTruncated() {
+		return c.exchangeTCP(ctx, q, server, verify)
+	}
+	return resp, err
+}
+
+// matchResponse returns ErrUnexpectedResponse unless IsResponseTo holds.
+func matchResponse(q, resp *Message) error {
+	if !IsResponseTo(q, resp) {
+		return ErrUnexpectedResponse
+	}
+	return nil
+}
+
+func (c *Client) exchangeUDP(ctx context.Context, q *Message, server string, verify func(q, resp *Message) error) (*Message, error) {
+	conn, err := c.dial(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := setConnDeadline(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(q.Encode()); err != nil {
+		return nil, ctxError(ctx, err)
+	}
+
+	buf := make([]byte, maxMessageLen)
+	var rejected error
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			err = ctxError(ctx, err)
+			if rejected != nil && !errors.Is(err, context.Canceled) {
+				return nil, rejected
+			}
+			return nil, err
+		}
+		resp, err := ReadMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		if err := verify(q, resp); err != nil {
+			// Keep waiting for the real answer. Only responses that
+			// match the question say something about the server.
+			if IsResponseTo(q, resp) {
+				rejected = err
+			}
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func (c *Client) exchangeTCP(ctx context.Context, q *Message, server string, verify func(q, resp *Message) error) (*Message, error) {
+	network := "tcp"
+	if c.Net == "tcp-tls" {
+		network = c.Net
+	}
+	conn, err := c.dial(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := setConnDeadline(ctx, conn)
+	defer stop()
+
+	if err := WriteTCPMessage(conn, q.Encode()); err != nil {
+		return nil, ctxError(ctx, err)
+	}
+	b, err := ReadTCPMessage(conn)
+	if err != nil {
+		return nil, ctxError(ctx, err)
+	}
+	resp, err := ReadMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(q, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// setConnDeadline applies the deadline of ctx to conn and aborts pending
+// I/O once ctx is done. The returned function releases the watcher.
+func setConnDeadline(ctx context.Context, conn net.Conn) (stop func() bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// ctxError prefers the context error over the I/O error it caused.
+func ctxError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// IsResponseTo reports whether resp is a response to q: the Id has to
+// match and the question has to be echoed. Responses without a question
+// are accepted if they signal an error.
+func IsResponseTo(q *Message, resp *Message) bool {
+	if resp.Header == nil || !resp.Header.IsResponse() || resp.Header.Id != q.Header.Id {
+		return false
+	}
+	if len(resp.Question) == 0 {
+		return resp.Header.ResponseCode() != RCodeNoError
+	}
+	if len(resp.Question) != len(q.Question) {
+		return false
+	}
+	for i, rq := range resp.Question {
+		qq := q.Question[i]
+		if rq.Type != qq.Type || rq.Class != qq.Class || !rq.Name.Equal(qq.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTCPMessage writes b prefixed with its two byte length as used on
+// TCP connections.
+func WriteTCPMessage(w io.Writer, b []byte) error {
+	if len(b) > maxMessageLen {
+		return ErrValueTooLarge
+	}
+	buf := make([]byte, 2, 2+len(b))
+	uint16ToByte(uint16(len(b)), buf)
+	_, err := w.Write(append(buf, b...))
+	return err
+}
+
+// ReadTCPMessage reads a message prefixed with its two byte length.
+func ReadTCPMessage(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, byteToUint16(l[:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
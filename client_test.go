@@ -0,0 +1,171 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// startTestServer answers queries on UDP and TCP of the same loopback
+// port with handler and returns the address. A nil response is dropped.
+func startTestServer(t *testing.T, handler func(q *Message, network string) *Message) string {
+	return startTestServerAt(t, "127.0.0.1:0", handler)
+}
+
+// startTestServerAt is startTestServer for a given address. A random port
+// is chosen if the port of addr is 0.
+func startTestServerAt(t *testing.T, addr string, handler func(q *Message, network string) *Message) string {
+	t.Helper()
+
+	var pc net.PacketConn
+	var l net.Listener
+	for i := 0; ; i++ {
+		var err error
+		pc, err = net.ListenPacket("udp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err = net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			break
+		}
+		pc.Close()
+		if i == 10 {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		l.Close()
+	})
+
+	go func() {
+		buf := make([]byte, maxMessageLen)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			q, err := ReadMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			if resp := handler(q, "udp"); resp != nil {
+				pc.WriteTo(resp.Encode(), addr)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					b, err := ReadTCPMessage(conn)
+					if err != nil {
+						return
+					}
+					q, err := ReadMessage(b)
+					if err != nil {
+						return
+					}
+					if resp := handler(q, "tcp"); resp != nil {
+						WriteTCPMessage(conn, resp.Encode())
+					}
+				}
+			}()
+		}
+	}()
+
+	return pc.LocalAddr().String()
+}
+
+// testReply returns a response to q with the given answers.
+func testReply(q *Message, rcode Rcode, answers ...*ResourceRecord) *Message {
+	hdr := *q.Header
+	resp := &Message{Header: &hdr, Question: q.Question, Answer: answers}
+	resp.Header.SetResponse(true)
+	resp.Header.SetRecursionAvailable(true)
+	resp.Header.SetResponseCode(rcode)
+	return resp
+}
+
+func testA(name DNSName, ip ...byte) *ResourceRecord {
+	return &ResourceRecord{Name: name, Type: TypeA, Class: ClassIN, TTL: 60, Length: 4, Data: ip}
+}
+
+func TestClientExchangeUDP(t *testing.T) {
+	addr := startTestServer(t, func(q *Message, network string) *Message {
+		return testReply(q, RCodeNoError, testA(q.Question[0].Name, 192, 0, 2, 1))
+	})
+
+	q, err := NewQuery("git.noteip.de", TypeA, ClassIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{Timeout: time.Second}
+	resp, err := c.Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Answer) != 1 || resp.Answer[0].Data[0] != 192 {
+		t.Fatalf("Unexpected answer %v", resp.Answer)
+	}
+}
+
+func TestClientTruncatedRetry(t *testing.T) {
+	addr := startTestServer(t, func(q *Message, network string) *Message {
+		if network == "udp" {
+			resp := testReply(q, RCodeNoError)
+			resp.Header.SetTruncated(true)
+			return resp
+		}
+		return testReply(q, RCodeNoError, testA(q.Question[0].Name, 192, 0, 2, 1))
+	})
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	c := &Client{Timeout: time.Second}
+	resp, err := c.Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Header.IsTruncated() || len(resp.Answer) != 1 {
+		t.Fatal("Truncated response should have been retried over TCP.")
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	addr := startTestServer(t, func(q *Message, network string) *Message {
+		return nil
+	})
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	c := &Client{Timeout: 50 * time.Millisecond}
+	if _, err := c.Exchange(context.Background(), q, addr); err == nil {
+		t.Fatal("Exchange should time out.")
+	}
+}
+
+func TestIsResponseTo(t *testing.T) {
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+
+	resp := testReply(q, RCodeNoError)
+	if !IsResponseTo(q, resp) {
+		t.Fatal("Should be a response.")
+	}
+
+	other, _ := NewQuery("www.noteip.de", TypeA, ClassIN)
+	resp = testReply(other, RCodeNoError)
+	resp.Header.Id = q.Header.Id
+	if IsResponseTo(q, resp) {
+		t.Fatal("Response to a different question should be rejected.")
+	}
+}
@@ -0,0 +1,246 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Dialer connects the pure Go resolver of the net package to an
+// Exchanger. The queries the runtime writes to the connections returned by
+// Dial are decoded, sent with the Exchanger and the responses are handed
+// back, so net.Resolver can use any transport of this package:
+//
+//	r := &net.Resolver{PreferGo: true, Dial: (&dns.Dialer{Exchanger: up}).Dial}
+type Dialer struct {
+	Exchanger Exchanger
+}
+
+// NewGoResolver returns a net.Resolver that sends all queries through ex.
+func NewGoResolver(ex Exchanger) *net.Resolver {
+	d := &Dialer{Exchanger: ex}
+	return &net.Resolver{PreferGo: true, Dial: d.Dial}
+}
+
+// Dial returns a connection that answers the queries written to it. The
+// address chosen by the runtime is ignored; the Exchanger decides where
+// queries go. Datagram networks ("udp", "udp4", "udp6") return a
+// net.PacketConn with one message per read and write, stream networks use
+// the two byte length prefix of TCP.
+func (d *Dialer) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	c := &exchangeConn{
+		ex:        d.Exchanger,
+		responses: make(chan []byte, 16),
+		closed:    make(chan struct{}),
+		remote:    dialerAddr{network, address},
+	}
+	switch network {
+	case "udp", "udp4", "udp6":
+	case "tcp", "tcp4", "tcp6":
+		c.stream = true
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+	c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	return c, nil
+}
+
+// dialerAddr is the address of an exchangeConn.
+type dialerAddr struct {
+	network, address string
+}
+
+func (a dialerAddr) Network() string { return a.network }
+func (a dialerAddr) String() string  { return a.address }
+
+// exchangeConn is the connection returned by Dialer.Dial.
+type exchangeConn struct {
+	ex     Exchanger
+	stream bool
+	remote net.Addr
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	responses chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	rbuf          []byte
+	wbuf          []byte
+}
+
+func (c *exchangeConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	if !c.stream {
+		c.handle(append([]byte(nil), b...))
+		return len(b), nil
+	}
+
+	var queries [][]byte
+	c.mu.Lock()
+	c.wbuf = append(c.wbuf, b...)
+	for len(c.wbuf) >= 2 {
+		l := int(byteToUint16(c.wbuf))
+		if len(c.wbuf) < 2+l {
+			break
+		}
+		queries = append(queries, append([]byte(nil), c.wbuf[2:2+l]...))
+		c.wbuf = c.wbuf[2+l:]
+	}
+	c.mu.Unlock()
+
+	for _, q := range queries {
+		c.handle(q)
+	}
+	return len(b), nil
+}
+
+// handle exchanges the query in b in the background. Failures are
+// answered with SERVFAIL so that the runtime moves on.
+func (c *exchangeConn) handle(b []byte) {
+	q, err := ReadMessage(b)
+	if err != nil || len(q.Question) == 0 {
+		// Nothing sensible to answer, the runtime will time out.
+		return
+	}
+
+	c.mu.Lock()
+	deadline := c.writeDeadline
+	if deadline.IsZero() || (!c.readDeadline.IsZero() && c.readDeadline.Before(deadline)) {
+		deadline = c.readDeadline
+	}
+	c.mu.Unlock()
+
+	go func() {
+		ctx := c.ctx
+		if !deadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+
+		resp, err := c.ex.Exchange(ctx, q)
+		if err != nil {
+			resp = errorResponse(q, RCodeServerFailure)
+		}
+		resp.Header.Id = q.Header.Id
+		if !c.stream {
+			resp.Truncate(q.UDPSize())
+		}
+
+		wire := resp.Encode()
+		if c.stream {
+			buf := make([]byte, 2, 2+len(wire))
+			uint16ToByte(uint16(len(wire)), buf)
+			wire = append(buf, wire...)
+		}
+		select {
+		case c.responses <- wire:
+		case <-c.closed:
+		}
+	}()
+}
+
+// errorResponse returns an empty response to q with rcode.
+func errorResponse(q *Message, rcode Rcode) *Message {
+	hdr := *q.Header
+	resp := &Message{Header: &hdr, Question: q.Question}
+	resp.Header.SetResponse(true)
+	resp.Header.Flags &^= 0xF
+	resp.Header.SetResponseCode(rcode)
+	return resp
+}
+
+func (c *exchangeConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.stream && len(c.rbuf) > 0 {
+		n := copy(b, c.rbuf)
+		c.rbuf = c.rbuf[n:]
+		c.mu.Unlock()
+		return n, nil
+	}
+	deadline := c.readDeadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case wire := <-c.responses:
+		n := copy(b, wire)
+		if c.stream {
+			c.mu.Lock()
+			c.rbuf = wire[n:]
+			c.mu.Unlock()
+		}
+		return n, nil
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	case <-c.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (c *exchangeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.remote, err
+}
+
+func (c *exchangeConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+func (c *exchangeConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.cancel()
+	})
+	return nil
+}
+
+func (c *exchangeConn) LocalAddr() net.Addr {
+	return dialerAddr{c.remote.Network(), "local"}
+}
+
+func (c *exchangeConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *exchangeConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline, c.writeDeadline = t, t
+	return nil
+}
+
+func (c *exchangeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *exchangeConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// exchangeConn is used as net.PacketConn by the runtime for datagrams.
+var _ net.PacketConn = (*exchangeConn)(nil)
@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGoResolver(t *testing.T) {
+	up := &Upstream{Client: &Client{Timeout: time.Second}, Addr: startTestServer(t, testLookupZone.handle)}
+	r := NewGoResolver(up)
+	ctx := context.Background()
+
+	addrs, err := r.LookupHost(ctx, "www.noteip.de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(addrs)
+	if want := []string{"192.0.2.1", "2001:db8::1"}; !slices.Equal(addrs, want) {
+		t.Errorf("LookupHost = %v, want %v", addrs, want)
+	}
+
+	mx, err := r.LookupMX(ctx, "noteip.de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mx) != 2 || mx[0].Host != "mx1.noteip.de." {
+		t.Errorf("LookupMX = %v", mx)
+	}
+
+	_, err = r.LookupHost(ctx, "missing.noteip.de")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("LookupHost(missing) error = %v, want not found", err)
+	}
+}
+
+// failingExchanger fails every exchange.
+type failingExchanger struct{}
+
+func (failingExchanger) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	return nil, ErrNoServers
+}
+
+func TestGoResolverServerFailure(t *testing.T) {
+	r := NewGoResolver(failingExchanger{})
+	_, err := r.LookupHost(context.Background(), "www.noteip.de")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || dnsErr.IsNotFound {
+		t.Errorf("LookupHost error = %v, want server failure", err)
+	}
+}
+
+func TestDialerTCP(t *testing.T) {
+	up := &Upstream{Client: &Client{Timeout: time.Second}, Addr: startTestServer(t, testLookupZone.handle)}
+	d := &Dialer{Exchanger: up}
+	conn, err := d.Dial(context.Background(), "tcp", "192.0.2.53:53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	q.Header.Id = 4711
+	if err := WriteTCPMessage(conn, q.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadTCPMessage(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ReadMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Id != 4711 || len(resp.Answer) != 1 {
+		t.Errorf("response = %v", resp)
+	}
+}
+
+func TestDialerUnknownNetwork(t *testing.T) {
+	d := &Dialer{Exchanger: failingExchanger{}}
+	if _, err := d.Dial(context.Background(), "unix", "/tmp/dns"); err == nil {
+		t.Error("Dial(unix) succeeded")
+	}
+}
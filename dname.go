@@ -0,0 +1,68 @@
+package dns
+
+// SynthesizeCNAME returns the CNAME record that the DNAME record dname
+// synthesizes for name, which has to lie below the owner of dname: the
+// owner suffix of name is replaced with the target of dname and the CNAME
+// gets the TTL of dname (RFC 6672 section 2.2). ErrNameTooLong is returned
+// if the new name exceeds 255 octets; servers answer such queries with
+// YXDOMAIN.
+func SynthesizeCNAME(dname *ResourceRecord, name DNSName) (*ResourceRecord, error) {
+	data, err := dname.Rdata()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := data.(*DNAME)
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	if !name.IsSubdomainOf(dname.Name) || name.Equal(dname.Name) {
+		return nil, ErrNotBelowDNAME
+	}
+
+	labels := name.labels()
+	labels = append(labels[:len(labels)-dname.Name.CountLabels()], d.Target.labels()...)
+	wireLen := 1
+	for _, label := range labels {
+		wireLen += 1 + len(label)
+	}
+	if wireLen > 255 {
+		return nil, ErrNameTooLong
+	}
+	return NewResourceRecord(name, dname.Class, dname.TTL, &CNAME{Target: nameFromLabels(labels)}), nil
+}
+
+// dnameTarget returns the target a DNAME record in answers synthesizes for
+// name.
+func dnameTarget(answers []*ResourceRecord, name DNSName) (DNSName, bool) {
+	for _, rr := range answers {
+		if rr.Type != TypeDNAME {
+			continue
+		}
+		if cname, err := SynthesizeCNAME(rr, name); err == nil {
+			data, _ := cname.Rdata()
+			return data.(*CNAME).Target, true
+		}
+	}
+	return "", false
+}
+
+// aliasTarget returns the name the alias name points to in answers: the
+// target of the DNAME record of an ancestor that covers it or else the
+// target of its CNAME record. DNAME records apply even without the
+// synthesized CNAME, and a synthesized CNAME that does not match its DNAME
+// is ignored (RFC 6672 section 3.4). An empty name is returned if name is
+// no alias.
+func aliasTarget(answers []*ResourceRecord, name DNSName) DNSName {
+	if target, ok := dnameTarget(answers, name); ok {
+		return target
+	}
+	var target DNSName
+	for _, rr := range answers {
+		if rr.Type == TypeCNAME && rr.Name.Equal(name) {
+			if data, err := rr.Rdata(); err == nil {
+				target = data.(*CNAME).Target
+			}
+		}
+	}
+	return target
+}
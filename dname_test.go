@@ -0,0 +1,123 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testDNAME(owner, target DNSName) *ResourceRecord {
+	return NewResourceRecord(owner, ClassIN, 600, &DNAME{Target: target})
+}
+
+func TestSynthesizeCNAME(t *testing.T) {
+	dname := testDNAME("old.example.com", "new.example.net")
+	cname, err := SynthesizeCNAME(dname, "www.sub.OLD.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := cname.Rdata()
+	if cname.Type != TypeCNAME || cname.TTL != 600 || !cname.Name.Equal("www.sub.old.example.com") ||
+		data.(*CNAME).Target != "www.sub.new.example.net" {
+		t.Fatalf("Unexpected CNAME %v", cname)
+	}
+
+	for _, name := range []DNSName{"old.example.com", "example.com", "xold.example.com"} {
+		if _, err := SynthesizeCNAME(dname, name); !errors.Is(err, ErrNotBelowDNAME) {
+			t.Fatalf("%s: expected ErrNotBelowDNAME but got %v", name, err)
+		}
+	}
+
+	long := DNSName(strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63))
+	if _, err := SynthesizeCNAME(testDNAME("x", long), DNSName(strings.Repeat("d", 63)+".x")); !errors.Is(err, ErrNameTooLong) {
+		t.Fatalf("Expected ErrNameTooLong but got %v", err)
+	}
+	if _, err := SynthesizeCNAME(testA("x", 192, 0, 2, 1), "a.x"); err == nil {
+		t.Fatal("Expected an error for a record that is no DNAME.")
+	}
+}
+
+func TestDNAMEChain(t *testing.T) {
+	answers := []*ResourceRecord{
+		testDNAME("old.example.com", "new.example.com"),
+		testA("www.new.example.com", 192, 0, 2, 1),
+	}
+	// Without the synthesized CNAME.
+	records, name, _ := followCNAMEs(answers, "www.old.example.com", TypeA, 0)
+	if len(records) != 1 || name != "www.new.example.com" {
+		t.Fatalf("Unexpected chain end %s %v", name, records)
+	}
+
+	// A CNAME that does not match the DNAME is ignored.
+	forged := NewResourceRecord("www.old.example.com", ClassIN, 600, &CNAME{Target: "bank.example.com"})
+	answers = append(answers, forged, testA("bank.example.com", 192, 0, 2, 66))
+	chain := cnameChain(answers, "www.old.example.com", TypeA)
+	if !slices.Equal(chain, []DNSName{"www.old.example.com", "www.new.example.com"}) {
+		t.Fatalf("Unexpected chain %v", chain)
+	}
+
+	q, _ := NewQuery("www.old.example.com", TypeA, ClassIN)
+	resp := testReply(q, RCodeNoError, answers...)
+	var reasons []string
+	for _, u := range FindUnsolicited(resp, "example.com") {
+		reasons = append(reasons, u.Record.Type.String()+" "+u.Reason)
+	}
+	if !slices.Equal(reasons, []string{"CNAME does not match DNAME", "A not in answer chain"}) {
+		t.Fatalf("Unexpected unsolicited records %v", reasons)
+	}
+}
+
+func TestZoneDNAME(t *testing.T) {
+	long := DNSName(strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + ".example.com")
+	z, err := NewZone("example.com",
+		testSOAFor("example.com"),
+		testDNAME("old.example.com", "new.example.com"),
+		testAddr("www.new.example.com", "192.0.2.1"),
+		testDNAME("ext.example.com", "example.net"),
+		testDNAME("grow.example.com", long),
+		NewResourceRecord("alias.example.com", ClassIN, 300, &CNAME{Target: "www.old.example.com"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label := strings.Repeat("x", 40)
+	tests := []struct {
+		name   DNSName
+		qtype  Type
+		rcode  Rcode
+		answer []string
+	}{
+		{"www.old.example.com", TypeA, RCodeNoError,
+			[]string{"old.example.com/DNAME", "www.old.example.com/CNAME", "www.new.example.com/A"}},
+		{"missing.old.example.com", TypeA, RCodeNameError,
+			[]string{"old.example.com/DNAME", "missing.old.example.com/CNAME"}},
+		{"old.example.com", TypeDNAME, RCodeNoError, []string{"old.example.com/DNAME"}},
+		{"old.example.com", TypeA, RCodeNoError, nil},
+		{"alias.example.com", TypeA, RCodeNoError,
+			[]string{"alias.example.com/CNAME", "old.example.com/DNAME", "www.old.example.com/CNAME", "www.new.example.com/A"}},
+		{"www.ext.example.com", TypeA, RCodeNoError, []string{"ext.example.com/DNAME", "www.ext.example.com/CNAME"}},
+		{DNSName(label + "." + label + "." + label + ".grow.example.com"), TypeA, RCodeYXDomain, []string{"grow.example.com/DNAME"}},
+	}
+	for _, test := range tests {
+		resp := zoneQuery(t, z, test.name, test.qtype)
+		if rcode := resp.Header.ResponseCode(); rcode != test.rcode {
+			t.Errorf("%s %s: rcode %v, expected %v", test.name, test.qtype, rcode, test.rcode)
+		}
+		if got := recordNames(resp.Answer); !slices.Equal(got, test.answer) {
+			t.Errorf("%s %s: got %v, expected %v", test.name, test.qtype, got, test.answer)
+		}
+	}
+
+	// A resolver accepts the synthesized answer.
+	q, _ := NewQuery("www.old.example.com", TypeA, ClassIN)
+	resp, _ := z.Exchange(context.Background(), q)
+	if found := FindUnsolicited(resp, "example.com"); len(found) != 0 {
+		t.Fatalf("Unexpected unsolicited records %v", found)
+	}
+	if records, _, _ := followCNAMEs(resp.Answer, "www.old.example.com", TypeA, 0); len(records) != 1 {
+		t.Fatalf("Chain does not end at the address: %v", resp.Answer)
+	}
+}
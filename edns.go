@@ -0,0 +1,50 @@
+package dns
+
+const (
+	// DefaultEDNSUDPSize is the EDNS payload size that avoids IP
+	// fragmentation on common paths (DNS Flag Day 2020).
+	DefaultEDNSUDPSize = 1232
+
+	// Largest message a client has to accept over UDP without EDNS.
+	minUDPSize = 512
+
+	// DNSSEC OK bit in the TTL field of the OPT record.
+	flagDNSSECOK = uint32(1 << 15)
+)
+
+// SetEDNS0 adds an OPT pseudo record to the additional section which
+// advertises udpSize as the largest UDP payload the sender can receive.
+// do sets the DNSSEC OK bit. An existing OPT record is replaced.
+func (msg *Message) SetEDNS0(udpSize uint16, do bool) {
+	opt := msg.OPT()
+	if opt == nil {
+		opt = &ResourceRecord{Name: RootName, Type: TypeOPT}
+		msg.Additional = append(msg.Additional, opt)
+		msg.Header.AdditionalCount++
+	}
+
+	opt.Class = Class(udpSize)
+	opt.TTL = 0
+	if do {
+		opt.TTL |= flagDNSSECOK
+	}
+}
+
+// OPT returns the OPT pseudo record of msg or nil if msg does not use
+// EDNS.
+func (msg *Message) OPT() *ResourceRecord {
+	for _, rr := range msg.Additional {
+		if rr.Type == TypeOPT {
+			return rr
+		}
+	}
+	return nil
+}
+
+// UDPSize returns the largest UDP payload the sender of msg accepts.
+func (msg *Message) UDPSize() int {
+	if opt := msg.OPT(); opt != nil && int(opt.Class) > minUDPSize {
+		return int(opt.Class)
+	}
+	return minUDPSize
+}
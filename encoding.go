@@ -5,84 +5,312 @@ import (
 	"strings"
 )
 
+// DNSName is a domain name in presentation format. Labels are separated by
+// dots and may contain the escapes defined in RFC 4343: "\." for a literal
+// dot, "\\" for a backslash and "\DDD" for an arbitrary octet given as three
+// decimal digits; any other escaped character stands for itself.
+//
+// Names are always absolute on the wire, so the trailing dot is optional:
+// "noteip.de" and "noteip.de." denote the same name. Both "" and "."
+// denote the root. DecodeDNSName returns names without the trailing dot
+// except for the root, which is returned as ".".
 type DNSName string
 
-// Encode converts a string to the DNS Name Notation format.
-func (name *DNSName) Encode(rawMsg []byte) (newRaw []byte) {
-	var buf bytes.Buffer
+// RootName is the name of the root zone.
+const RootName DNSName = "."
 
-	// prepare wire format
-	tmpName := []byte(*name)
-	parts := strings.Split(string(*name), ".")
-	wireName := make([]byte, len(tmpName)+1)
-	pos := 0
-	for i := 0; i < len(parts); i++ {
-		copy(wireName[pos:], []byte{byte(len(parts[i]))})
-		copy(wireName[pos+1:], parts[i])
+const (
+	// Maximum length of a single label in octets.
+	maxLabelLen = 63
+
+	// Maximum length of a name in wire format including the root label.
+	maxNameLen = 255
+
+	// Maximum offset a compression pointer can address.
+	maxPointerOffset = 0x3FFF
+)
 
-		pos += len(parts[i]) + 1
+// ParseDNSName checks s and returns it as DNSName.
+func ParseDNSName(s string) (DNSName, error) {
+	name := DNSName(s)
+	if err := name.Validate(); err != nil {
+		return "", err
 	}
+	return name, nil
+}
 
-	for {
-		if idx := bytes.Index(rawMsg, wireName); idx == -1 || idx >= 0xFF3F {
-			// wireName not found or idx too big to encode.
-
-			// search next "."
-			sepIdx := bytes.Index(tmpName, []byte{0x2E})
-
-			if sepIdx > -1 {
-				// Write string length and string
-				buf.Write(wireName[:sepIdx+1])
-			} else {
-				buf.Write(wireName[:])
-				// If no "." is found the encoding is done.
-				buf.WriteByte(0x00)
-				break
+// Validate reports whether name can be encoded. It fails if a label is
+// empty or longer than 63 octets, if the name exceeds 255 octets in wire
+// format or if an escape sequence is malformed.
+func (name DNSName) Validate() error {
+	_, err := name.wireLabels()
+	return err
+}
+
+// IsRoot returns true if name denotes the root.
+func (name DNSName) IsRoot() bool {
+	return name == "" || name == RootName
+}
+
+// wireLabels splits name into its labels and resolves all escapes.
+func (name DNSName) wireLabels() ([][]byte, error) {
+	labels, err := name.splitLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	wireLen := 1
+	for _, label := range labels {
+		if len(label) > maxLabelLen {
+			return nil, ErrLabelTooLong
+		}
+		wireLen += len(label) + 1
+	}
+	if wireLen > maxNameLen {
+		return nil, ErrNameTooLong
+	}
+	return labels, nil
+}
+
+// splitLabels is wireLabels without the length limits.
+func (name DNSName) splitLabels() ([][]byte, error) {
+	if name.IsRoot() {
+		return nil, nil
+	}
+
+	var labels [][]byte
+	var label []byte
+	s := string(name)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '.':
+			if len(label) == 0 {
+				return nil, ErrEmptyLabel
+			}
+			labels = append(labels, label)
+			label = nil
+			continue
+		case '\\':
+			if i+1 >= len(s) {
+				return nil, ErrInvalidEscape
 			}
+			i++
+			c = s[i]
+			if isDigit(c) {
+				if i+2 >= len(s) || !isDigit(s[i+1]) || !isDigit(s[i+2]) {
+					return nil, ErrInvalidEscape
+				}
+				v := int(c-'0')*100 + int(s[i+1]-'0')*10 + int(s[i+2]-'0')
+				if v > 0xFF {
+					return nil, ErrInvalidEscape
+				}
+				c = byte(v)
+				i += 2
+			}
+		}
+		label = append(label, c)
+	}
+	if len(label) > 0 {
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// escapeLabel converts a label from wire to presentation format.
+func escapeLabel(label []byte) string {
+	var sb strings.Builder
+	for _, c := range label {
+		switch {
+		case c == '.' || c == '\\' || c == '"' || c == '(' || c == ')' || c == ';':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x21 || c > 0x7E:
+			sb.WriteByte('\\')
+			sb.WriteByte('0' + c/100)
+			sb.WriteByte('0' + c/10%10)
+			sb.WriteByte('0' + c%10)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
 
-			wireName = wireName[sepIdx+1:]
-			tmpName = tmpName[sepIdx+1:]
-		} else {
+// nameFromLabels converts labels in wire format to a DNSName.
+func nameFromLabels(labels [][]byte) DNSName {
+	if len(labels) == 0 {
+		return RootName
+	}
+
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = escapeLabel(label)
+	}
+	return DNSName(strings.Join(parts, "."))
+}
+
+// Encode converts a string to the DNS Name Notation format. The longest
+// suffix of name that is already present in rawMsg is replaced by a
+// compression pointer. Encode expects a valid name (see Validate); labels
+// that are too long are truncated.
+func (name *DNSName) Encode(rawMsg []byte) (newRaw []byte) {
+	labels, err := name.wireLabels()
+	if err != nil {
+		labels = name.lenientLabels()
+	}
+
+	// prepare wire format
+	var wireName []byte
+	starts := make([]int, len(labels))
+	for i, label := range labels {
+		starts[i] = len(wireName)
+		wireName = append(wireName, byte(len(label)))
+		wireName = append(wireName, label...)
+	}
+	wireName = append(wireName, 0x00)
+
+	var buf bytes.Buffer
+	for i := range labels {
+		if idx := findName(rawMsg, wireName[starts[i]:]); idx >= 0 {
 			// String found calculate location.
+			buf.Write(wireName[:starts[i]])
 			loc := uint16(0xC000 + idx)
 			buf.WriteByte(byte(loc >> 8))
 			buf.WriteByte(byte(loc))
-			break
+			return append(rawMsg, buf.Bytes()...)
 		}
 	}
+	buf.Write(wireName)
 
 	return append(rawMsg, buf.Bytes()...)
 }
 
+// lenientLabels splits an invalid name on the best-effort basis used by
+// Encode: escapes are taken literally, empty labels are dropped and long
+// labels are truncated.
+func (name DNSName) lenientLabels() [][]byte {
+	var labels [][]byte
+	for _, part := range strings.Split(string(name), ".") {
+		if part == "" {
+			continue
+		}
+		if len(part) > maxLabelLen {
+			part = part[:maxLabelLen]
+		}
+		labels = append(labels, []byte(part))
+	}
+	return labels
+}
+
+// findName returns the position of wireName in rawMsg if it can be
+// addressed by a compression pointer.
+func findName(rawMsg []byte, wireName []byte) int {
+	if idx := bytes.Index(rawMsg, wireName); idx >= 0 && idx <= maxPointerOffset {
+		return idx
+	}
+	return -1
+}
+
 // DecodeDNSName converts the DNS Name Notation to a string.
 // nextIdx specifies the position of the following element.
 func DecodeDNSName(b []byte, rawMsg []byte) (name DNSName, err error, nextIdx int) {
-	var dnsStr string
+	name, nextIdx, perr := decodeName(b, rawMsg, offsetOf(b, rawMsg), false)
+	if perr != nil {
+		return "", perr, 0
+	}
+	return name, nil, nextIdx
+}
 
-	i := 0
-	for i < len(b) {
-		l := int(b[i])
-		if l >= 0xC0 {
+// maxPointers limits the number of compression pointers followed while
+// decoding a single name. A name has at most 127 labels so any name that
+// needs more pointers contains a loop.
+const maxPointers = 127
+
+// decodeName decodes the name at the start of b. Compression pointers are
+// resolved against rawMsg and base is the position of b within rawMsg.
+// If strict is set, compression pointers have to point backwards.
+func decodeName(b []byte, rawMsg []byte, base int, strict bool) (name DNSName, nextIdx int, perr *ParseError) {
+	labels, nextIdx, perr := decodeLabels(b, rawMsg, base, strict)
+	if perr != nil {
+		return "", 0, perr
+	}
+	return nameFromLabels(labels), nextIdx, nil
+}
+
+// decodeLabels works like decodeName but returns the labels in wire format.
+func decodeLabels(b []byte, rawMsg []byte, base int, strict bool) (labels [][]byte, nextIdx int, perr *ParseError) {
+	buf, pos := b, 0
+	pointers := 0
+	wireLen := 1
+	for {
+		offset := base + pos
+		if pointers > 0 {
+			offset = pos
+		}
+		if pos >= len(buf) {
+			return nil, 0, newParseError(offset, SectionUnknown, "name exceeds message")
+		}
+
+		l := int(buf[pos])
+		switch l & 0xC0 {
+		case 0xC0:
 			// DNS Compression used.
-			l := byteToUint16(b[i:]) ^ 0xC000
-			tmpName, err, _ := DecodeDNSName(rawMsg[l:], rawMsg)
-			if err != nil {
-				return "", err, 0
+			if pos+1 >= len(buf) {
+				return nil, 0, newParseError(offset, SectionUnknown, "truncated compression pointer")
+			}
+			ptr := int(byteToUint16(buf[pos:]) ^ 0xC000)
+			if ptr >= len(rawMsg) {
+				return nil, 0, newParseError(offset, SectionUnknown, "compression pointer out of range")
 			}
-			dnsStr += "." + string(tmpName)
-			i += 1
-			break
-		} else {
-			next := i + l + 1
+			if strict && ptr >= offset {
+				return nil, 0, newParseError(offset, SectionUnknown, "forward compression pointer")
+			}
+			if pointers++; pointers > maxPointers {
+				return nil, 0, newParseError(offset, SectionUnknown, "compression pointer loop")
+			}
+			if pointers == 1 {
+				nextIdx = pos + 2
+			}
+			buf, pos = rawMsg, ptr
+		case 0x00:
 			if l == 0 {
-				break
-			} else if next >= len(b) {
-				return "", ErrInvalidFormat, 0
+				if pointers == 0 {
+					nextIdx = pos + 1
+				}
+				return labels, nextIdx, nil
+			}
+			next := pos + l + 1
+			if next >= len(buf) {
+				return nil, 0, newParseError(offset, SectionUnknown, "label exceeds message")
+			}
+			if wireLen += l + 1; wireLen > maxNameLen {
+				return nil, 0, newParseError(offset, SectionUnknown, "name too long")
 			}
-			dnsStr += "." + string(b[i+1:next])
-			i = next
+			labels = append(labels, buf[pos+1:next])
+			pos = next
+		default:
+			return nil, 0, newParseError(offset, SectionUnknown, "reserved label type")
 		}
 	}
+}
 
-	return DNSName(dnsStr[1:]), nil, i + 1
+// offsetOf returns the position of b within msg. b has to be a suffix of
+// msg, otherwise 0 is returned.
+func offsetOf(b []byte, msg []byte) int {
+	if len(b) == 0 {
+		return len(msg)
+	}
+	if len(b) > len(msg) {
+		return 0
+	}
+	off := len(msg) - len(b)
+	if &msg[off] != &b[0] {
+		return 0
+	}
+	return off
 }
@@ -2,6 +2,8 @@ package dns
 
 import (
 	"bytes"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -56,7 +58,7 @@ func TestDNSNameDecode02(t *testing.T) {
 }
 
 func TestDNSNameDecode03(t *testing.T) {
-	if dns, err, _ := DecodeDNSName(testDataInvalid, testDataNull); err != ErrInvalidFormat || dns != "" {
+	if dns, err, _ := DecodeDNSName(testDataInvalid, testDataNull); !errors.Is(err, ErrInvalidFormat) || dns != "" {
 		t.Fatalf("DNSName should be invalid! -> %s", err)
 	}
 }
@@ -66,3 +68,94 @@ func TestDNSNameDecodeComplex(t *testing.T) {
 		t.Fatalf("Expected 'www.noteip.de' but got '%s'. Next Index = %d.", dns, num)
 	}
 }
+
+func TestDNSNameDecodeRoot(t *testing.T) {
+	if dns, err, num := DecodeDNSName(testDataNull, testDataNull); err != nil || dns != "." || num != 1 {
+		t.Fatalf("Expected the root name but got '%q' (%v). Next Index = %d.", dns, err, num)
+	}
+}
+
+func TestDNSNameDecodePointerErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"compression pointer out of range": {0x03, 0x77, 0x77, 0x77, 0xC0, 0x20},
+		"compression pointer loop":         {0xC0, 0x00},
+		"name too long":                    {0x03, 0x77, 0x77, 0x77, 0xC0, 0x00},
+		"reserved label type":              {0x40, 0x00},
+		"truncated compression pointer":    {0xC0},
+	}
+
+	for reason, data := range tests {
+		_, err, _ := DecodeDNSName(data, data)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("Expected a ParseError for %q but got %v", reason, err)
+		}
+		if perr.Reason != reason {
+			t.Fatalf("Expected %q but got %q", reason, perr.Reason)
+		}
+	}
+}
+
+func TestDNSNameEscapes(t *testing.T) {
+	tests := map[DNSName][]byte{
+		`My\ Printer._ipp._tcp.local`: {0x0A, 'M', 'y', ' ', 'P', 'r', 'i', 'n', 't', 'e', 'r', 0x04, '_', 'i', 'p', 'p', 0x04, '_', 't', 'c', 'p', 0x05, 'l', 'o', 'c', 'a', 'l', 0x00},
+		`a\.b.de.`:                    {0x03, 'a', '.', 'b', 0x02, 'd', 'e', 0x00},
+		`back\\slash`:                 {0x0A, 'b', 'a', 'c', 'k', '\\', 's', 'l', 'a', 's', 'h', 0x00},
+		`\000\255.de`:                 {0x02, 0x00, 0xFF, 0x02, 'd', 'e', 0x00},
+		".":                           {0x00},
+		"":                            {0x00},
+	}
+
+	for name, expected := range tests {
+		enc := name.Encode([]byte{})
+		if !bytes.Equal(enc, expected) {
+			t.Fatalf("Encoding of %q failed! Expected '%x' got '%x'", name, expected, enc)
+		}
+	}
+}
+
+func TestDNSNameDecodeEscapes(t *testing.T) {
+	tests := map[string][]byte{
+		`My\032Printer._ipp._tcp.local`: {0x0A, 'M', 'y', ' ', 'P', 'r', 'i', 'n', 't', 'e', 'r', 0x04, '_', 'i', 'p', 'p', 0x04, '_', 't', 'c', 'p', 0x05, 'l', 'o', 'c', 'a', 'l', 0x00},
+		`a\.b.de`:                       {0x03, 'a', '.', 'b', 0x02, 'd', 'e', 0x00},
+		`\000\255\\.de`:                 {0x03, 0x00, 0xFF, '\\', 0x02, 'd', 'e', 0x00},
+	}
+
+	for expected, data := range tests {
+		dns, err, _ := DecodeDNSName(data, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(dns) != expected {
+			t.Fatalf("Expected %q but got %q", expected, dns)
+		}
+
+		// The decoded name has to round-trip.
+		if enc := dns.Encode([]byte{}); !bytes.Equal(enc, data) {
+			t.Fatalf("Round-trip of %q failed! Expected '%x' got '%x'", dns, data, enc)
+		}
+	}
+}
+
+func TestDNSNameValidate(t *testing.T) {
+	long := strings.Repeat("a", 63)
+	tests := map[DNSName]error{
+		"noteip.de":                          nil,
+		"noteip.de.":                         nil,
+		".":                                  nil,
+		DNSName(long + ".de"):                nil,
+		DNSName(long + "a.de"):               ErrLabelTooLong,
+		"noteip..de":                         ErrEmptyLabel,
+		".noteip.de":                         ErrEmptyLabel,
+		`noteip\`:                            ErrInvalidEscape,
+		`noteip\25`:                          ErrInvalidEscape,
+		`noteip\256`:                         ErrInvalidEscape,
+		DNSName(strings.Repeat(long+".", 4)): ErrNameTooLong,
+	}
+
+	for name, expected := range tests {
+		if err := name.Validate(); err != expected {
+			t.Fatalf("Validate(%q) should return %v but got %v", name, expected, err)
+		}
+	}
+}
@@ -2,10 +2,158 @@ package dns
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
 	ErrInvalidFormat  = errors.New("Invalid Format.")
 	ErrNotImplemented = errors.New("Not Implemented.")
 	ErrValueTooLarge  = errors.New("Value too large.")
+
+	ErrUnknownMnemonic = errors.New("Unknown Mnemonic.")
+
+	ErrEmptyLabel    = errors.New("Empty label.")
+	ErrLabelTooLong  = errors.New("Label too long.")
+	ErrNameTooLong   = errors.New("Name too long.")
+	ErrInvalidEscape = errors.New("Invalid escape sequence.")
+	ErrInvalidIDN    = errors.New("Invalid internationalized domain name.")
+
+	ErrNotReverseName = errors.New("Not a reverse lookup name.")
+
+	ErrNoServers          = errors.New("No name servers configured.")
+	ErrUnexpectedResponse = errors.New("Response does not match the query.")
+
+	// ErrNXDomain reports that the name does not exist.
+	ErrNXDomain = errors.New("No such domain.")
+
+	// ErrNoData reports that the name exists but has no records of the
+	// requested type.
+	ErrNoData = errors.New("No records of the requested type.")
+
+	// ErrServerFailure reports that the name servers could not answer.
+	ErrServerFailure = errors.New("Server failure.")
+
+	// ErrMaxDepth reports that resolving name server addresses nested too
+	// deeply.
+	ErrMaxDepth = errors.New("Maximum resolution depth exceeded.")
+
+	// ErrMaxQueries reports that the query budget of a resolution is used
+	// up.
+	ErrMaxQueries = errors.New("Maximum number of queries exceeded.")
+
+	// ErrCNAMEChain reports a CNAME chain longer than allowed.
+	ErrCNAMEChain = errors.New("CNAME chain too long.")
+
+	// ErrCaseMismatch reports that a response did not echo the query name with
+	// the case it was sent with (see Randomize0x20).
+	ErrCaseMismatch = errors.New("Response does not echo the case of the query name.")
+
+	// ErrQueryDenied is returned by Router for queries to local zones of type
+	// LocalDeny. Servers should drop such queries without a response.
+	ErrQueryDenied = errors.New("Query denied.")
+
+	// ErrServerClosed is returned by the Serve methods after Close.
+	ErrServerClosed = errors.New("Server closed.")
+
+	// ErrNoHandler is returned by the Serve methods of a Server without
+	// a Handler.
+	ErrNoHandler = errors.New("No handler configured.")
+
+	// ErrNotInZone is returned for records that do not belong to a zone.
+	ErrNotInZone = errors.New("Record is not in the zone.")
+
+	// ErrNotBelowDNAME is returned by SynthesizeCNAME for names that a DNAME
+	// record does not redirect.
+	ErrNotBelowDNAME = errors.New("Name is not below the DNAME owner.")
+
+	// ErrBadTransfer is returned for zone transfers that do not start and end
+	// with the SOA record of the zone.
+	ErrBadTransfer = errors.New("Invalid zone transfer.")
+
+	// ErrDeltaMismatch is returned for deltas that do not start at the current
+	// version of a zone.
+	ErrDeltaMismatch = errors.New("Delta does not apply to the zone version.")
+
+	// ErrZoneExpired is returned by a Secondary that has not been able to
+	// refresh its zone within the expire interval of the SOA record.
+	ErrZoneExpired = errors.New("Zone has expired.")
+
+	// ErrSerialBehind is returned by a Secondary whose zone transfer did
+	// not reach the serial of the primary.
+	ErrSerialBehind = errors.New("Zone serial is behind the primary.")
+)
+
+// Section identifies the part of a message a ParseError refers to.
+type Section int
+
+const (
+	// The section is not known, e.g. when a single record is parsed.
+	SectionUnknown Section = iota
+	SectionHeader
+	SectionQuestion
+	SectionAnswer
+	SectionAuthority
+	SectionAdditional
 )
+
+var sectionNames = [...]string{
+	SectionUnknown:    "unknown",
+	SectionHeader:     "header",
+	SectionQuestion:   "question",
+	SectionAnswer:     "answer",
+	SectionAuthority:  "authority",
+	SectionAdditional: "additional",
+}
+
+func (s Section) String() string {
+	if s < 0 || int(s) >= len(sectionNames) {
+		return fmt.Sprintf("section %d", int(s))
+	}
+	return sectionNames[s]
+}
+
+// ParseError describes where and why a message could not be decoded. It
+// wraps ErrInvalidFormat, so errors.Is(err, ErrInvalidFormat) holds for
+// every ParseError.
+type ParseError struct {
+	// Offset is the position of the offending byte from the start of the
+	// message.
+	Offset int
+
+	// Section is the message section that was being decoded.
+	Section Section
+
+	// Index is the zero-based position of the record within Section or -1
+	// if the error is not tied to a record.
+	Index int
+
+	// Reason is a short description of the problem.
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("Invalid Format: %s at offset %d (%s).", e.Reason, e.Offset, e.Section)
+	}
+	return fmt.Sprintf("Invalid Format: %s at offset %d (%s #%d).", e.Reason, e.Offset, e.Section, e.Index)
+}
+
+// Unwrap returns ErrInvalidFormat.
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidFormat
+}
+
+func newParseError(offset int, section Section, reason string) *ParseError {
+	return &ParseError{Offset: offset, Section: section, Index: -1, Reason: reason}
+}
+
+// withContext fills in the section and record index of err if it is a
+// ParseError.
+func withContext(err error, section Section, index int) error {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.Section = section
+		perr.Index = index
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Exchanger sends a query and returns the response. Resolver, Upstream
+// and HTTPSClient implement it, so code written against Exchanger works
+// with every transport.
+type Exchanger interface {
+	Exchange(ctx context.Context, q *Message) (*Message, error)
+}
+
+// Upstream sends queries to a single server with Client.
+type Upstream struct {
+	Client *Client
+
+	// Addr is the server as host with optional port.
+	Addr string
+}
+
+// Exchange sends q to the server.
+func (u *Upstream) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	return u.Client.Exchange(ctx, q, u.Addr)
+}
+
+func (u *Upstream) String() string {
+	scheme := u.Client.Net
+	switch scheme {
+	case "":
+		scheme = "udp"
+	case "tcp-tls":
+		scheme = "tls"
+	}
+	return scheme + "://" + u.Addr
+}
+
+// NewUpstream returns an Exchanger for spec, which is a server address
+// with an optional scheme: "192.0.2.53" and "udp://192.0.2.53:5353" use
+// UDP, "tcp://192.0.2.53" uses TCP, "tls://dns.example" uses DNS over TLS
+// and "https://dns.example/dns-query" uses DNS over HTTPS.
+func NewUpstream(spec string) (Exchanger, error) {
+	scheme, addr, ok := strings.Cut(spec, "://")
+	if !ok {
+		scheme, addr = "udp", spec
+	}
+
+	switch scheme {
+	case "udp":
+		return &Upstream{Client: &Client{}, Addr: addr}, nil
+	case "tcp":
+		return &Upstream{Client: &Client{Net: "tcp"}, Addr: addr}, nil
+	case "tls":
+		return &Upstream{Client: &Client{Net: "tcp-tls"}, Addr: addr}, nil
+	case "https":
+		if _, err := url.Parse(spec); err != nil {
+			return nil, err
+		}
+		return &HTTPSClient{URL: spec}, nil
+	}
+	return nil, ErrNotImplemented
+}
@@ -0,0 +1,120 @@
+package dns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewUpstream(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		want string
+	}{
+		{"192.0.2.53", "udp://192.0.2.53"},
+		{"udp://192.0.2.53:5353", "udp://192.0.2.53:5353"},
+		{"tcp://[2001:db8::53]:53", "tcp://[2001:db8::53]:53"},
+		{"tls://dns.example", "tls://dns.example"},
+		{"https://dns.example/dns-query", "https://dns.example/dns-query"},
+	} {
+		ex, err := NewUpstream(tt.spec)
+		if err != nil {
+			t.Errorf("NewUpstream(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := ex.(interface{ String() string }).String(); got != tt.want {
+			t.Errorf("NewUpstream(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+
+	if _, err := NewUpstream("quic://dns.example"); err == nil {
+		t.Error("Expected an error for an unsupported scheme.")
+	}
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.example"},
+		DNSNames:     []string{"dns.example"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestClientExchangeTLS(t *testing.T) {
+	cert, pool := testCertificate(t)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				b, err := ReadTCPMessage(conn)
+				if err != nil {
+					return
+				}
+				q, err := ReadMessage(b)
+				if err != nil {
+					return
+				}
+				WriteTCPMessage(conn, testLookupZone.handle(q, "tcp-tls").Encode())
+			}()
+		}
+	}()
+
+	up := &Upstream{
+		Client: &Client{Net: "tcp-tls", TLSConfig: &tls.Config{RootCAs: pool}, Timeout: time.Second},
+		Addr:   l.Addr().String(),
+	}
+	q, _ := NewQuery("git.noteip.de", TypeAAAA, ClassIN)
+	resp, err := up.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Type != TypeAAAA {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+
+	up.Client.TLSConfig = nil
+	if _, err := up.Exchange(context.Background(), q); err == nil {
+		t.Fatal("Expected an untrusted certificate to fail.")
+	}
+}
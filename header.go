@@ -3,6 +3,7 @@ package dns
 import (
 	"crypto/rand"
 	"math/big"
+	"strconv"
 )
 
 const (
@@ -12,39 +13,50 @@ const (
 	flagTruncation            = uint16(1 << 9)
 	flagRecursionDesired      = uint16(1 << 8)
 	flagRecursionAvailable    = uint16(1 << 7)
+	flagZ                     = uint16(1 << 6)
 	flagResponseCodeBits      = 4
 	flagResponseCodePosition  = 0
 )
 
+// Opcode is the kind of query carried in a message header.
+type Opcode uint16
+
 const (
 	// A standard query
-	OpcodeQuery = 0
+	OpcodeQuery Opcode = 0
 	// An inverse query; now obsolete. RFC 1035 defines the inverse query
 	// as an optional method for performing inverse DNS lookups, that is,
 	// finding a name from an IP address. Due to implementation difficulties,
-	// the method was never widely deployed, however, in favor of reverse 
+	// the method was never widely deployed, however, in favor of reverse
 	// mapping using IN-ADDR.ARPA domain. Use of this Opcode value was formally
 	// obsoleted in RFC 3425 (Nov 2002).
-	OpcodeInverseQuery = 1
+	OpcodeInverseQuery Opcode = 1
 
 	// A server status request.
-	OpcodeStatus = 2
+	OpcodeStatus Opcode = 2
 
 	// Opcode 3 is reserved and not used!
 
 	// A special message type added by RFC 1996. It is used by a primary
 	// (master, authoritative) server to tell secondary servers that data
 	// for a zone has changed and prompt them to request a zone transfer.
-	OpcodeNotify = 4
+	OpcodeNotify Opcode = 4
 
 	// A special message type added by RFC 2136 to implement "dynamic DNS".
 	// It allows resource records to be added, deleted or updated selectively.
-	OpcodeUpdate = 5
+	OpcodeUpdate Opcode = 5
+
+	// DNS Stateful Operations as defined in RFC 8490.
+	OpcodeDSO Opcode = 6
 )
 
+// Rcode is a response code. Only the lower four bits fit into the header,
+// the extended values are carried in EDNS and TSIG records.
+type Rcode uint16
+
 const (
 	// No error occured.
-	RCodeNoError = iota
+	RCodeNoError Rcode = iota
 
 	// The server was unable to respond to the query due to a problem with
 	// how it was constructed.
@@ -55,7 +67,7 @@ const (
 	RCodeServerFailure
 
 	// The name specified in the query does not exist in the domain. This
-	// code can be used by an authoritative server for a zone (since it 
+	// code can be used by an authoritative server for a zone (since it
 	// knows all the objects and subdomains in a domain) or by a chaching
 	// server that implements negative caching.
 	RCodeNameError
@@ -78,21 +90,128 @@ const (
 	// A resource record set that should exist does not.
 	RCodeNXRRSet
 
-	// The server receiving the query is not authoritative for the zone 
+	// The server receiving the query is not authoritative for the zone
 	// specified.
 	RCodeNotAuth
 
 	// A name specified in the message is not within the zone specified
 	// in the message.
 	RCodeNotZone
+
+	// The DSO-TYPE is not implemented by the server (RFC 8490).
+	RCodeDSOTypeNI
+)
+
+const (
+	// The EDNS version is not supported (RFC 6891). TSIG uses the same
+	// value to signal a bad signature (BADSIG).
+	RCodeBadVers Rcode = 16 + iota
+
+	// The TSIG key is not recognized.
+	RCodeBadKey
+
+	// The TSIG signature is outside of the time window.
+	RCodeBadTime
+
+	// The TKEY mode is bad.
+	RCodeBadMode
+
+	// The TKEY name is duplicated.
+	RCodeBadName
+
+	// The TKEY algorithm is not supported.
+	RCodeBadAlg
+
+	// The TSIG MAC is truncated.
+	RCodeBadTrunc
+
+	// The server cookie is bad or missing (RFC 7873).
+	RCodeBadCookie
+)
+
+var opcodeNames = map[Opcode]string{
+	OpcodeQuery:        "QUERY",
+	OpcodeInverseQuery: "IQUERY",
+	OpcodeStatus:       "STATUS",
+	OpcodeNotify:       "NOTIFY",
+	OpcodeUpdate:       "UPDATE",
+	OpcodeDSO:          "DSO",
+}
+
+var rcodeNames = map[Rcode]string{
+	RCodeNoError:        "NOERROR",
+	RCodeFormatError:    "FORMERR",
+	RCodeServerFailure:  "SERVFAIL",
+	RCodeNameError:      "NXDOMAIN",
+	RCodeNotImplemented: "NOTIMP",
+	RCodeRefused:        "REFUSED",
+	RCodeYXDomain:       "YXDOMAIN",
+	RCodeYXRRSet:        "YXRRSET",
+	RCodeNXRRSet:        "NXRRSET",
+	RCodeNotAuth:        "NOTAUTH",
+	RCodeNotZone:        "NOTZONE",
+	RCodeDSOTypeNI:      "DSOTYPENI",
+	RCodeBadVers:        "BADVERS",
+	RCodeBadKey:         "BADKEY",
+	RCodeBadTime:        "BADTIME",
+	RCodeBadMode:        "BADMODE",
+	RCodeBadName:        "BADNAME",
+	RCodeBadAlg:         "BADALG",
+	RCodeBadTrunc:       "BADTRUNC",
+	RCodeBadCookie:      "BADCOOKIE",
+}
+
+var (
+	opcodeValues = invertNames(opcodeNames)
+	rcodeValues  = invertNames(rcodeNames)
 )
 
+func init() {
+	rcodeValues["BADSIG"] = RCodeBadVers
+}
+
+// String returns the mnemonic of op or "OPCODEn" for unassigned values.
+func (op Opcode) String() string {
+	if s, ok := opcodeNames[op]; ok {
+		return s
+	}
+	return "OPCODE" + strconv.Itoa(int(op))
+}
+
+// ParseOpcode converts a mnemonic such as "NOTIFY" or the generic form
+// "OPCODE3" to an Opcode.
+func ParseOpcode(s string) (Opcode, error) {
+	op, err := parseMnemonic(s, "OPCODE", opcodeValues)
+	if err == nil && op > 0xF {
+		return 0, ErrValueTooLarge
+	}
+	return op, err
+}
+
+// String returns the mnemonic of rc or "RCODEn" for unassigned values.
+func (rc Rcode) String() string {
+	if s, ok := rcodeNames[rc]; ok {
+		return s
+	}
+	return "RCODE" + strconv.Itoa(int(rc))
+}
+
+// ParseRcode converts a mnemonic such as "NXDOMAIN" or the generic form
+// "RCODE12" to an Rcode.
+func ParseRcode(s string) (Rcode, error) {
+	rc, err := parseMnemonic(s, "RCODE", rcodeValues)
+	if err == nil && rc > 0xFFF {
+		return 0, ErrValueTooLarge
+	}
+	return rc, err
+}
+
 // Header implements the DNS Header field and is always present in a Message.
 // The header includes fields that specify which of the remaining sections
-// are present, and also specify whether the message is a query, inverse 
+// are present, and also specify whether the message is a query, inverse
 // query, completion query or response.
 type Header struct {
-	// ID is a identifier assigned by the program that generates any kind of 
+	// ID is a identifier assigned by the program that generates any kind of
 	// query. This identifier is copied into all replies and can be used by the
 	// requestor to relate replies to outstanding questions.
 	Id uint16
@@ -105,7 +224,7 @@ type Header struct {
 	//             and copied into the response.
 	//   AA      - Authoritative Answer - this bit is valid in responses,
 	//                      and specifies that the responding name server
-	//                      is an authority for the domain name in the 
+	//                      is an authority for the domain name in the
 	//                      corresponding query.
 	//   TC      - Truncation - specifies that this message was truncated
 	//                      due to length greater than 512 characters.
@@ -113,9 +232,9 @@ type Header struct {
 	//                      not in messages sent over virtual circuits (TCP).
 	//   RD      - Recursion Desired - this bit may be set in a query and
 	//                      is copied into the response. If RD is set, it
-	//                      directs the name server to pursue the query 
+	//                      directs the name server to pursue the query
 	//                      recursively. Recursive query support is optional.
-	//   RA      - Recursion Availavle - this bit is set or cleared in a 
+	//   RA      - Recursion Availavle - this bit is set or cleared in a
 	//                      response and denotes whether recursive query
 	//                      support is available by the name server.
 	//   Z       - reserved bit
@@ -161,19 +280,19 @@ func (hdr *Header) IsResponse() bool {
 }
 
 // SetOpcode sets the message OPCODE variable.
-func (hdr *Header) SetOpcode(opcode uint16) error {
+func (hdr *Header) SetOpcode(opcode Opcode) error {
 	if opcode > 0xF {
 		return ErrValueTooLarge
 	}
 
 	opcode &= 0xF
-	hdr.Flags |= opcode << flagOperationCodePosition
+	hdr.Flags |= uint16(opcode) << flagOperationCodePosition
 	return nil
 }
 
 // Opcode returns the kind of the message (opcode).
-func (hdr *Header) Opcode() uint16 {
-	return (hdr.Flags << 1) >> flagOperationCodePosition
+func (hdr *Header) Opcode() Opcode {
+	return Opcode((hdr.Flags << 1) >> flagOperationCodePosition)
 }
 
 // SetAuthoritativeAnswer sets the Authoritative-Answer flag.
@@ -224,19 +343,19 @@ func (hdr *Header) IsRecursionAvailable() bool {
 }
 
 // SetResponseCode sets the message Response Code.
-func (hdr *Header) SetResponseCode(responseCode uint16) error {
+func (hdr *Header) SetResponseCode(responseCode Rcode) error {
 	if responseCode > 0xF {
 		return ErrValueTooLarge
 	}
 
 	responseCode &= 0xF
-	hdr.Flags |= responseCode
+	hdr.Flags |= uint16(responseCode)
 	return nil
 }
 
 // ResponseCode is set as part of responses.
-func (hdr *Header) ResponseCode() uint16 {
-	return (hdr.Flags & 0xF)
+func (hdr *Header) ResponseCode() Rcode {
+	return Rcode(hdr.Flags & 0xF)
 }
 
 // Encode converts the Header object to the wire format.
@@ -271,7 +390,7 @@ func ReadHeader(b []byte) (*Header, error) {
 	hdr := new(Header)
 
 	if len(b) < 12 {
-		return nil, ErrInvalidFormat
+		return nil, newParseError(len(b), SectionHeader, "truncated header")
 	}
 
 	hdr.Id = byteToUint16(b[0:2])
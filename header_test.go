@@ -172,3 +172,42 @@ func TestHeaderFlags(t *testing.T) {
 		t.Fatal("ResponseCode should be 'No Error (0)'")
 	}
 }
+
+func TestOpcodeString(t *testing.T) {
+	if OpcodeNotify.String() != "NOTIFY" {
+		t.Fatalf("Expected 'NOTIFY' but got '%s'", OpcodeNotify)
+	}
+
+	if Opcode(3).String() != "OPCODE3" {
+		t.Fatalf("Expected 'OPCODE3' but got '%s'", Opcode(3))
+	}
+
+	op, err := ParseOpcode("update")
+	if err != nil || op != OpcodeUpdate {
+		t.Fatalf("ParseOpcode should return UPDATE but got %s (%v)", op, err)
+	}
+
+	if _, err := ParseOpcode("OPCODE16"); err != ErrValueTooLarge {
+		t.Fatalf("Opcode 16 should be too large but got %v", err)
+	}
+}
+
+func TestRcodeString(t *testing.T) {
+	if RCodeNameError.String() != "NXDOMAIN" {
+		t.Fatalf("Expected 'NXDOMAIN' but got '%s'", RCodeNameError)
+	}
+
+	if RCodeBadCookie.String() != "BADCOOKIE" {
+		t.Fatalf("Expected 'BADCOOKIE' but got '%s'", RCodeBadCookie)
+	}
+
+	rc, err := ParseRcode("RCODE12")
+	if err != nil || rc != 12 {
+		t.Fatalf("ParseRcode should return 12 but got %d (%v)", rc, err)
+	}
+
+	rc, err = ParseRcode("BADSIG")
+	if err != nil || rc != RCodeBadVers {
+		t.Fatalf("ParseRcode should return 16 but got %d (%v)", rc, err)
+	}
+}
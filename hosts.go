@@ -0,0 +1,216 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHostsFile is the location of the system hosts file.
+const DefaultHostsFile = "/etc/hosts"
+
+// Hosts answers lookups from a file in the hosts(5) format. The file is
+// read on the first lookup and read again whenever its modification time
+// or size changes. Hosts is safe for concurrent use.
+type Hosts struct {
+	// Path is the location of the file.
+	Path string
+
+	// CheckInterval limits how often the file is checked for changes. The
+	// file is checked on every lookup if it is zero.
+	CheckInterval time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	modTime   time.Time
+	size      int64
+	loaded    bool
+	byName    map[DNSName][]netip.Addr
+	byAddr    map[netip.Addr][]DNSName
+}
+
+// NewHosts returns Hosts for the file at path.
+func NewHosts(path string) *Hosts {
+	return &Hosts{Path: path}
+}
+
+// LookupHost returns the addresses listed for name. The comparison is
+// case-insensitive and a trailing dot is ignored.
+func (h *Hosts) LookupHost(name string) []netip.Addr {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.reload()
+	return append([]netip.Addr(nil), h.byName[DNSName(name).Canonical()]...)
+}
+
+// LookupAddr returns the names listed for addr. The first name is the
+// canonical name of the host, the others are aliases.
+func (h *Hosts) LookupAddr(addr netip.Addr) []DNSName {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.reload()
+	return append([]DNSName(nil), h.byAddr[addr.Unmap()]...)
+}
+
+// reload reads the file if it changed since the last time. A missing or
+// unreadable file results in empty tables.
+func (h *Hosts) reload() {
+	now := time.Now()
+	if h.loaded && h.CheckInterval > 0 && now.Sub(h.lastCheck) < h.CheckInterval {
+		return
+	}
+	h.lastCheck = now
+
+	fi, err := os.Stat(h.Path)
+	if err != nil {
+		h.byName, h.byAddr = nil, nil
+		h.modTime, h.size = time.Time{}, 0
+		h.loaded = true
+		return
+	}
+	if h.loaded && fi.ModTime().Equal(h.modTime) && fi.Size() == h.size {
+		return
+	}
+
+	f, err := os.Open(h.Path)
+	if err != nil {
+		h.byName, h.byAddr = nil, nil
+		h.loaded = true
+		return
+	}
+	defer f.Close()
+
+	h.byName, h.byAddr, err = ParseHosts(f)
+	if err != nil {
+		// Try again on the next lookup.
+		h.loaded = false
+		return
+	}
+	h.modTime, h.size = fi.ModTime(), fi.Size()
+	h.loaded = true
+}
+
+// ParseHosts reads a file in the hosts(5) format and returns the forward
+// and reverse tables. Names are stored in canonical form. Lines with
+// invalid addresses or names are skipped.
+func ParseHosts(r io.Reader) (byName map[DNSName][]netip.Addr, byAddr map[netip.Addr][]DNSName, err error) {
+	byName = make(map[DNSName][]netip.Addr)
+	byAddr = make(map[netip.Addr][]DNSName)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+
+		for _, field := range fields[1:] {
+			name := DNSName(field)
+			if name.IsRoot() || name.Validate() != nil {
+				continue
+			}
+			key := name.Canonical()
+			if !containsAddr(byName[key], addr) {
+				byName[key] = append(byName[key], addr)
+			}
+			if !containsName(byAddr[addr], key) {
+				byAddr[addr] = append(byAddr[addr], key)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return byName, byAddr, nil
+}
+
+func containsAddr(addrs []netip.Addr, addr netip.Addr) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func containsName(names []DNSName, name DNSName) bool {
+	for _, n := range names {
+		if n.Equal(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Source is a source of host information consulted by Resolver.
+type Source int
+
+const (
+	// SourceFiles is the hosts file.
+	SourceFiles Source = iota
+
+	// SourceDNS are the name servers.
+	SourceDNS
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceFiles:
+		return "files"
+	case SourceDNS:
+		return "dns"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// ParseLookupOrder parses an order such as "files dns" or the hosts line
+// of nsswitch.conf(5), "hosts: dns files". Actions in brackets and
+// unsupported sources such as "mdns4_minimal" are skipped.
+func ParseLookupOrder(s string) ([]Source, error) {
+	if key, rest, ok := strings.Cut(s, ":"); ok {
+		if strings.TrimSpace(key) != "hosts" {
+			return nil, ErrInvalidFormat
+		}
+		s = rest
+	}
+
+	var order []Source
+	inAction := false
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "[") {
+			inAction = true
+		}
+		if inAction {
+			inAction = !strings.HasSuffix(field, "]")
+			continue
+		}
+
+		switch field {
+		case "files":
+			order = append(order, SourceFiles)
+		case "dns":
+			order = append(order, SourceDNS)
+		}
+	}
+	if len(order) == 0 {
+		return nil, ErrInvalidFormat
+	}
+	return order, nil
+}
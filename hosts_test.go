@@ -0,0 +1,138 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHostsFile = `# static entries
+127.0.0.1	localhost
+::1		localhost ip6-localhost
+192.0.2.10	dev.noteip.de dev	# development box
+192.0.2.11	Build.NoteIP.de.
+not-an-ip	broken.noteip.de
+fe80::1%lo	link.noteip.de
+`
+
+func TestParseHosts(t *testing.T) {
+	byName, byAddr, err := ParseHosts(strings.NewReader(testHostsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addrs := byName["localhost"]; len(addrs) != 2 {
+		t.Fatalf("localhost should have 2 addresses but got %v", addrs)
+	}
+
+	if addrs := byName["build.noteip.de"]; len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.11") {
+		t.Fatalf("Unexpected addresses %v", addrs)
+	}
+
+	if _, ok := byName["broken.noteip.de"]; ok {
+		t.Fatal("Lines with invalid addresses should be skipped.")
+	}
+
+	names := byAddr[netip.MustParseAddr("192.0.2.10")]
+	if len(names) != 2 || names[0] != "dev.noteip.de" || names[1] != "dev" {
+		t.Fatalf("Unexpected names %q", names)
+	}
+
+	if addrs := byName["link.noteip.de"]; len(addrs) != 1 || addrs[0].Zone() != "lo" {
+		t.Fatalf("Zones should be kept but got %v", addrs)
+	}
+}
+
+func TestHostsReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("192.0.2.10 dev.noteip.de\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHosts(path)
+	if addrs := h.LookupHost("DEV.noteip.de."); len(addrs) != 1 {
+		t.Fatalf("Expected one address but got %v", addrs)
+	}
+
+	if err := os.WriteFile(path, []byte("192.0.2.20 dev.noteip.de\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time changes on coarse file systems.
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if addrs := h.LookupHost("dev.noteip.de"); len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.20") {
+		t.Fatalf("File should have been reloaded but got %v", addrs)
+	}
+
+	if names := h.LookupAddr(netip.MustParseAddr("192.0.2.20")); len(names) != 1 || names[0] != "dev.noteip.de" {
+		t.Fatalf("Unexpected names %q", names)
+	}
+
+	os.Remove(path)
+	if addrs := h.LookupHost("dev.noteip.de"); len(addrs) != 0 {
+		t.Fatalf("Removed file should yield no addresses but got %v", addrs)
+	}
+}
+
+func TestParseLookupOrder(t *testing.T) {
+	tests := map[string][]Source{
+		"files dns": {SourceFiles, SourceDNS},
+		"dns files": {SourceDNS, SourceFiles},
+		"hosts: files mdns4_minimal [NOTFOUND=return] dns": {SourceFiles, SourceDNS},
+	}
+
+	for s, expected := range tests {
+		order, err := ParseLookupOrder(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(order) != len(expected) || order[0] != expected[0] || order[1] != expected[1] {
+			t.Fatalf("ParseLookupOrder(%q) should be %v but got %v", s, expected, order)
+		}
+	}
+
+	for _, s := range []string{"", "networks: files", "mdns"} {
+		if _, err := ParseLookupOrder(s); err == nil {
+			t.Fatalf("ParseLookupOrder(%q) should fail.", s)
+		}
+	}
+}
+
+func TestResolverHostsOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("10.0.0.1 git.noteip.de\n10.0.0.2 local.noteip.de\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestResolver(t, testLookupZone)
+	r.Hosts = NewHosts(path)
+
+	addrs, err := r.LookupHost(context.Background(), "git.noteip.de")
+	if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("10.0.0.1") {
+		t.Fatalf("Hosts file should override DNS but got %v (%v)", addrs, err)
+	}
+
+	r.Order = []Source{SourceDNS, SourceFiles}
+	addrs, err = r.LookupHost(context.Background(), "git.noteip.de")
+	if err != nil || len(addrs) != 2 {
+		t.Fatalf("DNS should be consulted first but got %v (%v)", addrs, err)
+	}
+
+	// Not in DNS, so the hosts file is consulted afterwards.
+	addrs, err = r.LookupHost(context.Background(), "local.noteip.de.")
+	if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("10.0.0.2") {
+		t.Fatalf("Hosts file should be the fallback but got %v (%v)", addrs, err)
+	}
+
+	names, err := r.LookupAddr(context.Background(), netip.MustParseAddr("10.0.0.2"))
+	if err != nil || len(names) != 1 || names[0] != "local.noteip.de" {
+		t.Fatalf("Unexpected names %q (%v)", names, err)
+	}
+}
@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MIME type of DNS messages in DNS over HTTPS.
+const dnsMessageType = "application/dns-message"
+
+// HTTPSClient sends queries as DNS over HTTPS POST requests (RFC 8484).
+type HTTPSClient struct {
+	// URL is the URI template without variables, e.g.
+	// "https://dns.example/dns-query".
+	URL string
+
+	// Client is used for the requests. http.DefaultClient is used if it is
+	// nil.
+	Client *http.Client
+}
+
+// Exchange sends q to the server. The Id is sent as 0 to make responses
+// cacheable as recommended by RFC 8484 and restored in the response.
+func (c *HTTPSClient) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	hdr := *q.Header
+	hdr.Id = 0
+	wire := *q
+	wire.Header = &hdr
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(wire.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS request failed: %s", httpResp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(httpResp.Body, maxMessageLen+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxMessageLen {
+		return nil, ErrValueTooLarge
+	}
+
+	resp, err := ReadMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	if !IsResponseTo(&wire, resp) {
+		return nil, ErrUnexpectedResponse
+	}
+	resp.Header.Id = q.Header.Id
+	return resp, nil
+}
+
+func (c *HTTPSClient) String() string {
+	return c.URL
+}
@@ -0,0 +1,44 @@
+package dns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSClientExchange(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		q, err := ReadMessage(b)
+		if err != nil || q.Header.Id != 0 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dnsMessageType)
+		w.Write(testLookupZone.handle(q, "https").Encode())
+	}))
+	defer srv.Close()
+
+	c := &HTTPSClient{URL: srv.URL + "/dns-query", Client: srv.Client()}
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	q.Header.Id = 4711
+	resp, err := c.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Id != 4711 || len(resp.Answer) != 1 {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+
+	c.URL = srv.URL + "/missing"
+	srv.Config.Handler = http.NotFoundHandler()
+	if _, err := c.Exchange(context.Background(), q); err == nil {
+		t.Fatal("Expected an error for a failed HTTP request.")
+	}
+}
@@ -0,0 +1,346 @@
+package dns
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The IDNA support implements the UTS #46 non-transitional mapping and the
+// IDNA 2008 label validation (RFC 5891, RFC 5892) as far as it is possible
+// with the Unicode tables of the standard library. Input is expected to be
+// in Normalization Form C and the Bidi rule of RFC 5893 is not checked.
+
+const acePrefix = "xn--"
+
+// ToASCII converts a domain name that may contain Unicode labels to its
+// A-label form, e.g. "Bücher.noteip.de" to "xn--bcher-kva.noteip.de".
+// Labels are mapped according to UTS #46 (case folding, full-width forms,
+// ideographic full stops, removal of ignored code points) and validated
+// against IDNA 2008 before they are punycode encoded.
+func ToASCII(s string) (DNSName, error) {
+	s = mapIDNA(s)
+	if s == "" || s == "." {
+		return RootName, nil
+	}
+
+	labels, err := DNSName(s).splitLabels()
+	if err != nil {
+		return "", err
+	}
+	for i, l := range labels {
+		label := string(l)
+		if isASCII(label) {
+			if hasACEPrefix(label) {
+				// Existing A-labels have to decode to a valid U-label.
+				if _, err := decodeALabel(label); err != nil {
+					return "", err
+				}
+			} else if err := validateASCIILabel(label); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		if err := validateULabel(label); err != nil {
+			return "", err
+		}
+		enc, err := punycodeEncode(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = []byte(acePrefix + enc)
+	}
+
+	return ParseDNSName(string(nameFromLabels(labels)))
+}
+
+// ToUnicode converts the A-labels of name to Unicode. Labels that are not
+// A-labels are returned in presentation format. If an A-label is invalid
+// an error is returned together with name itself.
+func (name DNSName) ToUnicode() (string, error) {
+	labels := name.Labels()
+	if len(labels) == 0 {
+		return string(RootName), nil
+	}
+
+	for i, label := range labels {
+		if !hasACEPrefix(label) {
+			continue
+		}
+		u, err := decodeALabel(label)
+		if err != nil {
+			return string(name), err
+		}
+		labels[i] = u
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// decodeALabel returns the U-label of the A-label label. The U-label has
+// to be valid, contain non-ASCII characters and encode to label again
+// (UTS #46 section 4.1, step 4).
+func decodeALabel(label string) (string, error) {
+	puny := label[len(acePrefix):]
+	u, err := punycodeDecode(puny)
+	if err != nil {
+		return "", err
+	}
+	if isASCII(u) {
+		return "", ErrInvalidIDN
+	}
+	if enc, err := punycodeEncode(u); err != nil || enc != strings.ToLower(puny) {
+		return "", ErrInvalidIDN
+	}
+	if err := validateULabel(u); err != nil {
+		return "", err
+	}
+	return u, nil
+}
+
+func hasACEPrefix(label string) bool {
+	return len(label) >= len(acePrefix) && strings.EqualFold(label[:len(acePrefix)], acePrefix)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// mapIDNA applies the UTS #46 mapping step.
+func mapIDNA(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u3002' || r == '\uFF0E' || r == '\uFF61':
+			// Ideographic and full-width full stops are label separators.
+			return '.'
+		case r >= '\uFF01' && r <= '\uFF5E':
+			// Full-width ASCII variants.
+			return unicode.ToLower(r - 0xFEE0)
+		case r == '\u00AD' || r == '\u034F' || r == '\u180B' || r == '\u180C' ||
+			r == '\u180D' || r == '\u200B' || r == '\u2060' || r == '\uFEFF' ||
+			(r >= '\uFE00' && r <= '\uFE0F'):
+			// Code points that are ignored.
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// validateASCIILabel checks the hyphen restrictions of RFC 5891 for
+// labels that contain no Unicode. Other characters such as "_" are
+// allowed to keep service labels working.
+func validateASCIILabel(label string) error {
+	if len(label) >= 4 && label[2:4] == "--" {
+		return ErrInvalidIDN
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return ErrInvalidIDN
+	}
+	return nil
+}
+
+// validateULabel checks a label against the IDNA 2008 rules.
+func validateULabel(label string) error {
+	if label == "" {
+		return ErrEmptyLabel
+	}
+	if err := validateASCIILabel(label); err != nil {
+		return err
+	}
+	if mapIDNA(label) != label {
+		// U-labels have to be stable under the mapping.
+		return ErrInvalidIDN
+	}
+
+	for i, r := range label {
+		if i == 0 && unicode.Is(unicode.M, r) {
+			// A label must not start with a combining mark.
+			return ErrInvalidIDN
+		}
+		if !isPValid(r) {
+			return ErrInvalidIDN
+		}
+	}
+	return nil
+}
+
+// isPValid approximates the PVALID category of RFC 5892: lower-case
+// letters, letters without case, marks, decimal digits and the hyphen.
+func isPValid(r rune) bool {
+	switch {
+	case r == '-':
+		return true
+	case r < utf8.RuneSelf:
+		return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+	case unicode.IsUpper(r) || unicode.IsTitle(r):
+		return false
+	case unicode.In(r, unicode.L, unicode.M, unicode.Nd):
+		return true
+	}
+	return false
+}
+
+// Bootstring parameters for punycode (RFC 3492 section 5).
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+func punyAdapt(delta, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+// punycodeEncode encodes a Unicode label without the ACE prefix.
+func punycodeEncode(s string) (string, error) {
+	runes := []rune(s)
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for h < len(runes) {
+		m := int(unicode.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		if delta < 0 {
+			return "", ErrInvalidIDN
+		}
+		n = m
+
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+// punycodeDecode decodes a label without the ACE prefix.
+func punycodeDecode(s string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		for _, r := range s[:i] {
+			if r >= utf8.RuneSelf {
+				return "", ErrInvalidIDN
+			}
+			output = append(output, r)
+		}
+		pos = i + 1
+	}
+
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for pos < len(s) {
+		oldI, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(s) {
+				return "", ErrInvalidIDN
+			}
+			var digit int
+			switch c := s[pos]; {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				digit = int(c - 'A')
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", ErrInvalidIDN
+			}
+			pos++
+
+			i += digit * w
+			if i < 0 || i > unicode.MaxRune*punyBase {
+				return "", ErrInvalidIDN
+			}
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			if w *= punyBase - t; w > unicode.MaxRune*punyBase {
+				return "", ErrInvalidIDN
+			}
+		}
+		bias = punyAdapt(i-oldI, len(output)+1, oldI == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > unicode.MaxRune || (n >= 0xD800 && n <= 0xDFFF) {
+			return "", ErrInvalidIDN
+		}
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
@@ -0,0 +1,84 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := map[string]DNSName{
+		"bücher.noteip.de":     "xn--bcher-kva.noteip.de",
+		"Bücher.NoteIP.de.":    "xn--bcher-kva.noteip.de",
+		"straße.de":            "xn--strae-oqa.de",
+		"münchen。de":           "xn--mnchen-3ya.de",
+		"ｎｏｔｅｉｐ.de":            "noteip.de",
+		"xn--bcher-kva.de":     "xn--bcher-kva.de",
+		"_sip._tcp.noteip.de":  "_sip._tcp.noteip.de",
+		"日本語.jp":               "xn--wgv71a119e.jp",
+		"soft\u00ADhyphen.de":  "softhyphen.de",
+		".":                    ".",
+		"ex\u200Bample.noteip": "example.noteip",
+		`a\.b.noteip.de`:       `a\.b.noteip.de`,
+	}
+
+	for s, expected := range tests {
+		name, err := ToASCII(s)
+		if err != nil {
+			t.Fatalf("ToASCII(%q) failed: %v", s, err)
+		}
+		if name != expected {
+			t.Fatalf("ToASCII(%q) should be %q but got %q", s, expected, name)
+		}
+	}
+
+	for _, s := range []string{"-bücher.de", "bücher-.de", "ab--cd.de", "\u0301a.de", "a☃b.de", "xn--.de", "xn--zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz.de", "a..de", "xn--abc-.de", "xn---tda.de", `b\.ücher.de`} {
+		if _, err := ToASCII(s); err == nil {
+			t.Fatalf("ToASCII(%q) should fail.", s)
+		}
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := map[DNSName]string{
+		"xn--bcher-kva.noteip.de.": "bücher.noteip.de",
+		"xn--wgv71a119e.jp":        "日本語.jp",
+		"noteip.de":                "noteip.de",
+		`a\.b.de`:                  `a\.b.de`,
+		".":                        ".",
+	}
+
+	for name, expected := range tests {
+		s, err := name.ToUnicode()
+		if err != nil {
+			t.Fatalf("ToUnicode(%q) failed: %v", name, err)
+		}
+		if s != expected {
+			t.Fatalf("ToUnicode(%q) should be %q but got %q", name, expected, s)
+		}
+	}
+
+	if s, err := DNSName("xn--ab--c-.de").ToUnicode(); err == nil || s != "xn--ab--c-.de" {
+		t.Fatalf("ToUnicode should fail but got %q", s)
+	}
+}
+
+func TestPunycodeRoundTrip(t *testing.T) {
+	// Samples from RFC 3492 section 7.1.
+	tests := map[string]string{
+		"ليهمابتكلموشعربي؟":            "egbpdaj6bu4bxfgehfvwxn",
+		"他们为什么不说中文":                    "ihqwcrb4cv8a8dqg056pqjye",
+		"почемужеонинеговорятпорусски": "b1abfaaepdrnnbgefbadotcwatmq2g4l",
+		"3年b組金八先生":                     "3b-ww4c5e180e575a65lsy2b",
+	}
+
+	for u, a := range tests {
+		enc, err := punycodeEncode(u)
+		if err != nil || enc != a {
+			t.Fatalf("punycodeEncode(%q) should be %q but got %q (%v)", u, a, enc, err)
+		}
+
+		dec, err := punycodeDecode(a)
+		if err != nil || dec != u {
+			t.Fatalf("punycodeDecode(%q) should be %q but got %q (%v)", a, u, dec, err)
+		}
+	}
+}
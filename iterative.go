@@ -0,0 +1,613 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxDepth limits the nesting of resolutions started to find
+	// the addresses of name servers.
+	DefaultMaxDepth = 6
+
+	// DefaultMaxQueries limits the number of queries sent to resolve a
+	// single question, including the nested resolutions.
+	DefaultMaxQueries = 128
+
+	// maxMinimiseCount and minimiseOneLab limit the queries spent on QNAME
+	// minimisation (RFC 9156 section 2.3): the first minimiseOneLab steps
+	// add one label each, the remaining labels are spread over at most
+	// maxMinimiseCount steps in total.
+	maxMinimiseCount = 10
+	minimiseOneLab   = 4
+
+	// maxDelegations limits the number of cached delegations and name
+	// server addresses of an IterativeResolver.
+	maxDelegations = DefaultCacheSize
+
+	// maxCaseMismatches is the number of responses in a row that do not
+	// echo the randomized case after which a server is queried without
+	// 0x20.
+	maxCaseMismatches = 3
+)
+
+// rootServers are the root name servers as published by IANA in
+// https://www.internic.net/domain/named.root.
+var rootServers = []struct {
+	name DNSName
+	v4   string
+	v6   string
+}{
+	{"a.root-servers.net", "198.41.0.4", "2001:503:ba3e::2:30"},
+	{"b.root-servers.net", "170.247.170.2", "2801:1b8:10::b"},
+	{"c.root-servers.net", "192.33.4.12", "2001:500:2::c"},
+	{"d.root-servers.net", "199.7.91.13", "2001:500:2d::d"},
+	{"e.root-servers.net", "192.203.230.10", "2001:500:a8::e"},
+	{"f.root-servers.net", "192.5.5.241", "2001:500:2f::f"},
+	{"g.root-servers.net", "192.112.36.4", "2001:500:12::d0d"},
+	{"h.root-servers.net", "198.97.190.53", "2001:500:1::53"},
+	{"i.root-servers.net", "192.36.148.17", "2001:7fe::53"},
+	{"j.root-servers.net", "192.58.128.30", "2001:503:c27::2:30"},
+	{"k.root-servers.net", "193.0.14.129", "2001:7fd::1"},
+	{"l.root-servers.net", "199.7.83.42", "2001:500:9f::42"},
+	{"m.root-servers.net", "202.12.27.33", "2001:dc3::35"},
+}
+
+// RootHints returns the NS records of the root zone and the addresses of
+// the root name servers.
+func RootHints() []*ResourceRecord {
+	const ttl = 3600000
+	var hints []*ResourceRecord
+	for _, s := range rootServers {
+		hints = append(hints,
+			NewResourceRecord(RootName, ClassIN, ttl, &NS{Host: s.name}),
+			NewResourceRecord(s.name, ClassIN, ttl, &A{Addr: netip.MustParseAddr(s.v4)}),
+			NewResourceRecord(s.name, ClassIN, ttl, &AAAA{Addr: netip.MustParseAddr(s.v6)}))
+	}
+	return hints
+}
+
+// IterativeResolver resolves names itself, starting at the root name
+// servers and following referrals (RFC 1034 section 5.3.3). Referrals are
+// the NS records in the authority section of a response with the
+// addresses of the name servers as glue in the additional section; name
+// servers without glue are resolved separately. CNAME chains are followed
+// across zones.
+//
+// Responses have to echo the question and records outside the bailiwick of
+// the queried zone or unrelated to the question are dropped (see
+// FindUnsolicited), so that a server can only answer for its own zone.
+//
+// Query names are minimised as described in RFC 9156: each zone is only
+// asked for the name with one more label than the zone, so that the root
+// and TLD servers do not see full query names. Servers that wrongly answer
+// NXDOMAIN for empty non-terminals or fail on minimised queries are asked
+// for the full name instead.
+//
+// Delegations and name server addresses are remembered for their TTL.
+// Wrap the IterativeResolver in a Cache to cache answers. The zero value
+// is ready to use and safe for concurrent use.
+type IterativeResolver struct {
+	// Client sends the queries. A Client with default settings is used if
+	// it is nil.
+	Client *Client
+
+	// Hints are the NS records of the root zone and the addresses of
+	// their targets. It defaults to RootHints. Hints for another zone make
+	// the IterativeResolver a stub resolver for that zone.
+	Hints []*ResourceRecord
+
+	// Port is the port name servers are queried on. It defaults to 53.
+	Port uint16
+
+	// MaxDepth limits nested resolutions of name server addresses. It
+	// defaults to DefaultMaxDepth.
+	MaxDepth int
+
+	// MaxQueries limits the queries of a resolution. It defaults to
+	// DefaultMaxQueries.
+	MaxQueries int
+
+	// DisableQNAMEMinimisation sends the full query name to every zone.
+	DisableQNAMEMinimisation bool
+
+	// MinimisationType is the type of minimised queries. It defaults to
+	// TypeA, which RFC 9156 section 3 recommends over TypeNS.
+	MinimisationType Type
+
+	// Use0x20 randomizes the case of query names and ignores responses
+	// that do not echo it like forged ones (see Randomize0x20). A server
+	// that did not preserve the case in several responses in a row is
+	// queried without randomization from then on.
+	Use0x20 bool
+
+	mu          sync.Mutex
+	delegations map[DNSName]*delegation
+	addrs       map[DNSName]addrEntry
+
+	// mismatches counts the responses in a row per server address that
+	// did not echo the case of the query name.
+	mismatches map[netip.Addr]int
+}
+
+// delegation is a zone with its name servers.
+type delegation struct {
+	zone    DNSName
+	servers []DNSName
+
+	// glue maps the canonical names of servers to their addresses.
+	glue    map[DNSName][]netip.Addr
+	expires time.Time
+}
+
+// addrEntry holds the resolved addresses of a name server.
+type addrEntry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// resolution is the state of a single call to Resolve.
+type resolution struct {
+	queries    int
+	maxQueries int
+	maxDepth   int
+}
+
+// Exchange resolves the question of q and returns a response to it.
+func (r *IterativeResolver) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	if len(q.Question) != 1 || q.Question[0].Class != ClassIN {
+		return errorResponse(q, RCodeNotImplemented), nil
+	}
+
+	question := q.Question[0]
+	result, err := r.Resolve(ctx, question.Name, question.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := errorResponse(q, result.Header.ResponseCode())
+	resp.Header.SetRecursionAvailable(true)
+	resp.Answer = result.Answer
+	resp.Authority = result.Authority
+	if opt := q.OPT(); opt != nil {
+		resp.SetEDNS0(DefaultEDNSUDPSize, opt.TTL&flagDNSSECOK != 0)
+	}
+	return resp, nil
+}
+
+// Resolve looks up name with type qtype in class IN. The returned message
+// is the final response of the authoritative server with the answer
+// section extended by the CNAME records that led to it.
+func (r *IterativeResolver) Resolve(ctx context.Context, name DNSName, qtype Type) (*Message, error) {
+	res := &resolution{maxQueries: r.MaxQueries, maxDepth: r.MaxDepth}
+	if res.maxQueries <= 0 {
+		res.maxQueries = DefaultMaxQueries
+	}
+	if res.maxDepth <= 0 {
+		res.maxDepth = DefaultMaxDepth
+	}
+	return r.resolve(ctx, name, qtype, 0, res)
+}
+
+func (r *IterativeResolver) resolve(ctx context.Context, name DNSName, qtype Type, depth int, res *resolution) (*Message, error) {
+	if depth > res.maxDepth {
+		return nil, ErrMaxDepth
+	}
+
+	var chain []*ResourceRecord
+	current := name
+	for hops := 0; hops <= maxCNAMEChain; hops++ {
+		resp, err := r.iterate(ctx, current, qtype, depth, res)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, resp.Answer...)
+		records, target, n := followCNAMEs(resp.Answer, current, qtype, hops)
+		hops = n
+		if len(records) > 0 || target.Equal(current) || resp.Header.ResponseCode() != RCodeNoError {
+			resp.Answer = chain
+			return resp, nil
+		}
+		// The answer ends at an alias, its target may be in another zone.
+		current = target
+	}
+	return nil, ErrCNAMEChain
+}
+
+// iterate follows referrals from the closest known delegation of name to
+// a final answer.
+func (r *IterativeResolver) iterate(ctx context.Context, name DNSName, qtype Type, depth int, res *resolution) (*Message, error) {
+	d := r.closestDelegation(name)
+	minimise := !r.DisableQNAMEMinimisation
+
+	// known is the longest ancestor of name that was found to be within
+	// the zone of d.
+	known := d.zone
+	steps := 0
+	for {
+		qname, qt := name, qtype
+		if minimise {
+			if next := minimisedName(name, known, steps); next != "" {
+				qname, qt = next, r.minimisationType()
+				steps++
+			}
+		}
+		minimised := qname != name
+
+		resp, err := r.queryZone(ctx, d, qname, qt, depth, res)
+		if err != nil {
+			if minimised && !isFatalResolution(ctx, err) {
+				// Broken servers may fail on minimised queries.
+				minimise = false
+				continue
+			}
+			return nil, err
+		}
+
+		if next := referral(resp, d.zone, qname); next != nil {
+			r.storeDelegation(next)
+			d, known = next, next.zone
+			continue
+		}
+		if !minimised {
+			return resp, nil
+		}
+		if resp.Header.ResponseCode() == RCodeNameError {
+			// Nothing exists below qname (RFC 8020), but some servers
+			// answer NXDOMAIN for empty non-terminals. Ask for the full
+			// name to be sure (RFC 9156 section 2.3).
+			minimise = false
+			continue
+		}
+		// qname exists in the zone of d without a zone cut.
+		known = qname
+	}
+}
+
+func (r *IterativeResolver) minimisationType() Type {
+	if r.MinimisationType != 0 {
+		return r.MinimisationType
+	}
+	return TypeA
+}
+
+// minimisedName returns the ancestor of name to ask for in the given step
+// of QNAME minimisation, when known is the longest ancestor known to
+// exist. It returns "" if the full name is to be asked for.
+func minimisedName(name, known DNSName, step int) DNSName {
+	labels := name.labels()
+	have := known.CountLabels()
+	remaining := len(labels) - have
+	if remaining <= 1 || step >= maxMinimiseCount-1 {
+		return ""
+	}
+
+	add := 1
+	if step >= minimiseOneLab {
+		add = max(remaining/(maxMinimiseCount-step), 1)
+	}
+	if add >= remaining {
+		return ""
+	}
+	return nameFromLabels(labels[len(labels)-have-add:])
+}
+
+// queryZone asks the name servers of d until one gives a usable response.
+// Servers with known addresses are tried before the addresses of the
+// others are resolved.
+func (r *IterativeResolver) queryZone(ctx context.Context, d *delegation, name DNSName, qtype Type, depth int, res *resolution) (*Message, error) {
+	servers := slices.Clone(d.servers)
+	rand.Shuffle(len(servers), func(i, j int) { servers[i], servers[j] = servers[j], servers[i] })
+
+	tried := make(map[netip.Addr]bool)
+	var lastErr error = ErrServerFailure
+	for pass := 0; pass < 2; pass++ {
+		for _, ns := range servers {
+			addrs := d.glue[ns.Canonical()]
+			if len(addrs) == 0 {
+				addrs = r.cachedAddrs(ns)
+			}
+			if len(addrs) == 0 {
+				if pass == 0 {
+					continue
+				}
+				var err error
+				addrs, err = r.resolveAddrs(ctx, ns, depth+1, res)
+				if err != nil {
+					if isFatalResolution(ctx, err) {
+						return nil, err
+					}
+					lastErr = err
+					continue
+				}
+			}
+
+			for _, addr := range addrs {
+				if tried[addr] {
+					continue
+				}
+				tried[addr] = true
+
+				resp, err := r.send(ctx, addr, name, qtype, res)
+				if err != nil {
+					if isFatalResolution(ctx, err) {
+						return nil, err
+					}
+					lastErr = err
+					continue
+				}
+				if rcode := resp.Header.ResponseCode(); rcode != RCodeNoError && rcode != RCodeNameError {
+					lastErr = ErrServerFailure
+					continue
+				}
+				DropUnsolicited(resp, d.zone)
+				if isLame(resp, d.zone, name) {
+					continue
+				}
+				return resp, nil
+			}
+		}
+	}
+	return nil, &LookupError{Name: name, Type: qtype, Rcode: RCodeServerFailure, Err: lastErr}
+}
+
+// isFatalResolution reports whether err ends the whole resolution.
+func isFatalResolution(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, ErrMaxQueries) || errors.Is(err, ErrMaxDepth)
+}
+
+// send queries a single name server without recursion.
+func (r *IterativeResolver) send(ctx context.Context, addr netip.Addr, name DNSName, qtype Type, res *resolution) (*Message, error) {
+	if res.queries >= res.maxQueries {
+		return nil, ErrMaxQueries
+	}
+	res.queries++
+
+	use0x20 := r.use0x20(addr)
+	if use0x20 {
+		name = Randomize0x20(name)
+	}
+	q, err := NewQuery(string(name), qtype, ClassIN)
+	if err != nil {
+		return nil, err
+	}
+	q.Header.SetRecursionDesired(false)
+	q.SetEDNS0(DefaultEDNSUDPSize, false)
+
+	c := r.Client
+	if c == nil {
+		c = &Client{}
+	}
+	verify := matchResponse
+	if use0x20 {
+		verify = VerifyResponse
+	}
+	resp, err := c.exchange(ctx, q, netip.AddrPortFrom(addr, r.port()).String(), verify)
+	if mismatch := errors.Is(err, ErrCaseMismatch); use0x20 && (err == nil || mismatch) {
+		r.noteCase(addr, mismatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// use0x20 reports whether queries to addr get a randomized case.
+func (r *IterativeResolver) use0x20(addr netip.Addr) bool {
+	if !r.Use0x20 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mismatches[addr] < maxCaseMismatches
+}
+
+// noteCase counts a response of addr that did not echo the case of the
+// query name or resets the count after one that did.
+func (r *IterativeResolver) noteCase(addr netip.Addr, mismatch bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !mismatch {
+		delete(r.mismatches, addr)
+		return
+	}
+	if r.mismatches == nil || len(r.mismatches) >= maxDelegations {
+		r.mismatches = make(map[netip.Addr]int)
+	}
+	r.mismatches[addr]++
+}
+
+func (r *IterativeResolver) port() uint16 {
+	if r.Port != 0 {
+		return r.Port
+	}
+	port, _ := strconv.Atoi(DefaultPort)
+	return uint16(port)
+}
+
+// resolveAddrs returns the addresses of the name server ns. IPv6
+// addresses are only looked up if ns has no IPv4 address.
+func (r *IterativeResolver) resolveAddrs(ctx context.Context, ns DNSName, depth int, res *resolution) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	ttl := uint32(0)
+	var lastErr error
+	for _, qtype := range []Type{TypeA, TypeAAAA} {
+		resp, err := r.resolve(ctx, ns, qtype, depth, res)
+		if err != nil {
+			if isFatalResolution(ctx, err) {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+
+		records, _, _ := followCNAMEs(resp.Answer, ns, qtype, 0)
+		for _, rr := range records {
+			if addr, ok := recordAddr(rr); ok {
+				addrs = append(addrs, addr)
+				ttl = rr.TTL
+			}
+		}
+		if len(addrs) > 0 {
+			break
+		}
+	}
+
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = ErrNoData
+		}
+		return nil, &LookupError{Name: ns, Type: TypeA, Err: lastErr}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.addrs == nil || len(r.addrs) >= maxDelegations {
+		r.addrs = make(map[DNSName]addrEntry)
+	}
+	r.addrs[ns.Canonical()] = addrEntry{addrs: addrs, expires: time.Now().Add(capTTL(ttl))}
+	return addrs, nil
+}
+
+// recordAddr returns the address of an A or AAAA record.
+func recordAddr(rr *ResourceRecord) (netip.Addr, bool) {
+	if rr.Type != TypeA && rr.Type != TypeAAAA {
+		return netip.Addr{}, false
+	}
+	data, err := rr.Rdata()
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	switch data := data.(type) {
+	case *A:
+		return data.Addr, true
+	case *AAAA:
+		return data.Addr, true
+	}
+	return netip.Addr{}, false
+}
+
+func capTTL(ttl uint32) time.Duration {
+	return min(time.Duration(ttl)*time.Second, DefaultMaxTTL)
+}
+
+func (r *IterativeResolver) cachedAddrs(ns DNSName) []netip.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.addrs[ns.Canonical()]
+	if !ok || !time.Now().Before(e.expires) {
+		return nil
+	}
+	return e.addrs
+}
+
+// closestDelegation returns the cached delegation closest to name or the
+// delegation of the hints.
+func (r *IterativeResolver) closestDelegation(name DNSName) *delegation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for zone := name.Canonical(); !zone.IsRoot(); zone = zone.Parent() {
+		if d, ok := r.delegations[zone]; ok && now.Before(d.expires) {
+			return d
+		}
+	}
+
+	hints := r.Hints
+	if hints == nil {
+		hints = RootHints()
+	}
+	zone := RootName
+	for _, rr := range hints {
+		if rr.Type == TypeNS {
+			zone = rr.Name
+			break
+		}
+	}
+	return newDelegation(zone, hints, hints)
+}
+
+func (r *IterativeResolver) storeDelegation(d *delegation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.delegations == nil || len(r.delegations) >= maxDelegations {
+		r.delegations = make(map[DNSName]*delegation)
+	}
+	r.delegations[d.zone.Canonical()] = d
+}
+
+// newDelegation builds the delegation of zone from the NS records in
+// authority and the address records of their targets in additional.
+func newDelegation(zone DNSName, authority, additional []*ResourceRecord) *delegation {
+	d := &delegation{zone: zone, glue: make(map[DNSName][]netip.Addr)}
+	ttl := uint32(0)
+	for _, rr := range authority {
+		if rr.Type != TypeNS || !rr.Name.Equal(zone) {
+			continue
+		}
+		data, err := rr.Rdata()
+		if err != nil {
+			continue
+		}
+		if len(d.servers) == 0 || rr.TTL < ttl {
+			ttl = rr.TTL
+		}
+		d.servers = append(d.servers, data.(*NS).Host)
+	}
+
+	for _, rr := range additional {
+		addr, ok := recordAddr(rr)
+		if !ok || !slices.ContainsFunc(d.servers, rr.Name.Equal) {
+			continue
+		}
+		key := rr.Name.Canonical()
+		d.glue[key] = append(d.glue[key], addr)
+	}
+	d.expires = time.Now().Add(capTTL(ttl))
+	return d
+}
+
+// referral returns the delegation in resp if it is a referral from zone
+// to a zone closer to name.
+func referral(resp *Message, zone, name DNSName) *delegation {
+	if resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) > 0 {
+		return nil
+	}
+	for _, rr := range resp.Authority {
+		if rr.Type == TypeNS && name.IsSubdomainOf(rr.Name) &&
+			rr.Name.IsSubdomainOf(zone) && !rr.Name.Equal(zone) {
+			d := newDelegation(rr.Name, resp.Authority, resp.Additional)
+			if len(d.servers) > 0 {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// isLame reports whether resp is neither an answer, a negative answer nor
+// a referral to a zone below zone, which is the case for servers that do
+// not serve zone.
+func isLame(resp *Message, zone, name DNSName) bool {
+	if resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) > 0 {
+		return false
+	}
+	hasNS := false
+	for _, rr := range resp.Authority {
+		switch rr.Type {
+		case TypeSOA:
+			return false
+		case TypeNS:
+			hasNS = true
+		}
+	}
+	return hasNS && referral(resp, zone, name) == nil
+}
@@ -0,0 +1,352 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testAuthZone answers queries for a zone like an authoritative server:
+// names below a zone cut get a referral with glue, names without records
+// NXDOMAIN and missing types NODATA, both with the SOA record.
+type testAuthZone struct {
+	origin  DNSName
+	records []*ResourceRecord
+	queries atomic.Int32
+
+	// brokenENT answers NXDOMAIN for empty non-terminals.
+	brokenENT atomic.Bool
+
+	// lowercase echoes the query name in lower case.
+	lowercase atomic.Bool
+
+	mu    sync.Mutex
+	names []DNSName
+
+	// inject is added to the answer and additional sections of every
+	// response.
+	inject []*ResourceRecord
+}
+
+// queried returns the names the zone was asked for.
+func (z *testAuthZone) queried() []DNSName {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return slices.Clone(z.names)
+}
+
+func (z *testAuthZone) handle(q *Message, network string) (resp *Message) {
+	z.queries.Add(1)
+	question := q.Question[0]
+	z.mu.Lock()
+	z.names = append(z.names, question.Name)
+	z.mu.Unlock()
+	defer func() {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		resp.Answer = append(resp.Answer, z.inject...)
+		resp.Additional = append(resp.Additional, z.inject...)
+	}()
+
+	resp = testReply(q, RCodeNoError)
+	resp.Header.SetRecursionAvailable(false)
+	if z.lowercase.Load() {
+		resp.Question = []*Question{{Name: question.Name.Canonical(), Type: question.Type, Class: question.Class}}
+	}
+	if !question.Name.IsSubdomainOf(z.origin) {
+		resp.Header.SetResponseCode(RCodeRefused)
+		return resp
+	}
+
+	var cut DNSName
+	for _, rr := range z.records {
+		if rr.Type == TypeNS && !rr.Name.Equal(z.origin) && question.Name.IsSubdomainOf(rr.Name) {
+			cut = rr.Name
+		}
+	}
+	if cut != "" {
+		for _, rr := range z.records {
+			if rr.Type == TypeNS && rr.Name.Equal(cut) {
+				resp.Authority = append(resp.Authority, rr)
+			}
+		}
+		for _, rr := range z.records {
+			for _, ns := range resp.Authority {
+				data, _ := ns.Rdata()
+				if (rr.Type == TypeA || rr.Type == TypeAAAA) && rr.Name.Equal(data.(*NS).Host) {
+					resp.Additional = append(resp.Additional, rr)
+				}
+			}
+		}
+		return resp
+	}
+
+	resp.Header.SetAuthoritativeAnswer(true)
+	exists := false
+	for _, rr := range z.records {
+		if rr.Name.Equal(question.Name) || (rr.Name.IsSubdomainOf(question.Name) && !z.brokenENT.Load()) {
+			exists = true
+		}
+		if rr.Name.Equal(question.Name) && (rr.Type == question.Type || rr.Type == TypeCNAME) {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	if len(resp.Answer) == 0 {
+		for _, rr := range z.records {
+			if rr.Type == TypeSOA {
+				resp.Authority = append(resp.Authority, rr)
+			}
+		}
+		if !exists {
+			resp.Header.SetResponseCode(RCodeNameError)
+		}
+	}
+	return resp
+}
+
+func testSOAFor(zone DNSName) *ResourceRecord {
+	return NewResourceRecord(zone, ClassIN, 300, &SOA{MName: "ns." + zone, RName: "hostmaster." + zone, Serial: 1, Minimum: 60})
+}
+
+func testNS(zone, host DNSName) *ResourceRecord {
+	return NewResourceRecord(zone, ClassIN, 3600, &NS{Host: host})
+}
+
+func testAddr(name DNSName, addr string) *ResourceRecord {
+	a := netip.MustParseAddr(addr)
+	if a.Is4() {
+		return NewResourceRecord(name, ClassIN, 3600, &A{Addr: a})
+	}
+	return NewResourceRecord(name, ClassIN, 3600, &AAAA{Addr: a})
+}
+
+// testHierarchy is a tree of authoritative servers on loopback addresses
+// sharing one port.
+type testHierarchy struct {
+	zones map[DNSName]*testAuthZone
+	port  uint16
+	hints []*ResourceRecord
+}
+
+// startTestHierarchy starts a server on 127.0.0.(2+i) for the i-th zone.
+// The first zone must be the root zone.
+func startTestHierarchy(t *testing.T, zones ...*testAuthZone) *testHierarchy {
+	h := &testHierarchy{zones: make(map[DNSName]*testAuthZone)}
+	for i, z := range zones {
+		addr := net.JoinHostPort(netip.AddrFrom4([4]byte{127, 0, 0, byte(2 + i)}).String(), strconv.Itoa(int(h.port)))
+		_, port, _ := net.SplitHostPort(startTestServerAt(t, addr, z.handle))
+		p, _ := strconv.Atoi(port)
+		h.port = uint16(p)
+		h.zones[z.origin] = z
+	}
+	h.hints = []*ResourceRecord{testNS(RootName, "a.root.test"), testAddr("a.root.test", "127.0.0.2")}
+	return h
+}
+
+func (h *testHierarchy) resolver() *IterativeResolver {
+	return &IterativeResolver{
+		Client: &Client{Timeout: time.Second},
+		Hints:  h.hints,
+		Port:   h.port,
+	}
+}
+
+func newTestHierarchy(t *testing.T) *testHierarchy {
+	return startTestHierarchy(t,
+		&testAuthZone{origin: RootName, records: []*ResourceRecord{
+			testSOAFor(RootName),
+			testNS(RootName, "a.root.test"),
+			testNS("de", "ns.de"),
+			testAddr("ns.de", "127.0.0.3"),
+			testNS("example", "ns.noteip.de"),
+			testNS("loop1", "ns.loop2"),
+			testNS("loop2", "ns.loop1"),
+		}},
+		&testAuthZone{origin: "de", records: []*ResourceRecord{
+			testSOAFor("de"),
+			testNS("de", "ns.de"),
+			testAddr("ns.de", "127.0.0.3"),
+			testNS("noteip.de", "ns1.noteip.de"),
+			testAddr("ns1.noteip.de", "127.0.0.4"),
+		}},
+		&testAuthZone{origin: "noteip.de", records: []*ResourceRecord{
+			testSOAFor("noteip.de"),
+			testNS("noteip.de", "ns1.noteip.de"),
+			testAddr("ns1.noteip.de", "127.0.0.4"),
+			testAddr("ns.noteip.de", "127.0.0.5"),
+			testAddr("git.noteip.de", "192.0.2.1"),
+			testAddr("git.noteip.de", "2001:db8::1"),
+			testAddr("host.ent.noteip.de", "192.0.2.2"),
+			NewResourceRecord("www.noteip.de", ClassIN, 300, &CNAME{Target: "web.example"}),
+		}},
+		&testAuthZone{origin: "example", records: []*ResourceRecord{
+			testSOAFor("example"),
+			testNS("example", "ns.noteip.de"),
+			testAddr("web.example", "192.0.2.80"),
+		}},
+	)
+}
+
+func TestIterativeResolve(t *testing.T) {
+	h := newTestHierarchy(t)
+	r := h.resolver()
+	ctx := context.Background()
+
+	resp, err := r.Resolve(ctx, "git.noteip.de", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || !resp.Header.IsAuthoritativeAnswer() {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+
+	// The delegation of noteip.de is remembered.
+	rootQueries := h.zones[RootName].queries.Load()
+	if _, err := r.Resolve(ctx, "git.noteip.de", TypeAAAA); err != nil {
+		t.Fatal(err)
+	}
+	if n := h.zones[RootName].queries.Load(); n != rootQueries {
+		t.Fatalf("Expected no further root queries but got %d", n-rootQueries)
+	}
+
+	resp, err = r.Resolve(ctx, "missing.noteip.de", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNameError || len(resp.Authority) != 1 || resp.Authority[0].Type != TypeSOA {
+		t.Fatalf("Expected NXDOMAIN with SOA but got %v", resp)
+	}
+}
+
+func TestIterativeResolveCNAME(t *testing.T) {
+	h := newTestHierarchy(t)
+	r := h.resolver()
+
+	// The CNAME target is in a zone whose name server has no glue.
+	resp, err := r.Resolve(context.Background(), "www.noteip.de", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 2 || resp.Answer[0].Type != TypeCNAME || resp.Answer[1].Name.Canonical() != "web.example" {
+		t.Fatalf("Unexpected answer %v", resp.Answer)
+	}
+}
+
+func TestIterativeResolveLimits(t *testing.T) {
+	h := newTestHierarchy(t)
+
+	r := h.resolver()
+	r.MaxQueries = 2
+	if _, err := r.Resolve(context.Background(), "git.noteip.de", TypeA); !errors.Is(err, ErrMaxQueries) {
+		t.Fatalf("Expected ErrMaxQueries but got %v", err)
+	}
+
+	// The name servers of loop1 and loop2 are in the other zone.
+	r = h.resolver()
+	if _, err := r.Resolve(context.Background(), "www.loop1", TypeA); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("Expected ErrMaxDepth but got %v", err)
+	}
+}
+
+func TestIterativeResolverExchange(t *testing.T) {
+	h := newTestHierarchy(t)
+	c := NewCache(h.resolver(), 0)
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	for range 2 {
+		resp, err := c.Exchange(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Id != q.Header.Id || !resp.Header.IsRecursionAvailable() || len(resp.Answer) != 1 {
+			t.Fatalf("Unexpected response %v", resp)
+		}
+	}
+	if n := h.zones["noteip.de"].queries.Load(); n != 1 {
+		t.Fatalf("Expected the second answer from the cache but got %d queries", n)
+	}
+}
+
+func TestRootHints(t *testing.T) {
+	d := newDelegation(RootName, RootHints(), RootHints())
+	if len(d.servers) != 13 || len(d.glue) != 13 || len(d.glue["a.root-servers.net"]) != 2 {
+		t.Fatalf("Unexpected root delegation %+v", d)
+	}
+}
+
+func TestIterativeQNAMEMinimisation(t *testing.T) {
+	h := newTestHierarchy(t)
+	r := h.resolver()
+
+	if _, err := r.Resolve(context.Background(), "host.ent.noteip.de", TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if names := h.zones[RootName].queried(); len(names) != 1 || names[0].Canonical() != "de" {
+		t.Fatalf("Root zone saw %v", names)
+	}
+	if names := h.zones["de"].queried(); len(names) != 1 || names[0].Canonical() != "noteip.de" {
+		t.Fatalf("de zone saw %v", names)
+	}
+	if names := h.zones["noteip.de"].queried(); len(names) != 2 || names[0].Canonical() != "ent.noteip.de" {
+		t.Fatalf("noteip.de zone saw %v", names)
+	}
+
+	r = h.resolver()
+	r.DisableQNAMEMinimisation = true
+	if _, err := r.Resolve(context.Background(), "git.noteip.de", TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if names := h.zones[RootName].queried(); names[len(names)-1].Canonical() != "git.noteip.de" {
+		t.Fatalf("Expected the full name at the root but got %v", names)
+	}
+}
+
+func TestIterativeQNAMEMinimisationBrokenENT(t *testing.T) {
+	h := newTestHierarchy(t)
+	h.zones["noteip.de"].brokenENT.Store(true)
+	r := h.resolver()
+
+	resp, err := r.Resolve(context.Background(), "host.ent.noteip.de", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) != 1 {
+		t.Fatalf("Expected the fallback to find the answer but got %v", resp)
+	}
+	if names := h.zones["noteip.de"].queried(); len(names) != 2 || names[1].Canonical() != "host.ent.noteip.de" {
+		t.Fatalf("noteip.de zone saw %v", names)
+	}
+}
+
+func TestMinimisedName(t *testing.T) {
+	name := DNSName("a.b.c.d.e.f.g.h.i.j.k.l.m.n.o.p.q.r.s.t.example")
+	known := DNSName("example")
+	var steps []DNSName
+	for step := 0; ; step++ {
+		next := minimisedName(name, known, step)
+		if next == "" {
+			break
+		}
+		steps = append(steps, next)
+		known = next
+	}
+	if len(steps) >= maxMinimiseCount {
+		t.Fatalf("Expected at most %d extra queries but got %v", maxMinimiseCount-1, steps)
+	}
+	for i, want := range []DNSName{"t.example", "s.t.example", "r.s.t.example", "q.r.s.t.example"} {
+		if steps[i] != want {
+			t.Errorf("Step %d is %s, want %s", i, steps[i], want)
+		}
+	}
+
+	if next := minimisedName("www.example", "example", 0); next != "" {
+		t.Errorf("Expected the full name for a child of the zone but got %s", next)
+	}
+}
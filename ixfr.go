@@ -0,0 +1,346 @@
+package dns
+
+import (
+	"context"
+	"slices"
+)
+
+// DefaultJournalSize is the number of deltas a Zone keeps for IXFR.
+const DefaultJournalSize = 100
+
+// ZoneDelta is the difference between two versions of a zone, which are
+// identified by their SOA records (RFC 1995 section 4).
+type ZoneDelta struct {
+	From, To *ResourceRecord
+
+	// Deleted and Added are the changed records besides the SOA.
+	Deleted, Added []*ResourceRecord
+}
+
+// IXFRResponse is the result of an incremental zone transfer.
+type IXFRResponse struct {
+	// SOA is the current SOA record of the zone at the server.
+	SOA *ResourceRecord
+
+	// Deltas lead from the requested version to SOA. They are empty if
+	// the requested version is current.
+	Deltas []*ZoneDelta
+
+	// Records holds all records of the zone, starting with SOA, if the
+	// server sent the full zone instead of the deltas.
+	Records []*ResourceRecord
+}
+
+// UpToDate reports whether the requested version is current.
+func (r *IXFRResponse) UpToDate() bool {
+	return len(r.Deltas) == 0 && r.Records == nil
+}
+
+// Apply changes the zone by deltas in order and journals them for IXFR.
+// Each delta has to start at the serial of the current SOA record;
+// otherwise ErrDeltaMismatch is returned and the zone is left unchanged.
+func (z *Zone) Apply(deltas ...*ZoneDelta) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	t := z.Snapshot()
+	for _, d := range deltas {
+		soa := t.SOA()
+		if soa == nil || !z.isSOA(d.From) || !z.isSOA(d.To) || soaSerial(soa) != soaSerial(d.From) {
+			return ErrDeltaMismatch
+		}
+		t = t.Delete(soa).Delete(d.Deleted...)
+		var err error
+		if t, err = t.Insert(slices.Concat(d.Added, []*ResourceRecord{d.To})...); err != nil {
+			return err
+		}
+	}
+
+	size := z.JournalSize
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	z.journal = append(z.journal, deltas...)
+	if len(z.journal) > size {
+		z.journal = append([]*ZoneDelta(nil), z.journal[len(z.journal)-size:]...)
+	}
+	z.tree.Store(t)
+	return nil
+}
+
+// isSOA reports whether rr is a valid SOA record of the origin.
+func (z *Zone) isSOA(rr *ResourceRecord) bool {
+	if rr == nil || rr.Type != TypeSOA || !rr.Name.Equal(z.Origin) {
+		return false
+	}
+	data, err := rr.Rdata()
+	if err != nil {
+		return false
+	}
+	_, ok := data.(*SOA)
+	return ok
+}
+
+// Replace replaces all records of the zone, e.g. with the result of an
+// AXFR. The journal is cleared, so IXFR clients get the full zone.
+func (z *Zone) Replace(records ...*ResourceRecord) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	t, err := NewZoneTree(z.Origin).Insert(records...)
+	if err != nil {
+		return err
+	}
+	z.journal = nil
+	z.tree.Store(t)
+	return nil
+}
+
+// ixfr returns the IXFR response records for a client at serial: the
+// deltas from serial to the current version or, if the journal does not
+// reach back to serial, the full zone (RFC 1995 section 4). A client that
+// is up to date gets the SOA record only.
+func (z *Zone) ixfr(serial uint32) ([]*ResourceRecord, error) {
+	z.mu.Lock()
+	t, journal := z.Snapshot(), z.journal
+	z.mu.Unlock()
+
+	soa := t.SOA()
+	if soa == nil {
+		return nil, &TransferError{Zone: z.Origin, Rcode: RCodeServerFailure}
+	}
+	if CompareSerial(serial, soaSerial(soa)) >= 0 {
+		return []*ResourceRecord{soa}, nil
+	}
+
+	start := -1
+	for i, d := range journal {
+		if soaSerial(d.From) == serial {
+			start = i
+		}
+	}
+	if start < 0 {
+		return t.axfr()
+	}
+
+	records := []*ResourceRecord{soa}
+	for _, d := range journal[start:] {
+		records = append(records, d.From)
+		records = append(records, d.Deleted...)
+		records = append(records, d.To)
+		records = append(records, d.Added...)
+	}
+	return append(records, soa), nil
+}
+
+// ixfrSerial returns the serial of the client's SOA record in the
+// authority section of the IXFR query q.
+func ixfrSerial(q *Message, zone DNSName) (uint32, bool) {
+	for _, rr := range q.Authority {
+		if rr.Type == TypeSOA && rr.Name.Equal(zone) {
+			return soaSerial(rr), true
+		}
+	}
+	return 0, false
+}
+
+// IXFR requests the changes of the zone of soa since the version of soa
+// from server (RFC 1995). The query is sent over UDP first if Net is empty
+// or "udp"; TCP is used if the response does not fit, which the server
+// signals by truncation or by sending only its newer SOA record. The
+// server may send the full zone instead of the changes.
+func (c *Client) IXFR(ctx context.Context, soa *ResourceRecord, server string) (*IXFRResponse, error) {
+	q, err := NewQuery(string(soa.Name), TypeIXFR, ClassIN)
+	if err != nil {
+		return nil, err
+	}
+	q.Authority = []*ResourceRecord{soa}
+	serial := soaSerial(soa)
+
+	if c.Net == "" || c.Net == "udp" {
+		uctx, cancel := context.WithTimeout(ctx, c.timeout())
+		resp, err := c.exchangeUDP(uctx, q, server, matchResponse)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if !resp.Header.IsTruncated() {
+			r := &ixfrReader{zone: soa.Name, serial: serial, udp: true}
+			done, err := r.read(resp)
+			if err != nil {
+				return nil, err
+			}
+			newer := r.resp.SOA != nil && CompareSerial(soaSerial(r.resp.SOA), serial) > 0
+			if done && !(r.resp.UpToDate() && newer) {
+				return &r.resp, nil
+			}
+		}
+	}
+
+	r := &ixfrReader{zone: soa.Name, serial: serial}
+	for resp, err := range c.transfer(ctx, q, server) {
+		if err != nil {
+			return nil, err
+		}
+		done, err := r.read(resp)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return &r.resp, nil
+		}
+	}
+	return nil, ErrBadTransfer
+}
+
+// UpdateZone brings z up to date with server: with an IXFR if z has an SOA
+// record, with an AXFR otherwise. It reports whether z changed.
+func (c *Client) UpdateZone(ctx context.Context, z *Zone, server string) (bool, error) {
+	soa := z.SOA()
+	if soa == nil {
+		return c.replaceZone(ctx, z, server)
+	}
+
+	resp, err := c.IXFR(ctx, soa, server)
+	switch {
+	case err != nil:
+		return false, err
+	case resp.Records != nil:
+		return true, z.Replace(resp.Records...)
+	case resp.UpToDate():
+		return false, nil
+	}
+	if err := z.Apply(resp.Deltas...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replaceZone replaces the records of z with an AXFR from server.
+func (c *Client) replaceZone(ctx context.Context, z *Zone, server string) (bool, error) {
+	var records []*ResourceRecord
+	for rr, err := range c.AXFR(ctx, z.Origin, server) {
+		if err != nil {
+			return false, err
+		}
+		records = append(records, rr)
+	}
+	return true, z.Replace(records...)
+}
+
+// ixfrReader parses the records of IXFR responses.
+type ixfrReader struct {
+	zone DNSName
+
+	// serial is the serial of the client.
+	serial uint32
+
+	// udp is set for a response in a single UDP message.
+	udp bool
+
+	resp  IXFRResponse
+	state int
+	delta *ZoneDelta
+}
+
+const (
+	ixfrStart   = iota // expecting the current SOA
+	ixfrFirst          // after the current SOA
+	ixfrFull           // reading the full zone
+	ixfrDeleted        // reading deleted records
+	ixfrAdded          // reading added records
+	ixfrDone
+)
+
+// read processes the records of the response msg and reports whether the
+// transfer is complete.
+func (r *ixfrReader) read(msg *Message) (bool, error) {
+	if rcode := msg.Header.ResponseCode(); rcode != RCodeNoError {
+		return false, &TransferError{Zone: r.zone, Rcode: rcode}
+	}
+	for _, rr := range msg.Answer {
+		if err := r.add(rr); err != nil {
+			return false, err
+		}
+	}
+	switch r.state {
+	case ixfrStart:
+		return false, ErrBadTransfer
+	case ixfrFirst:
+		// A UDP response with the SOA record only is complete; the
+		// caller retries over TCP if the SOA is newer. Over TCP, the
+		// server may send one record per message (RFC 5936 section
+		// 2.2), so only an SOA that is not newer ends the transfer.
+		if r.udp || CompareSerial(soaSerial(r.resp.SOA), r.serial) <= 0 {
+			r.state = ixfrDone
+		}
+	}
+	return r.state == ixfrDone, nil
+}
+
+func (r *ixfrReader) add(rr *ResourceRecord) error {
+	isSOA := rr.Type == TypeSOA && rr.Name.Equal(r.zone)
+	current := r.resp.SOA
+	switch r.state {
+	case ixfrStart:
+		if !isSOA {
+			return ErrBadTransfer
+		}
+		r.resp.SOA = rr
+		r.state = ixfrFirst
+
+	case ixfrFirst:
+		switch {
+		case isSOA && soaSerial(rr) == soaSerial(current):
+			// The full zone consists of the SOA record only.
+			r.resp.Records = []*ResourceRecord{current}
+			r.state = ixfrDone
+		case isSOA:
+			r.delta = &ZoneDelta{From: rr}
+			r.state = ixfrDeleted
+		default:
+			r.resp.Records = []*ResourceRecord{current, rr}
+			r.state = ixfrFull
+		}
+
+	case ixfrFull:
+		if !isSOA {
+			r.resp.Records = append(r.resp.Records, rr)
+			break
+		}
+		if !sameRecord(rr, current) {
+			return ErrBadTransfer
+		}
+		r.state = ixfrDone
+
+	case ixfrDeleted:
+		if isSOA {
+			r.delta.To = rr
+			r.state = ixfrAdded
+		} else {
+			r.delta.Deleted = append(r.delta.Deleted, rr)
+		}
+
+	case ixfrAdded:
+		if !isSOA {
+			r.delta.Added = append(r.delta.Added, rr)
+			break
+		}
+		r.resp.Deltas = append(r.resp.Deltas, r.delta)
+		last := soaSerial(r.delta.To)
+		switch {
+		case last == soaSerial(current) && soaSerial(rr) == last:
+			r.state = ixfrDone
+		case soaSerial(rr) != last:
+			// The next delta has to start where the last one ended.
+			return ErrBadTransfer
+		default:
+			r.delta = &ZoneDelta{From: rr}
+			r.state = ixfrDeleted
+		}
+
+	case ixfrDone:
+		return ErrBadTransfer
+	}
+	return nil
+}
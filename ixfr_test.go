@@ -0,0 +1,300 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// withSerial returns a copy of the SOA record soa with serial.
+func withSerial(soa *ResourceRecord, serial uint32) *ResourceRecord {
+	data := *soaData(soa)
+	data.Serial = serial
+	return NewResourceRecord(soa.Name, soa.Class, soa.TTL, &data)
+}
+
+// applyDelta moves z to serial with the changes.
+func applyDelta(t *testing.T, z *Zone, serial uint32, deleted, added []*ResourceRecord) {
+	t.Helper()
+
+	d := &ZoneDelta{From: z.SOA(), To: withSerial(z.SOA(), serial), Deleted: deleted, Added: added}
+	if err := z.Apply(d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ixfrQuery(serial uint32) *Message {
+	q, _ := NewQuery("example.com", TypeIXFR, ClassIN)
+	q.Authority = []*ResourceRecord{withSerial(testSOAFor("example.com"), serial)}
+	return q
+}
+
+func TestZoneApply(t *testing.T) {
+	z := newTestZone(t)
+	applyDelta(t, z, 2,
+		[]*ResourceRecord{testAddr("www.example.com", "192.0.2.10")},
+		[]*ResourceRecord{testAddr("www.example.com", "192.0.2.11")})
+
+	if s := soaSerial(z.SOA()); s != 2 {
+		t.Fatalf("Expected serial 2, got %d", s)
+	}
+	records, _ := z.Snapshot().Lookup("www.example.com")
+	if len(records) != 1 || records[0].String() != testAddr("www.example.com", "192.0.2.11").String() {
+		t.Fatalf("Unexpected records: %v", records)
+	}
+
+	// The delta has to start at the current serial.
+	d := &ZoneDelta{From: testSOAFor("example.com"), To: withSerial(testSOAFor("example.com"), 3)}
+	if err := z.Apply(d); !errors.Is(err, ErrDeltaMismatch) {
+		t.Fatalf("Expected ErrDeltaMismatch, got %v", err)
+	}
+	if s := soaSerial(z.SOA()); s != 2 {
+		t.Fatalf("Failed delta changed the zone to serial %d", s)
+	}
+
+	// Both ends of a delta have to be SOA records of the zone.
+	for _, d := range []*ZoneDelta{
+		{From: testAddr("example.com", "192.0.2.1"), To: withSerial(testSOAFor("example.com"), 3)},
+		{From: withSerial(testSOAFor("example.org"), 2), To: withSerial(testSOAFor("example.com"), 3)},
+		{From: withSerial(testSOAFor("example.com"), 2), To: withSerial(testSOAFor("www.example.com"), 3)},
+	} {
+		if err := z.Apply(d); !errors.Is(err, ErrDeltaMismatch) {
+			t.Fatalf("Expected ErrDeltaMismatch for %v, got %v", d.From, err)
+		}
+	}
+
+	// Apply does not write into the slices of the delta.
+	added := make([]*ResourceRecord, 1, 2)
+	added[0] = testAddr("new.example.com", "192.0.2.2")
+	spare := added[:2]
+	applyDelta(t, z, 3, nil, added)
+	if spare[1] != nil {
+		t.Fatalf("Apply wrote %v into the spare capacity of Added", spare[1])
+	}
+}
+
+func TestZoneIXFR(t *testing.T) {
+	z := newTestZone(t)
+	z.JournalSize = 2
+	applyDelta(t, z, 2, nil, []*ResourceRecord{testAddr("new.example.com", "192.0.2.2")})
+	applyDelta(t, z, 3, []*ResourceRecord{testAddr("new.example.com", "192.0.2.2")}, []*ResourceRecord{testAddr("new.example.com", "192.0.2.3")})
+	applyDelta(t, z, 4, nil, []*ResourceRecord{testAddr("four.example.com", "192.0.2.4")})
+
+	records, err := z.Transfer(context.Background(), ixfrQuery(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"example.com/SOA",
+		"example.com/SOA", "new.example.com/A", "example.com/SOA", "new.example.com/A",
+		"example.com/SOA", "example.com/SOA", "four.example.com/A",
+		"example.com/SOA",
+	}
+	if names := recordNames(records); !slices.Equal(names, expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	serials := []uint32{4, 2, 3, 3, 4, 4}
+	var got []uint32
+	for _, rr := range records {
+		if rr.Type == TypeSOA {
+			got = append(got, soaSerial(rr))
+		}
+	}
+	if !slices.Equal(got, serials) {
+		t.Fatalf("Expected SOA serials %v, got %v", serials, got)
+	}
+
+	// Serial 1 dropped out of the journal, so the full zone is sent.
+	records, err = z.Transfer(context.Background(), ixfrQuery(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != z.Snapshot().Len()+1 || records[1].Type == TypeSOA {
+		t.Fatalf("Expected the full zone, got %v", recordNames(records))
+	}
+
+	// Clients that are up to date get the SOA record only.
+	for _, serial := range []uint32{4, 5} {
+		records, err = z.Transfer(context.Background(), ixfrQuery(serial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || soaSerial(records[0]) != 4 {
+			t.Fatalf("Expected the SOA record for serial %d, got %v", serial, recordNames(records))
+		}
+	}
+
+	q, _ := NewQuery("example.com", TypeIXFR, ClassIN)
+	var te *TransferError
+	if _, err := z.Transfer(context.Background(), q); !errors.As(err, &te) || te.Rcode != RCodeFormatError {
+		t.Fatalf("Expected FORMERR without SOA, got %v", err)
+	}
+
+	// Changes without a version clear the journal.
+	if err := z.Add(testAddr("five.example.com", "192.0.2.5")); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ = z.Transfer(context.Background(), ixfrQuery(3)); len(records) != z.Snapshot().Len()+1 {
+		t.Fatalf("Expected the full zone after Add, got %v", recordNames(records))
+	}
+}
+
+func TestClientIXFR(t *testing.T) {
+	primary := newTestZone(t)
+	addr := serveTest(t, &Server{Handler: primary})
+	secondary := newTestZone(t)
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			c := &Client{Net: network, Timeout: time.Second}
+			resp, err := c.IXFR(context.Background(), primary.SOA(), addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !resp.UpToDate() || soaSerial(resp.SOA) != soaSerial(primary.SOA()) {
+				t.Fatalf("Expected an up to date response, got %+v", resp)
+			}
+		})
+	}
+
+	serial := soaSerial(primary.SOA())
+	applyDelta(t, primary, serial+1,
+		[]*ResourceRecord{testAddr("www.example.com", "192.0.2.10")},
+		[]*ResourceRecord{testAddr("www.example.com", "192.0.2.11")})
+	applyDelta(t, primary, serial+2, nil, []*ResourceRecord{testAddr("new.example.com", "192.0.2.2")})
+
+	c := &Client{Timeout: time.Second}
+	changed, err := c.UpdateZone(context.Background(), secondary, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || !slices.Equal(recordNames(secondary.Records()), recordNames(primary.Records())) {
+		t.Fatalf("Secondary differs from primary: %v", recordNames(secondary.Records()))
+	}
+	if rrs, _ := secondary.Snapshot().Lookup("www.example.com"); len(rrs) != 1 || rrs[0].String() != testAddr("www.example.com", "192.0.2.11").String() {
+		t.Fatalf("Unexpected records after IXFR: %v", rrs)
+	}
+	if soaSerial(secondary.SOA()) != serial+2 {
+		t.Fatalf("Expected serial %d, got %d", serial+2, soaSerial(secondary.SOA()))
+	}
+
+	// The secondary journals the deltas, so it can serve IXFR itself.
+	records, err := secondary.Transfer(context.Background(), ixfrQuery(serial))
+	if err != nil || len(records) != 9 {
+		t.Fatalf("Expected the deltas from the secondary, got %v %v", recordNames(records), err)
+	}
+
+	if changed, err = c.UpdateZone(context.Background(), secondary, addr); err != nil || changed {
+		t.Fatalf("Expected no change, got %v %v", changed, err)
+	}
+
+	// An empty zone is filled with an AXFR.
+	empty := &Zone{Origin: "example.com"}
+	if changed, err = c.UpdateZone(context.Background(), empty, addr); err != nil || !changed {
+		t.Fatalf("Expected AXFR, got %v %v", changed, err)
+	}
+	if empty.Snapshot().Len() != primary.Snapshot().Len() {
+		t.Fatalf("Expected %d records, got %d", primary.Snapshot().Len(), empty.Snapshot().Len())
+	}
+}
+
+func TestClientIXFRLarge(t *testing.T) {
+	primary := newTestZone(t)
+	addr := serveTest(t, &Server{Handler: primary})
+	serial := soaSerial(primary.SOA())
+	old := primary.SOA()
+
+	large := newLargeZone(t, 200)
+	var added []*ResourceRecord
+	for _, rr := range large.Records() {
+		if rr.Name.IsSubdomainOf("large.example.com") {
+			added = append(added, rr)
+		}
+	}
+	applyDelta(t, primary, serial+1, nil, added)
+
+	// The deltas do not fit into a UDP response, so the server sends its
+	// SOA record and the client retries over TCP.
+	q := ixfrQuery(serial)
+	resp, err := (&Client{Timeout: time.Second}).Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || soaSerial(resp.Answer[0]) != serial+1 || resp.Header.IsTruncated() {
+		t.Fatalf("Expected the SOA record only over UDP, got %v", resp)
+	}
+
+	ixfr, err := (&Client{Timeout: time.Second}).IXFR(context.Background(), old, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ixfr.Deltas) != 1 || len(ixfr.Deltas[0].Added) != len(added) || len(ixfr.Deltas[0].Deleted) != 0 {
+		t.Fatalf("Unexpected deltas: %+v", ixfr.Deltas)
+	}
+}
+
+func TestClientIXFROneRecordPerMessage(t *testing.T) {
+	soa := testSOAFor("example.com")
+	records := []*ResourceRecord{
+		withSerial(soa, 3),
+		withSerial(soa, 1), testAddr("www.example.com", "192.0.2.10"),
+		withSerial(soa, 2), testAddr("www.example.com", "192.0.2.11"),
+		withSerial(soa, 2),
+		withSerial(soa, 3), testAddr("new.example.com", "192.0.2.2"),
+		withSerial(soa, 3),
+	}
+	addr := serveStream(t, func(q *Message) []*Message {
+		var msgs []*Message
+		for _, rr := range records {
+			msgs = append(msgs, slices.Collect(transferMessages(q, []*ResourceRecord{rr}))...)
+		}
+		return msgs
+	})
+
+	c := &Client{Net: "tcp", Timeout: time.Second}
+	resp, err := c.IXFR(context.Background(), soa, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.UpToDate() || len(resp.Deltas) != 2 || soaSerial(resp.SOA) != 3 {
+		t.Fatalf("Expected two deltas to serial 3, got %+v", resp)
+	}
+	if d := resp.Deltas[1]; soaSerial(d.From) != 2 || soaSerial(d.To) != 3 || len(d.Deleted) != 0 || len(d.Added) != 1 {
+		t.Fatalf("Unexpected delta %+v", d)
+	}
+}
+
+func TestClientIXFRInvalid(t *testing.T) {
+	soa := testSOAFor("example.com")
+	tests := []struct {
+		name    string
+		records []*ResourceRecord
+	}{
+		{"no SOA", []*ResourceRecord{testAddr("www.example.com", "192.0.2.1")}},
+		{"broken chain", []*ResourceRecord{
+			withSerial(soa, 3),
+			withSerial(soa, 1), withSerial(soa, 2),
+			withSerial(soa, 1), withSerial(soa, 3),
+			withSerial(soa, 3),
+		}},
+		{"trailing records", []*ResourceRecord{
+			withSerial(soa, 2),
+			withSerial(soa, 1), withSerial(soa, 2),
+			withSerial(soa, 2),
+			testAddr("www.example.com", "192.0.2.1"),
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := serveStream(t, func(q *Message) []*Message {
+				return slices.Collect(transferMessages(q, tc.records))
+			})
+			c := &Client{Net: "tcp", Timeout: time.Second}
+			if _, err := c.IXFR(context.Background(), soa, addr); !errors.Is(err, ErrBadTransfer) {
+				t.Fatalf("Expected ErrBadTransfer, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package dns
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+)
+
+// maxCNAMEChain limits the number of CNAME records followed by a lookup.
+const maxCNAMEChain = 8
+
+// LookupError is returned by the Lookup methods of Resolver. Err is one
+// of ErrNXDomain, ErrNoData or ErrServerFailure, or the error of the
+// exchange that failed.
+type LookupError struct {
+	// Name is the name that was looked up.
+	Name DNSName
+
+	// Type is the type that was looked up.
+	Type Type
+
+	// Rcode is the response code of the last response, if any.
+	Rcode Rcode
+
+	Err error
+}
+
+func (e *LookupError) Error() string {
+	return fmt.Sprintf("Lookup of %s %s failed: %s", e.Name, e.Type, e.Err)
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// lookup resolves name and returns the canonical name and the answer
+// records of type qtype owned by it. CNAME chains are followed within the
+// response and with further queries if the response stops at an alias.
+func (r *Resolver) lookup(ctx context.Context, name string, qtype Type) (DNSName, []*ResourceRecord, error) {
+	resp, err := r.Query(ctx, name, qtype)
+	if err != nil {
+		return "", nil, &LookupError{Name: DNSName(name), Type: qtype, Err: err}
+	}
+
+	target := DNSName(name)
+	if len(resp.Question) > 0 {
+		target = resp.Question[0].Name
+	}
+
+	for hops := 0; ; {
+		if err := lookupStatus(resp, target, qtype); err != nil {
+			return "", nil, err
+		}
+
+		var records []*ResourceRecord
+		records, target, hops = followCNAMEs(resp.Answer, target, qtype, hops)
+		if len(records) > 0 {
+			return target, records, nil
+		}
+		if hops > maxCNAMEChain || resp.Question == nil || target.Equal(resp.Question[0].Name) {
+			return "", nil, &LookupError{Name: target, Type: qtype, Err: ErrNoData}
+		}
+
+		// The response ended at an alias, ask for its target.
+		resp, err = r.Query(ctx, string(target.Fqdn()), qtype)
+		if err != nil {
+			return "", nil, &LookupError{Name: target, Type: qtype, Err: err}
+		}
+	}
+}
+
+// lookupStatus converts the response code of resp to an error.
+func lookupStatus(resp *Message, name DNSName, qtype Type) error {
+	switch rcode := resp.Header.ResponseCode(); rcode {
+	case RCodeNoError:
+		return nil
+	case RCodeNameError:
+		return &LookupError{Name: name, Type: qtype, Rcode: rcode, Err: ErrNXDomain}
+	default:
+		return &LookupError{Name: name, Type: qtype, Rcode: rcode, Err: ErrServerFailure}
+	}
+}
+
+// followCNAMEs follows the CNAME chain starting at name through answers,
+// including the CNAMEs synthesized from DNAME records.
+// It returns the records of type qtype at the end of the chain, the last
+// name of the chain and the updated number of hops.
+func followCNAMEs(answers []*ResourceRecord, name DNSName, qtype Type, hops int) ([]*ResourceRecord, DNSName, int) {
+	for hops <= maxCNAMEChain {
+		var records []*ResourceRecord
+		for _, rr := range answers {
+			if rr.Type == qtype && rr.Name.Equal(name) {
+				records = append(records, rr)
+			}
+		}
+		if len(records) > 0 || qtype == TypeCNAME {
+			return records, name, hops
+		}
+
+		next := aliasTarget(answers, name)
+		if next == "" {
+			return nil, name, hops
+		}
+		name = next
+		hops++
+	}
+	return nil, name, hops
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of host from the sources
+// in Order. The A and AAAA queries are sent in parallel. An error is only
+// returned if neither query produced an address. IP literals are returned
+// as they are.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	var lastErr error
+	for _, src := range r.order() {
+		switch src {
+		case SourceFiles:
+			if r.Hosts == nil {
+				continue
+			}
+			if addrs := r.Hosts.LookupHost(host); len(addrs) > 0 {
+				return addrs, nil
+			}
+			if lastErr == nil {
+				lastErr = &LookupError{Name: DNSName(host), Type: TypeA, Err: ErrNXDomain}
+			}
+		case SourceDNS:
+			addrs, err := r.lookupHostDNS(ctx, host)
+			if err == nil {
+				return addrs, nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func (r *Resolver) lookupHostDNS(ctx context.Context, host string) ([]netip.Addr, error) {
+	type result struct {
+		records []*ResourceRecord
+		err     error
+	}
+	results := make([]chan result, 2)
+	for i, qtype := range []Type{TypeA, TypeAAAA} {
+		results[i] = make(chan result, 1)
+		go func() {
+			_, records, err := r.lookup(ctx, host, qtype)
+			results[i] <- result{records, err}
+		}()
+	}
+
+	var addrs []netip.Addr
+	var errs []error
+	for _, ch := range results {
+		res := <-ch
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+		for _, rr := range res.records {
+			switch data, _ := rr.Rdata(); data := data.(type) {
+			case *A:
+				addrs = append(addrs, data.Addr)
+			case *AAAA:
+				addrs = append(addrs, data.Addr)
+			}
+		}
+	}
+
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	return nil, combineLookupErrors(errs)
+}
+
+// combineLookupErrors returns the most significant of the errors of the
+// parallel address lookups: NXDOMAIN wins over NODATA which wins over
+// failures. Both queries failing with NODATA means there is no address.
+func combineLookupErrors(errs []error) error {
+	for _, target := range []error{ErrNXDomain, ErrNoData} {
+		for _, err := range errs {
+			if errors.Is(err, target) {
+				return err
+			}
+		}
+	}
+	return errs[0]
+}
+
+// LookupMX returns the MX records of name sorted by preference. Records
+// with equal preference are shuffled.
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*MX, error) {
+	_, records, err := r.lookup(ctx, name, TypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	var mxs []*MX
+	for _, rr := range records {
+		if data, err := rr.Rdata(); err == nil {
+			mxs = append(mxs, data.(*MX))
+		}
+	}
+	rand.Shuffle(len(mxs), func(i, j int) {
+		mxs[i], mxs[j] = mxs[j], mxs[i]
+	})
+	slices.SortStableFunc(mxs, func(a, b *MX) int {
+		return cmp.Compare(a.Preference, b.Preference)
+	})
+	return mxs, nil
+}
+
+// LookupSRV looks up the SRV records of _service._proto.name; if service
+// and proto are empty, name is looked up directly. It returns the
+// canonical name and the records in the order they should be tried: by
+// priority and, within one priority, by the weighted random selection of
+// RFC 2782. A single record with the target "." means that the service
+// is not available and is reported as ErrNoData.
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (DNSName, []*SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+
+	cname, records, err := r.lookup(ctx, target, TypeSRV)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var srvs []*SRV
+	for _, rr := range records {
+		if data, err := rr.Rdata(); err == nil {
+			srvs = append(srvs, data.(*SRV))
+		}
+	}
+	if len(srvs) == 1 && srvs[0].Target.IsRoot() {
+		return cname, nil, &LookupError{Name: cname, Type: TypeSRV, Err: ErrNoData}
+	}
+
+	SortSRV(srvs)
+	return cname, srvs, nil
+}
+
+// SortSRV orders srvs as described in RFC 2782: ascending by priority and
+// by weighted random selection among records of the same priority.
+func SortSRV(srvs []*SRV) {
+	slices.SortFunc(srvs, func(a, b *SRV) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+
+	for start := 0; start < len(srvs); {
+		end := start + 1
+		for end < len(srvs) && srvs[end].Priority == srvs[start].Priority {
+			end++
+		}
+		shuffleByWeight(srvs[start:end])
+		start = end
+	}
+}
+
+// shuffleByWeight repeatedly picks a record with a probability
+// proportional to its weight. Records with weight 0 end up last in random
+// order.
+func shuffleByWeight(srvs []*SRV) {
+	sum := 0
+	for _, srv := range srvs {
+		sum += int(srv.Weight)
+	}
+
+	for sum > 0 && len(srvs) > 1 {
+		n := rand.IntN(sum)
+		running := 0
+		for i := range srvs {
+			running += int(srvs[i].Weight)
+			if running > n {
+				srvs[0], srvs[i] = srvs[i], srvs[0]
+				break
+			}
+		}
+		sum -= int(srvs[0].Weight)
+		srvs = srvs[1:]
+	}
+
+	rand.Shuffle(len(srvs), func(i, j int) {
+		srvs[i], srvs[j] = srvs[j], srvs[i]
+	})
+}
+
+// LookupTXT returns the texts of the TXT records of name. The character
+// strings of each record are joined.
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	_, records, err := r.lookup(ctx, name, TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts []string
+	for _, rr := range records {
+		if data, err := rr.Rdata(); err == nil {
+			texts = append(texts, data.(*TXT).Joined())
+		}
+	}
+	return texts, nil
+}
+
+// LookupAddr returns the names of addr from the sources in Order: the
+// names listed in the hosts file or the PTR records.
+func (r *Resolver) LookupAddr(ctx context.Context, addr netip.Addr) ([]DNSName, error) {
+	if !addr.IsValid() {
+		return nil, ErrNotReverseName
+	}
+
+	var lastErr error
+	for _, src := range r.order() {
+		switch src {
+		case SourceFiles:
+			if r.Hosts == nil {
+				continue
+			}
+			if names := r.Hosts.LookupAddr(addr); len(names) > 0 {
+				return names, nil
+			}
+			if lastErr == nil {
+				lastErr = &LookupError{Name: ReverseName(addr), Type: TypePTR, Err: ErrNXDomain}
+			}
+		case SourceDNS:
+			names, err := r.lookupAddrDNS(ctx, addr)
+			if err == nil {
+				return names, nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func (r *Resolver) lookupAddrDNS(ctx context.Context, addr netip.Addr) ([]DNSName, error) {
+	_, records, err := r.lookup(ctx, string(ReverseName(addr).Fqdn()), TypePTR)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []DNSName
+	for _, rr := range records {
+		if data, err := rr.Rdata(); err == nil {
+			names = append(names, data.(*PTR).Name)
+		}
+	}
+	return names, nil
+}
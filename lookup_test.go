@@ -0,0 +1,221 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// testZone answers queries from a fixed set of records. Names without
+// records are answered with NXDOMAIN, names listed in fail with SERVFAIL.
+type testZone struct {
+	records []*ResourceRecord
+	fail    map[DNSName]bool
+	// noChase stops the server from adding the targets of CNAMEs.
+	noChase bool
+}
+
+func (z *testZone) handle(q *Message, network string) *Message {
+	question := q.Question[0]
+	if z.fail[question.Name.Canonical()] {
+		return testReply(q, RCodeServerFailure)
+	}
+
+	var answers []*ResourceRecord
+	name := question.Name
+	exists := false
+	for hops := 0; hops < 10; hops++ {
+		var next DNSName
+		for _, rr := range z.records {
+			if !rr.Name.Equal(name) {
+				continue
+			}
+			exists = true
+			if rr.Type == question.Type {
+				answers = append(answers, rr)
+			} else if rr.Type == TypeCNAME {
+				answers = append(answers, rr)
+				data, _ := rr.Rdata()
+				next = data.(*CNAME).Target
+			}
+		}
+		if next == "" || z.noChase {
+			break
+		}
+		name = next
+		exists = false
+	}
+
+	if !exists && len(answers) == 0 {
+		return testReply(q, RCodeNameError)
+	}
+	return testReply(q, RCodeNoError, answers...)
+}
+
+func newTestResolver(t *testing.T, zone *testZone) *Resolver {
+	return NewResolver(&ResolverConfig{
+		Servers:  []string{startTestServer(t, zone.handle)},
+		Search:   []DNSName{"noteip.de."},
+		Ndots:    1,
+		Timeout:  time.Second,
+		Attempts: 1,
+	})
+}
+
+var testLookupZone = &testZone{
+	records: []*ResourceRecord{
+		NewResourceRecord("www.noteip.de", ClassIN, 60, &CNAME{Target: "git.noteip.de"}),
+		NewResourceRecord("git.noteip.de", ClassIN, 60, &A{Addr: netip.MustParseAddr("192.0.2.1")}),
+		NewResourceRecord("git.noteip.de", ClassIN, 60, &AAAA{Addr: netip.MustParseAddr("2001:db8::1")}),
+		NewResourceRecord("v4.noteip.de", ClassIN, 60, &A{Addr: netip.MustParseAddr("192.0.2.4")}),
+		NewResourceRecord("noteip.de", ClassIN, 60, &MX{Preference: 20, Exchange: "mx2.noteip.de"}),
+		NewResourceRecord("noteip.de", ClassIN, 60, &MX{Preference: 10, Exchange: "mx1.noteip.de"}),
+		NewResourceRecord("noteip.de", ClassIN, 60, &TXT{Strings: []string{"v=spf1 ", "-all"}}),
+		NewResourceRecord("_sip._udp.noteip.de", ClassIN, 60, &SRV{Priority: 20, Weight: 0, Port: 5060, Target: "backup.noteip.de"}),
+		NewResourceRecord("_sip._udp.noteip.de", ClassIN, 60, &SRV{Priority: 10, Weight: 100, Port: 5060, Target: "a.noteip.de"}),
+		NewResourceRecord("_sip._udp.noteip.de", ClassIN, 60, &SRV{Priority: 10, Weight: 0, Port: 5060, Target: "b.noteip.de"}),
+		NewResourceRecord("_xmpp._tcp.noteip.de", ClassIN, 60, &SRV{Target: "."}),
+		NewResourceRecord("1.2.0.192.in-addr.arpa", ClassIN, 60, &PTR{Name: "git.noteip.de"}),
+	},
+	fail: map[DNSName]bool{"broken.noteip.de": true},
+}
+
+func TestLookupHost(t *testing.T) {
+	r := newTestResolver(t, testLookupZone)
+
+	addrs, err := r.LookupHost(context.Background(), "www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 || addrs[0] != netip.MustParseAddr("192.0.2.1") || addrs[1] != netip.MustParseAddr("2001:db8::1") {
+		t.Fatalf("Unexpected addresses %v", addrs)
+	}
+
+	// Only an A record exists.
+	addrs, err = r.LookupHost(context.Background(), "v4.noteip.de")
+	if err != nil || len(addrs) != 1 {
+		t.Fatalf("Expected one address but got %v (%v)", addrs, err)
+	}
+
+	addrs, err = r.LookupHost(context.Background(), "192.0.2.99")
+	if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.99") {
+		t.Fatalf("IP literals should be returned but got %v (%v)", addrs, err)
+	}
+}
+
+func TestLookupCNAMERequery(t *testing.T) {
+	zone := &testZone{records: testLookupZone.records, noChase: true}
+	r := newTestResolver(t, zone)
+
+	addrs, err := r.LookupHost(context.Background(), "www.noteip.de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("Expected the addresses of the CNAME target but got %v", addrs)
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	r := newTestResolver(t, testLookupZone)
+
+	_, err := r.LookupHost(context.Background(), "missing.noteip.de.")
+	if !errors.Is(err, ErrNXDomain) {
+		t.Fatalf("Expected NXDOMAIN but got %v", err)
+	}
+
+	_, err = r.LookupMX(context.Background(), "git.noteip.de.")
+	if !errors.Is(err, ErrNoData) {
+		t.Fatalf("Expected NODATA but got %v", err)
+	}
+
+	_, err = r.LookupTXT(context.Background(), "broken.noteip.de.")
+	if !errors.Is(err, ErrServerFailure) {
+		t.Fatalf("Expected a server failure but got %v", err)
+	}
+
+	var lerr *LookupError
+	if !errors.As(err, &lerr) || lerr.Rcode != RCodeServerFailure || lerr.Type != TypeTXT {
+		t.Fatalf("Expected a LookupError but got %#v", err)
+	}
+
+	_, _, err = r.LookupSRV(context.Background(), "xmpp", "tcp", "noteip.de.")
+	if !errors.Is(err, ErrNoData) {
+		t.Fatalf("A SRV target of '.' should be reported as NODATA but got %v", err)
+	}
+}
+
+func TestLookupMX(t *testing.T) {
+	r := newTestResolver(t, testLookupZone)
+
+	mxs, err := r.LookupMX(context.Background(), "noteip.de.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mxs) != 2 || mxs[0].Exchange != "mx1.noteip.de" || mxs[1].Exchange != "mx2.noteip.de" {
+		t.Fatalf("MX records should be sorted by preference but got %v", mxs)
+	}
+}
+
+func TestLookupSRV(t *testing.T) {
+	r := newTestResolver(t, testLookupZone)
+
+	cname, srvs, err := r.LookupSRV(context.Background(), "sip", "udp", "noteip.de.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cname != "_sip._udp.noteip.de" {
+		t.Fatalf("Unexpected name %q", cname)
+	}
+
+	// The record with weight 100 always wins against weight 0.
+	if len(srvs) != 3 || srvs[0].Target != "a.noteip.de" || srvs[1].Target != "b.noteip.de" || srvs[2].Target != "backup.noteip.de" {
+		t.Fatalf("Unexpected order %v", srvs)
+	}
+}
+
+func TestSortSRVWeights(t *testing.T) {
+	counts := map[DNSName]int{}
+	for i := 0; i < 1000; i++ {
+		srvs := []*SRV{
+			{Priority: 1, Weight: 10, Target: "light"},
+			{Priority: 1, Weight: 90, Target: "heavy"},
+			{Priority: 0, Weight: 0, Target: "first"},
+		}
+		SortSRV(srvs)
+		if srvs[0].Target != "first" {
+			t.Fatal("Lower priority has to come first.")
+		}
+		counts[srvs[1].Target]++
+	}
+
+	if counts["heavy"] < 800 || counts["light"] < 50 {
+		t.Fatalf("Selection doesn't follow the weights: %v", counts)
+	}
+}
+
+func TestLookupTXT(t *testing.T) {
+	r := newTestResolver(t, testLookupZone)
+
+	texts, err := r.LookupTXT(context.Background(), "noteip.de.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 1 || texts[0] != "v=spf1 -all" {
+		t.Fatalf("Unexpected texts %q", texts)
+	}
+}
+
+func TestLookupAddr(t *testing.T) {
+	r := newTestResolver(t, testLookupZone)
+
+	names, err := r.LookupAddr(context.Background(), netip.MustParseAddr("192.0.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "git.noteip.de" {
+		t.Fatalf("Unexpected names %q", names)
+	}
+}
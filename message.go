@@ -1,5 +1,10 @@
 package dns
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Message implements the overall message format of the DNS specification.
 // All messages sent by the domain system are divided into 5 sections (some
 // of which are empty in certain cases).
@@ -26,9 +31,16 @@ type Message struct {
 	Additional []*ResourceRecord
 }
 
+// Encode converts the message to the wire format. The section counts of
+// the header are taken from the sections.
 func (msg *Message) Encode() []byte {
 	// Encode Header
-	buf := msg.Header.Encode()
+	hdr := *msg.Header
+	hdr.QuestionCount = uint16(len(msg.Question))
+	hdr.AnswerCount = uint16(len(msg.Answer))
+	hdr.AuthorityCount = uint16(len(msg.Authority))
+	hdr.AdditionalCount = uint16(len(msg.Additional))
+	buf := hdr.Encode()
 
 	// Encode Questions
 	for _, q := range msg.Question {
@@ -53,6 +65,61 @@ func (msg *Message) Encode() []byte {
 	return buf
 }
 
+// Truncate removes records until the wire format of msg fits into size
+// octets. Additional records except the OPT record are removed first,
+// then whole RRsets from the end of the authority and answer sections. The
+// TC flag is only set if answer or authority records had to be removed
+// (RFC 2181 section 9).
+func (msg *Message) Truncate(size int) {
+	for len(msg.Encode()) > size {
+		if i := lastNonOPT(msg.Additional); i >= 0 {
+			msg.Additional = append(msg.Additional[:i], msg.Additional[i+1:]...)
+			continue
+		}
+
+		section := &msg.Authority
+		if len(*section) == 0 {
+			section = &msg.Answer
+		}
+		if len(*section) == 0 {
+			// Only header, question and OPT are left.
+			msg.Header.SetTruncated(true)
+			return
+		}
+		*section = dropLastRRset(*section)
+		msg.Header.SetTruncated(true)
+	}
+
+	msg.Header.AnswerCount = uint16(len(msg.Answer))
+	msg.Header.AuthorityCount = uint16(len(msg.Authority))
+	msg.Header.AdditionalCount = uint16(len(msg.Additional))
+}
+
+func lastNonOPT(records []*ResourceRecord) int {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Type != TypeOPT {
+			return i
+		}
+	}
+	return -1
+}
+
+// dropLastRRset removes the trailing records that share owner, type and
+// class with the last record.
+func dropLastRRset(records []*ResourceRecord) []*ResourceRecord {
+	last := records[len(records)-1]
+	i := len(records) - 1
+	for i > 0 {
+		rr := records[i-1]
+		if rr.Type != last.Type || rr.Class != last.Class || !rr.Name.Equal(last.Name) {
+			break
+		}
+		i--
+	}
+	return records[:i]
+}
+
+// NewMessage returns an empty message with a random Id.
 func NewMessage() (msg *Message, err error) {
 	msg = new(Message)
 
@@ -61,11 +128,16 @@ func NewMessage() (msg *Message, err error) {
 		return nil, err
 	}
 
-	return nil, ErrNotImplemented
+	return msg, nil
 }
 
-func NewQuery(domainName string, queryType uint16, queryClass uint16) (msg *Message, err error) {
+// NewQuery returns a standard query with recursion desired for a single
+// question.
+func NewQuery(domainName string, queryType Type, queryClass Class) (msg *Message, err error) {
 	msg, err = NewMessage()
+	if err != nil {
+		return nil, err
+	}
 
 	msg.Header.SetQuery(true)
 	msg.Header.SetRecursionDesired(true)
@@ -81,8 +153,88 @@ func NewQuery(domainName string, queryType uint16, queryClass uint16) (msg *Mess
 	return msg, nil
 }
 
-// ReadMessage parses a message from b.
-func ReadMessage(b []byte) (msg *Message, err error) {
+// ParseMode selects how ReadMessage deals with malformed or sloppy input.
+type ParseMode int
+
+const (
+	// ParseDefault fails on data that cannot be decoded but ignores
+	// trailing bytes, forward compression pointers and the Z bit.
+	ParseDefault ParseMode = iota
+
+	// ParseStrict additionally rejects trailing bytes after the last
+	// section, record counts that cannot fit into the message, forward
+	// compression pointers and a set Z bit.
+	ParseStrict
+
+	// ParseLenient returns whatever could be decoded before the first
+	// failure together with Diagnostics describing every problem found.
+	ParseLenient
+)
+
+// ParseOption configures ReadMessage.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	mode ParseMode
+}
+
+// WithParseMode selects the ParseMode used by ReadMessage.
+func WithParseMode(mode ParseMode) ParseOption {
+	return func(opts *parseOptions) {
+		opts.mode = mode
+	}
+}
+
+// Diagnostics lists the problems found while parsing a message in
+// ParseLenient mode. It wraps every ParseError, so errors.Is(err,
+// ErrInvalidFormat) holds.
+type Diagnostics []*ParseError
+
+func (d Diagnostics) Error() string {
+	if len(d) == 1 {
+		return d[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more problems)", d[0].Error(), len(d)-1)
+}
+
+// Unwrap returns the individual ParseErrors.
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, len(d))
+	for i, perr := range d {
+		errs[i] = perr
+	}
+	return errs
+}
+
+// Minimal size of a question (root name, type and class) and a resource
+// record (root name, type, class, TTL and length).
+const (
+	minQuestionLen = 5
+	minRecordLen   = 11
+)
+
+// ReadMessage parses a message from b. Decoding failures are reported as
+// *ParseError. In ParseLenient mode the partially decoded message is
+// returned together with Diagnostics; the message is only nil if not even
+// the header could be read.
+func ReadMessage(b []byte, opts ...ParseOption) (msg *Message, err error) {
+	var o parseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	strict := o.mode == ParseStrict
+	lenient := o.mode == ParseLenient
+	var diags Diagnostics
+
+	// problem records a violation of the strict rules. It returns true if
+	// parsing has to stop.
+	problem := func(perr *ParseError) bool {
+		if lenient {
+			diags = append(diags, perr)
+		}
+		return strict
+	}
+
 	msg = new(Message)
 
 	msg.Header, err = ReadHeader(b)
@@ -91,41 +243,70 @@ func ReadMessage(b []byte) (msg *Message, err error) {
 	}
 	nextPos := 12
 
-	for i := 0; i < int(msg.Header.QuestionCount); i++ {
-		q, err, nextIdx := ReadQuestion(b[nextPos:], b)
-		if err != nil {
-			return nil, err
+	if msg.Header.Flags&flagZ != 0 {
+		if perr := newParseError(2, SectionHeader, "Z bit set"); problem(perr) {
+			return nil, perr
 		}
-		nextPos += nextIdx
-		msg.Question = append(msg.Question, q)
 	}
 
-	for i := 0; i < int(msg.Header.AnswerCount); i++ {
-		rr, err, nextIdx := ReadResourceRecord(b[nextPos:], b)
-		if err != nil {
-			return nil, err
+	minLen := minQuestionLen * int(msg.Header.QuestionCount)
+	minLen += minRecordLen * (int(msg.Header.AnswerCount) + int(msg.Header.AuthorityCount) + int(msg.Header.AdditionalCount))
+	if len(b)-nextPos < minLen {
+		if perr := newParseError(4, SectionHeader, "record counts exceed message size"); problem(perr) {
+			return nil, perr
 		}
-		nextPos += nextIdx
-		msg.Answer = append(msg.Answer, rr)
 	}
 
-	for i := 0; i < int(msg.Header.AuthorityCount); i++ {
-		rr, err, nextIdx := ReadResourceRecord(b[nextPos:], b)
-		if err != nil {
+	// fail stops parsing. Lenient mode keeps what was decoded so far.
+	fail := func(err error, section Section, index int) (*Message, error) {
+		err = withContext(err, section, index)
+		if !lenient {
 			return nil, err
 		}
-		nextPos += nextIdx
-		msg.Authority = append(msg.Authority, rr)
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			diags = append(diags, perr)
+		}
+		return msg, diags
 	}
 
-	for i := 0; i < int(msg.Header.AdditionalCount); i++ {
-		rr, err, nextIdx := ReadResourceRecord(b[nextPos:], b)
+	for i := 0; i < int(msg.Header.QuestionCount); i++ {
+		q, err, nextIdx := readQuestion(b[nextPos:], b, strict)
 		if err != nil {
-			return nil, err
+			return fail(err, SectionQuestion, i)
 		}
 		nextPos += nextIdx
-		msg.Additional = append(msg.Additional, rr)
+		msg.Question = append(msg.Question, q)
 	}
 
+	sections := []struct {
+		section Section
+		count   uint16
+		records *[]*ResourceRecord
+	}{
+		{SectionAnswer, msg.Header.AnswerCount, &msg.Answer},
+		{SectionAuthority, msg.Header.AuthorityCount, &msg.Authority},
+		{SectionAdditional, msg.Header.AdditionalCount, &msg.Additional},
+	}
+	for _, s := range sections {
+		for i := 0; i < int(s.count); i++ {
+			rr, err, nextIdx := readResourceRecord(b[nextPos:], b, strict)
+			if err != nil {
+				return fail(err, s.section, i)
+			}
+			nextPos += nextIdx
+			*s.records = append(*s.records, rr)
+		}
+	}
+
+	if nextPos < len(b) {
+		if perr := newParseError(nextPos, SectionUnknown, "trailing data"); problem(perr) {
+			return nil, perr
+		}
+	}
+
+	if len(diags) > 0 {
+		return msg, diags
+	}
 	return msg, nil
 }
@@ -2,6 +2,7 @@ package dns
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -54,3 +55,158 @@ func BenchmarkReadMessage01(b *testing.B) {
 		}
 	}
 }
+
+func TestReadMessageParseError(t *testing.T) {
+	// Cut the message in the middle of the RDATA of the second answer.
+	_, err := ReadMessage(testDataMessageAnswer01[:len(testDataMessageAnswer01)-2])
+
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("Expected ErrInvalidFormat but got %v", err)
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a ParseError but got %T", err)
+	}
+
+	if perr.Section != SectionAnswer || perr.Index != 1 {
+		t.Fatalf("Expected answer #1 but got %s #%d", perr.Section, perr.Index)
+	}
+
+	if perr.Offset != 74 {
+		t.Fatalf("Expected offset 74 but got %d", perr.Offset)
+	}
+}
+
+func TestReadMessageHeaderError(t *testing.T) {
+	_, err := ReadMessage(testDataMessageAnswer01[:5])
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Section != SectionHeader {
+		t.Fatalf("Expected a header ParseError but got %v", err)
+	}
+}
+
+func TestReadMessageStrict(t *testing.T) {
+	if _, err := ReadMessage(testDataMessageAnswer01, WithParseMode(ParseStrict)); err != nil {
+		t.Fatal(err)
+	}
+
+	trailing := append(append([]byte{}, testDataMessageAnswer01...), 0xFF, 0xFF)
+	if _, err := ReadMessage(trailing); err != nil {
+		t.Fatalf("Trailing data should be ignored by default: %v", err)
+	}
+
+	tests := map[string][]byte{
+		"trailing data": trailing,
+		"Z bit set": func() []byte {
+			b := append([]byte{}, testDataMessageAnswer01...)
+			b[3] |= 0x40
+			return b
+		}(),
+		"record counts exceed message size": func() []byte {
+			b := append([]byte{}, testDataMessageAnswer01...)
+			b[11] = 0x20
+			return b
+		}(),
+		// Question name pointing forward to the CNAME target.
+		"forward compression pointer": func() []byte {
+			b := append([]byte{}, testDataMessageAnswer01[:12]...)
+			b = append(b, 0xC0, 0x14, 0x00, 0x01, 0x00, 0x01)
+			b[7] = 0
+			b = append(b, 0x00, 0x00, 0x06, 0x6e, 0x6f, 0x74, 0x65, 0x69, 0x70, 0x00)
+			b[11] = 0
+			return b
+		}(),
+	}
+
+	for reason, data := range tests {
+		msg, err := ReadMessage(data, WithParseMode(ParseStrict))
+		var perr *ParseError
+		if msg != nil || !errors.As(err, &perr) || perr.Reason != reason {
+			t.Fatalf("Expected %q but got %v", reason, err)
+		}
+	}
+}
+
+func TestReadMessageLenient(t *testing.T) {
+	truncated := testDataMessageAnswer01[:len(testDataMessageAnswer01)-2]
+
+	msg, err := ReadMessage(truncated, WithParseMode(ParseLenient))
+	if msg == nil {
+		t.Fatal("Expected a partial message.")
+	}
+
+	if len(msg.Question) != 1 || len(msg.Answer) != 1 {
+		t.Fatalf("Expected 1 question and 1 answer but got %d and %d", len(msg.Question), len(msg.Answer))
+	}
+
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 {
+		t.Fatalf("Expected one diagnostic but got %v", err)
+	}
+
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatal("Diagnostics should wrap ErrInvalidFormat.")
+	}
+
+	if diags[0].Section != SectionAnswer || diags[0].Index != 1 {
+		t.Fatalf("Expected answer #1 but got %s #%d", diags[0].Section, diags[0].Index)
+	}
+
+	if _, err := ReadMessage(testDataMessageAnswer01, WithParseMode(ParseLenient)); err != nil {
+		t.Fatalf("Expected no diagnostics but got %v", err)
+	}
+}
+
+func TestMessageEncodeCounts(t *testing.T) {
+	msg, err := NewQuery("git.noteip.de", TypeA, ClassIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.SetEDNS0(DefaultEDNSUDPSize, true)
+	msg.Answer = append(msg.Answer, testA("git.noteip.de", 192, 0, 2, 1))
+
+	dec, err := ReadMessage(msg.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dec.Header.AnswerCount != 1 || dec.Header.AdditionalCount != 1 {
+		t.Fatalf("Unexpected counts %d and %d", dec.Header.AnswerCount, dec.Header.AdditionalCount)
+	}
+
+	if dec.UDPSize() != DefaultEDNSUDPSize || dec.OPT().TTL&flagDNSSECOK == 0 {
+		t.Fatal("OPT record wasn't encoded correctly.")
+	}
+}
+
+func TestMessageTruncate(t *testing.T) {
+	msg, err := NewQuery("git.noteip.de", TypeA, ClassIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := byte(0); i < 40; i++ {
+		msg.Answer = append(msg.Answer, testA("git.noteip.de", 192, 0, 2, i))
+	}
+	msg.Authority = append(msg.Authority, NewResourceRecord("noteip.de", ClassIN, 60, &NS{Host: "ns.noteip.de"}))
+	msg.Additional = append(msg.Additional, testA("ns.noteip.de", 192, 0, 2, 53))
+	msg.SetEDNS0(DefaultEDNSUDPSize, false)
+
+	full := len(msg.Encode())
+	msg.Truncate(full - 1)
+	if len(msg.Additional) != 1 || msg.Additional[0].Type != TypeOPT {
+		t.Fatalf("Expected only the OPT record to remain but got %v", msg.Additional)
+	}
+	if msg.Header.IsTruncated() {
+		t.Fatal("Dropping additional records must not set TC.")
+	}
+
+	msg.Truncate(minUDPSize / 2)
+	if len(msg.Encode()) > minUDPSize/2 {
+		t.Fatalf("Message still has %d bytes", len(msg.Encode()))
+	}
+	if !msg.Header.IsTruncated() || len(msg.Authority) != 0 || len(msg.Answer) != 0 {
+		t.Fatalf("Expected the answer RRset to be dropped: %v", msg)
+	}
+}
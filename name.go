@@ -0,0 +1,176 @@
+package dns
+
+import (
+	"bytes"
+	"strings"
+)
+
+// labels returns the labels of name in wire format. Invalid names are
+// split the same way Encode does.
+func (name DNSName) labels() [][]byte {
+	labels, err := name.wireLabels()
+	if err != nil {
+		return name.lenientLabels()
+	}
+	return labels
+}
+
+// Labels returns the labels of name from left to right in presentation
+// format. The root has no labels.
+func (name DNSName) Labels() []string {
+	labels := name.labels()
+	if len(labels) == 0 {
+		return nil
+	}
+
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = escapeLabel(label)
+	}
+	return parts
+}
+
+// CountLabels returns the number of labels in name.
+func (name DNSName) CountLabels() int {
+	return len(name.labels())
+}
+
+// Parent returns name without its leftmost label. The parent of the root
+// is the root.
+func (name DNSName) Parent() DNSName {
+	labels := name.labels()
+	if len(labels) == 0 {
+		return RootName
+	}
+	return nameFromLabels(labels[1:])
+}
+
+// Fqdn returns name in presentation format with a trailing dot.
+func (name DNSName) Fqdn() DNSName {
+	n := nameFromLabels(name.labels())
+	if n == RootName {
+		return n
+	}
+	return n + "."
+}
+
+// IsFqdn reports whether name ends with an unescaped dot.
+func (name DNSName) IsFqdn() bool {
+	s := string(name)
+	if !strings.HasSuffix(s, ".") {
+		return false
+	}
+	// The dot is escaped if it is preceded by an odd number of backslashes.
+	backslashes := len(s) - 1 - len(strings.TrimRight(s[:len(s)-1], `\`))
+	return backslashes%2 == 0
+}
+
+// Canonical returns name in lower case without the trailing dot, which is
+// the form DecodeDNSName produces. Names that are Equal have the same
+// canonical form.
+func (name DNSName) Canonical() DNSName {
+	labels := name.labels()
+	for i, label := range labels {
+		labels[i] = lowerLabel(label)
+	}
+	return nameFromLabels(labels)
+}
+
+// Equal reports whether name and other denote the same name. ASCII letters
+// are compared case-insensitively as required by RFC 4343.
+func (name DNSName) Equal(other DNSName) bool {
+	a, b := name.labels(), other.labels()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalLabels(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubdomainOf reports whether name is equal to zone or lies below it.
+// Names are compared on label boundaries, so "xnoteip.de" is not a
+// subdomain of "noteip.de".
+func (name DNSName) IsSubdomainOf(zone DNSName) bool {
+	z := zone.labels()
+	return commonSuffixLen(name.labels(), z) == len(z)
+}
+
+// CommonSuffix returns the longest name that both name and other are
+// subdomains of. The root is returned if they share no labels.
+func (name DNSName) CommonSuffix(other DNSName) DNSName {
+	a := name.labels()
+	n := commonSuffixLen(a, other.labels())
+	return nameFromLabels(a[len(a)-n:])
+}
+
+// commonSuffixLen returns the number of trailing labels a and b have in
+// common.
+func commonSuffixLen(a, b [][]byte) int {
+	n := 0
+	for n < len(a) && n < len(b) {
+		if !equalLabels(a[len(a)-1-n], b[len(b)-1-n]) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// Compare compares name and other in the canonical order defined in
+// RFC 4034 section 6.1: names are sorted by their rightmost label first
+// and labels are compared as lower-cased octet strings. The result is -1
+// if name sorts before other, 1 if it sorts after and 0 if they are equal.
+func (name DNSName) Compare(other DNSName) int {
+	a, b := name.labels(), other.labels()
+	for i := 1; i <= len(a) && i <= len(b); i++ {
+		if c := bytes.Compare(lowerLabel(a[len(a)-i]), lowerLabel(b[len(b)-i])); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// lowerLabel returns label with the ASCII letters in lower case. Other
+// octets are kept as they are, even if they are not valid UTF-8
+// (RFC 4343 section 3).
+func lowerLabel(label []byte) []byte {
+	lower := make([]byte, len(label))
+	for i, c := range label {
+		lower[i] = lowerByte(c)
+	}
+	return lower
+}
+
+// equalLabels reports whether the labels a and b are equal with the ASCII
+// letters compared case-insensitively. Unlike bytes.EqualFold, it does not
+// fold other characters, so "\u212A" (KELVIN SIGN) does not match "k".
+func equalLabels(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if lowerByte(a[i]) != lowerByte(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerByte returns c in lower case if it is an ASCII letter.
+func lowerByte(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,154 @@
+package dns
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestDNSNameLabels(t *testing.T) {
+	labels := DNSName(`My\ Printer._ipp._tcp.local.`).Labels()
+	expected := []string{`My\032Printer`, "_ipp", "_tcp", "local"}
+
+	if len(labels) != len(expected) {
+		t.Fatalf("Expected %q but got %q", expected, labels)
+	}
+	for i := range labels {
+		if labels[i] != expected[i] {
+			t.Fatalf("Expected %q but got %q", expected, labels)
+		}
+	}
+
+	if RootName.Labels() != nil || RootName.CountLabels() != 0 {
+		t.Fatal("The root shouldn't have labels.")
+	}
+}
+
+func TestDNSNameParent(t *testing.T) {
+	tests := map[DNSName]DNSName{
+		"git.noteip.de":  "noteip.de",
+		`a\.b.noteip.de`: "noteip.de",
+		"de.":            ".",
+		".":              ".",
+	}
+
+	for name, expected := range tests {
+		if parent := name.Parent(); parent != expected {
+			t.Fatalf("Parent of %q should be %q but got %q", name, expected, parent)
+		}
+	}
+}
+
+func TestDNSNameFqdn(t *testing.T) {
+	tests := map[DNSName]DNSName{
+		"noteip.de":  "noteip.de.",
+		"noteip.de.": "noteip.de.",
+		`noteip\.`:   `noteip\..`,
+		"":           ".",
+	}
+
+	for name, expected := range tests {
+		if fqdn := name.Fqdn(); fqdn != expected {
+			t.Fatalf("Fqdn of %q should be %q but got %q", name, expected, fqdn)
+		}
+	}
+}
+
+func TestDNSNameEqual(t *testing.T) {
+	if !DNSName("Git.NoteIP.de").Equal("git.noteip.de.") {
+		t.Fatal("Names should be equal.")
+	}
+
+	if !DNSName(`\071it.noteip.de`).Equal("git.noteip.de") {
+		t.Fatal("Escaped names should be equal.")
+	}
+
+	if DNSName(`git\.noteip.de`).Equal("git.noteip.de") {
+		t.Fatal("Names shouldn't be equal.")
+	}
+
+	// Only ASCII letters are case-insensitive (RFC 4343), so KELVIN SIGN
+	// and LATIN SMALL LETTER LONG S don't match their Unicode folds.
+	for _, names := range [][2]DNSName{
+		{"\u212Aexample.com", "kexample.com"},
+		{"\u017Fexample.com", "sexample.com"},
+	} {
+		if names[0].Equal(names[1]) || names[1].Equal(names[0]) {
+			t.Fatalf("%s and %s shouldn't be equal.", names[0], names[1])
+		}
+		if names[0].Compare(names[1]) == 0 {
+			t.Fatalf("%s and %s shouldn't compare equal.", names[0], names[1])
+		}
+	}
+
+	if DNSName("Git.NoteIP.de").Canonical() != "git.noteip.de" {
+		t.Fatal("Canonical form should be lower case.")
+	}
+	if c := DNSName(`\200A.noteip.de`).Canonical(); c != `\200a.noteip.de` {
+		t.Fatalf("Only ASCII letters should be lowered, got %s", c)
+	}
+}
+
+func TestDNSNameIsSubdomainOf(t *testing.T) {
+	tests := []struct {
+		name, zone DNSName
+		expected   bool
+	}{
+		{"git.noteip.de", "noteip.de", true},
+		{"git.noteip.de", "NOTEIP.DE.", true},
+		{"noteip.de", "noteip.de", true},
+		{"noteip.de", ".", true},
+		{"xnoteip.de", "noteip.de", false},
+		{`git\.noteip.de`, "noteip.de", false},
+		{"noteip.de", "git.noteip.de", false},
+		{"www.\u212Aexample.com", "kexample.com", false},
+	}
+
+	for _, test := range tests {
+		if test.name.IsSubdomainOf(test.zone) != test.expected {
+			t.Fatalf("%q.IsSubdomainOf(%q) should be %v", test.name, test.zone, test.expected)
+		}
+	}
+}
+
+func TestDNSNameCommonSuffix(t *testing.T) {
+	if s := DNSName("git.noteip.de").CommonSuffix("www.NOTEIP.de."); s != "noteip.de" {
+		t.Fatalf("Expected 'noteip.de' but got %q", s)
+	}
+
+	if s := DNSName("noteip.de").CommonSuffix("noteip.org"); s != "." {
+		t.Fatalf("Expected the root but got %q", s)
+	}
+}
+
+func TestDNSNameCompare(t *testing.T) {
+	// Example from RFC 4034 section 6.1.
+	expected := []DNSName{
+		"example",
+		"a.example",
+		"yljkjljk.a.example",
+		"Z.a.example",
+		"zABC.a.EXAMPLE",
+		"z.example",
+		`\001.z.example`,
+		"*.z.example",
+		`\200.z.example`,
+	}
+
+	names := []DNSName{
+		expected[3], expected[8], expected[0], expected[5], expected[1],
+		expected[7], expected[4], expected[2], expected[6],
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].Compare(names[j]) < 0
+	})
+
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("Wrong order at %d: expected %q but got %q", i, expected[i], names[i])
+		}
+	}
+
+	if DNSName("NoteIP.de").Compare("noteip.de.") != 0 {
+		t.Fatal("Names should compare equal.")
+	}
+}
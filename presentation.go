@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// FormatOptions controls the presentation format produced by the Format
+// methods.
+type FormatOptions struct {
+	// Unicode renders A-labels ("xn--") as Unicode.
+	Unicode bool
+}
+
+// formatName returns name fully qualified as it appears in zone files.
+func (opts FormatOptions) formatName(name DNSName) string {
+	fqdn := name.Fqdn()
+	if opts.Unicode && fqdn != RootName {
+		if u, err := fqdn.ToUnicode(); err == nil {
+			return u + "."
+		}
+	}
+	return string(fqdn)
+}
+
+// String returns the header flags in the style of dig.
+func (hdr *Header) String() string {
+	flags := []string{}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{hdr.IsResponse(), "qr"},
+		{hdr.IsAuthoritativeAnswer(), "aa"},
+		{hdr.IsTruncated(), "tc"},
+		{hdr.IsRecursionDesired(), "rd"},
+		{hdr.IsRecursionAvailable(), "ra"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+
+	return fmt.Sprintf(";; opcode: %s, status: %s, id: %d\n;; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d",
+		hdr.Opcode(), hdr.ResponseCode(), hdr.Id, strings.Join(flags, " "),
+		hdr.QuestionCount, hdr.AnswerCount, hdr.AuthorityCount, hdr.AdditionalCount)
+}
+
+// String returns q in presentation format.
+func (q *Question) String() string {
+	return q.Format(FormatOptions{})
+}
+
+// Format returns q in presentation format.
+func (q *Question) Format(opts FormatOptions) string {
+	return fmt.Sprintf(";%s\t%s\t%s", opts.formatName(q.Name), q.Class, q.Type)
+}
+
+// String returns rr in presentation format.
+func (rr *ResourceRecord) String() string {
+	return rr.Format(FormatOptions{})
+}
+
+// Format returns rr in presentation format. The RDATA of types without a
+// typed representation is written in the generic format of RFC 3597.
+func (rr *ResourceRecord) Format(opts FormatOptions) string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", opts.formatName(rr.Name), rr.TTL, rr.Class, rr.Type, rr.formatData(opts))
+}
+
+func (rr *ResourceRecord) formatData(opts FormatOptions) string {
+	if data, err := rr.Rdata(); err == nil {
+		return data.format(opts)
+	}
+	if len(rr.Data) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %s`, len(rr.Data), hex.EncodeToString(rr.Data))
+}
+
+// String returns msg in presentation format in the style of dig.
+func (msg *Message) String() string {
+	return msg.Format(FormatOptions{})
+}
+
+// Format returns msg in presentation format in the style of dig.
+func (msg *Message) Format(opts FormatOptions) string {
+	var sb strings.Builder
+	if msg.Header != nil {
+		sb.WriteString(msg.Header.String())
+		sb.WriteString("\n")
+	}
+
+	if len(msg.Question) > 0 {
+		sb.WriteString("\n;; QUESTION SECTION:\n")
+		for _, q := range msg.Question {
+			sb.WriteString(q.Format(opts))
+			sb.WriteString("\n")
+		}
+	}
+
+	for _, section := range []struct {
+		name    string
+		records []*ResourceRecord
+	}{
+		{"ANSWER", msg.Answer},
+		{"AUTHORITY", msg.Authority},
+		{"ADDITIONAL", msg.Additional},
+	} {
+		if len(section.records) == 0 {
+			continue
+		}
+		sb.WriteString("\n;; " + section.name + " SECTION:\n")
+		for _, rr := range section.records {
+			sb.WriteString(rr.Format(opts))
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMessageString(t *testing.T) {
+	msg, err := ReadMessage(testDataMessageAnswer01)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := msg.String()
+	for _, expected := range []string{
+		";; opcode: QUERY, status: NOERROR, id: 34906",
+		";; flags: qr rd ra; QUERY: 1, ANSWER: 2, AUTHORITY: 0, ADDITIONAL: 0",
+		";git.noteip.de.\tIN\tA",
+		"git.noteip.de.\t86400\tIN\tCNAME\tnoteip.dyndns.org.",
+		"noteip.dyndns.org.\t60\tIN\tA\t84.183.116.99",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, s)
+		}
+	}
+}
+
+func TestQuestionFormatUnicode(t *testing.T) {
+	name, err := ToASCII("bücher.noteip.de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQuestion(string(name), TypeMX, ClassIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := q.String(); s != ";xn--bcher-kva.noteip.de.\tIN\tMX" {
+		t.Fatalf("Unexpected presentation format %q", s)
+	}
+
+	if s := q.Format(FormatOptions{Unicode: true}); s != ";bücher.noteip.de.\tIN\tMX" {
+		t.Fatalf("Unexpected presentation format %q", s)
+	}
+}
+
+func TestResourceRecordStringGeneric(t *testing.T) {
+	rr := &ResourceRecord{Name: "noteip.de", Type: 65534, Class: ClassIN, TTL: 60, Data: []byte{0xCA, 0xFE}}
+
+	if s := rr.String(); s != "noteip.de.\t60\tIN\tTYPE65534\t\\# 2 cafe" {
+		t.Fatalf("Unexpected presentation format %q", s)
+	}
+}
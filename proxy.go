@@ -0,0 +1,67 @@
+package dns
+
+import (
+	"context"
+	"math/rand/v2"
+)
+
+// Proxy forwards queries to Upstream. Each query is sent with a fresh
+// random Id and with an OPT record advertising UDPSize, keeping the DNSSEC
+// OK bit of the client, so that responses of any transport come back as
+// large as the proxy can take them. The response gets the Id of the
+// client query back; fitting it to the transport of the client is left to
+// the Server.
+type Proxy struct {
+	Upstream Exchanger
+
+	// UDPSize is advertised to the upstream. It defaults to
+	// DefaultEDNSUDPSize.
+	UDPSize uint16
+}
+
+// Exchange forwards q to the upstream.
+func (p *Proxy) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	hdr := *q.Header
+	fwd := &Message{Header: &hdr, Question: q.Question}
+	fwd.Header.Id = uint16(rand.UintN(0x10000))
+	for _, rr := range q.Additional {
+		if rr.Type != TypeOPT {
+			fwd.Additional = append(fwd.Additional, rr)
+		}
+	}
+	size := p.UDPSize
+	if size < minUDPSize {
+		size = DefaultEDNSUDPSize
+	}
+	opt := q.OPT()
+	fwd.SetEDNS0(size, opt != nil && opt.TTL&flagDNSSECOK != 0)
+
+	resp, err := p.Upstream.Exchange(ctx, fwd)
+	if err != nil {
+		return nil, err
+	}
+	rhdr := *resp.Header
+	out := *resp
+	out.Header = &rhdr
+	out.Header.Id = q.Header.Id
+	return &out, nil
+}
+
+// NewProxyServer returns a Server for addr that forwards queries to the
+// fastest of upstreams, given as for NewUpstream, and caches up to
+// cacheSize responses.
+func NewProxyServer(addr string, upstreams []string, cacheSize int) (*Server, error) {
+	if len(upstreams) == 0 {
+		return nil, ErrNoServers
+	}
+	pool := NewPool()
+	for _, spec := range upstreams {
+		up, err := NewUpstream(spec)
+		if err != nil {
+			return nil, err
+		}
+		pool.Upstreams = append(pool.Upstreams, up)
+	}
+	cache := NewCache(&Proxy{Upstream: pool}, cacheSize)
+	return &Server{Addr: addr, Handler: cache}, nil
+}
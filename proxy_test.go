@@ -0,0 +1,116 @@
+package dns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyExchange(t *testing.T) {
+	var mu sync.Mutex
+	var seen []*Message
+	up := handlerFunc(func(ctx context.Context, q *Message) (*Message, error) {
+		mu.Lock()
+		seen = append(seen, q)
+		mu.Unlock()
+		return testReply(q, RCodeNoError, testA(q.Question[0].Name, 192, 0, 2, 1)), nil
+	})
+
+	p := &Proxy{Upstream: up, UDPSize: 4096}
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	q.Header.Id = 4711
+	q.SetEDNS0(1024, true)
+	resp, err := p.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Id != 4711 || len(resp.Answer) != 1 {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+
+	fwd := seen[0]
+	if opt := fwd.OPT(); opt == nil || fwd.UDPSize() != 4096 || opt.TTL&flagDNSSECOK == 0 {
+		t.Fatalf("Forwarded query has OPT %v", opt)
+	}
+	if q.UDPSize() != 1024 || q.Header.Id != 4711 {
+		t.Fatal("The client query must not be modified.")
+	}
+}
+
+func TestProxyServer(t *testing.T) {
+	var queries atomic.Int32
+	upstream := startTestServer(t, func(q *Message, network string) *Message {
+		queries.Add(1)
+		var answers []*ResourceRecord
+		for i := range 60 {
+			answers = append(answers, testA(q.Question[0].Name, 192, 0, 2, byte(i)))
+		}
+		return testReply(q, RCodeNoError, answers...)
+	})
+
+	s, err := NewProxyServer("", []string{"tcp://" + upstream}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTest(t, s)
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	c := &Client{Timeout: time.Second}
+	for range 2 {
+		resp, err := c.Exchange(context.Background(), q, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Id != q.Header.Id || len(resp.Answer) != 60 {
+			t.Fatalf("Unexpected response with %d answers", len(resp.Answer))
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Fatalf("Expected 1 upstream query thanks to the cache, got %d", n)
+	}
+
+	if _, err := NewProxyServer("", nil, 100); err == nil {
+		t.Fatal("Expected an error without upstreams.")
+	}
+	if _, err := NewProxyServer("", []string{"quic://dns.example"}, 100); err == nil {
+		t.Fatal("Expected an error for an unknown scheme.")
+	}
+}
+
+func TestProxyHTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		q, err := ReadMessage(b)
+		if err != nil {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		var answers []*ResourceRecord
+		for i := range 60 {
+			answers = append(answers, testA(q.Question[0].Name, 192, 0, 2, byte(i)))
+		}
+		w.Header().Set("Content-Type", dnsMessageType)
+		w.Write(testReply(q, RCodeNoError, answers...).Encode())
+	}))
+	defer srv.Close()
+
+	up := &HTTPSClient{URL: srv.URL + "/dns-query", Client: srv.Client()}
+	addr := serveTest(t, &Server{Handler: &Proxy{Upstream: up}})
+
+	// The upstream response does not fit into a UDP response without
+	// EDNS, so the client has to retry over TCP.
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	c := &Client{Timeout: time.Second}
+	resp, err := c.Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.IsTruncated() || len(resp.Answer) != 60 {
+		t.Fatalf("Expected 60 answers, got %d", len(resp.Answer))
+	}
+}
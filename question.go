@@ -8,8 +8,8 @@ package dns
 // entries; for all other responses it contains a single entry.
 type Question struct {
 	Name  DNSName
-	Type  uint16
-	Class uint16
+	Type  Type
+	Class Class
 }
 
 func (q *Question) Encode(rawMessage []byte) (newRaw []byte) {
@@ -18,20 +18,24 @@ func (q *Question) Encode(rawMessage []byte) (newRaw []byte) {
 
 	buf := make([]byte, 2)
 	// encode Type
-	uint16ToByte(q.Type, buf)
+	uint16ToByte(uint16(q.Type), buf)
 	newRaw = append(newRaw[:], buf...)
 
 	// encode Class
-	uint16ToByte(q.Class, buf)
+	uint16ToByte(uint16(q.Class), buf)
 	newRaw = append(newRaw[:], buf...)
 
 	return
 }
 
-func NewQuestion(domainStr string, qType uint16, qClass uint16) (*Question, error) {
+func NewQuestion(domainStr string, qType Type, qClass Class) (*Question, error) {
 	q := new(Question)
 
-	q.Name = DNSName(domainStr)
+	name, err := ParseDNSName(domainStr)
+	if err != nil {
+		return nil, err
+	}
+	q.Name = name
 	q.Type = qType
 	q.Class = qClass
 
@@ -41,18 +45,24 @@ func NewQuestion(domainStr string, qType uint16, qClass uint16) (*Question, erro
 // ReadQuestion parses the question part of the received message. b is the
 // start of the question part and rawMessage is the whole message.
 func ReadQuestion(b []byte, rawMessage []byte) (q *Question, err error, nextIdx int) {
-	q = new(Question)
+	return readQuestion(b, rawMessage, false)
+}
 
-	q.Name, err, nextIdx = DecodeDNSName(b, rawMessage)
+func readQuestion(b []byte, rawMessage []byte, strict bool) (q *Question, err error, nextIdx int) {
+	q = new(Question)
 
-	if err != nil {
-		return nil, err, 0
+	var perr *ParseError
+	base := offsetOf(b, rawMessage)
+	q.Name, nextIdx, perr = decodeName(b, rawMessage, base, strict)
+	if perr != nil {
+		perr.Section = SectionQuestion
+		return nil, perr, 0
 	}
 	if len(b) < nextIdx+4 {
-		return nil, ErrInvalidFormat, 0
+		return nil, newParseError(base+nextIdx, SectionQuestion, "truncated question"), 0
 	}
-	q.Type = byteToUint16(b[nextIdx : nextIdx+2])
-	q.Class = byteToUint16(b[nextIdx+2 : nextIdx+4])
+	q.Type = Type(byteToUint16(b[nextIdx : nextIdx+2]))
+	q.Class = Class(byteToUint16(b[nextIdx+2 : nextIdx+4]))
 	nextIdx += 4
 
 	return
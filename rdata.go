@@ -0,0 +1,440 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Rdata is the typed form of the Data field of a resource record.
+type Rdata interface {
+	// Type returns the record type the data belongs to.
+	Type() Type
+
+	// Encode appends the data in wire format to rawMsg. Names are not
+	// compressed.
+	Encode(rawMsg []byte) (newRaw []byte)
+
+	// String returns the data in presentation format.
+	String() string
+
+	format(opts FormatOptions) string
+}
+
+// A holds the data of an A record.
+type A struct {
+	Addr netip.Addr
+}
+
+func (a *A) Type() Type { return TypeA }
+
+// Encode appends the IPv4 address. Nothing is appended if Addr is no IPv4
+// address, so the record fails to decode instead of carrying a wrong one.
+func (a *A) Encode(rawMsg []byte) []byte {
+	addr := a.Addr.Unmap()
+	if !addr.Is4() {
+		return rawMsg
+	}
+	b := addr.As4()
+	return append(rawMsg, b[:]...)
+}
+
+func (a *A) String() string                   { return a.format(FormatOptions{}) }
+func (a *A) format(opts FormatOptions) string { return a.Addr.String() }
+
+// AAAA holds the data of an AAAA record.
+type AAAA struct {
+	Addr netip.Addr
+}
+
+func (a *AAAA) Type() Type { return TypeAAAA }
+
+func (a *AAAA) Encode(rawMsg []byte) []byte {
+	b := a.Addr.As16()
+	return append(rawMsg, b[:]...)
+}
+
+func (a *AAAA) String() string                   { return a.format(FormatOptions{}) }
+func (a *AAAA) format(opts FormatOptions) string { return a.Addr.String() }
+
+// NS holds the data of an NS record.
+type NS struct {
+	Host DNSName
+}
+
+func (ns *NS) Type() Type                       { return TypeNS }
+func (ns *NS) Encode(rawMsg []byte) []byte      { return appendName(rawMsg, ns.Host) }
+func (ns *NS) String() string                   { return ns.format(FormatOptions{}) }
+func (ns *NS) format(opts FormatOptions) string { return opts.formatName(ns.Host) }
+
+// CNAME holds the data of a CNAME record.
+type CNAME struct {
+	Target DNSName
+}
+
+func (c *CNAME) Type() Type                       { return TypeCNAME }
+func (c *CNAME) Encode(rawMsg []byte) []byte      { return appendName(rawMsg, c.Target) }
+func (c *CNAME) String() string                   { return c.format(FormatOptions{}) }
+func (c *CNAME) format(opts FormatOptions) string { return opts.formatName(c.Target) }
+
+// DNAME holds the data of a DNAME record, which redirects the names below
+// its owner to the same names below Target (RFC 6672).
+type DNAME struct {
+	Target DNSName
+}
+
+func (d *DNAME) Type() Type                       { return TypeDNAME }
+func (d *DNAME) Encode(rawMsg []byte) []byte      { return appendName(rawMsg, d.Target) }
+func (d *DNAME) String() string                   { return d.format(FormatOptions{}) }
+func (d *DNAME) format(opts FormatOptions) string { return opts.formatName(d.Target) }
+
+// PTR holds the data of a PTR record.
+type PTR struct {
+	Name DNSName
+}
+
+func (p *PTR) Type() Type                       { return TypePTR }
+func (p *PTR) Encode(rawMsg []byte) []byte      { return appendName(rawMsg, p.Name) }
+func (p *PTR) String() string                   { return p.format(FormatOptions{}) }
+func (p *PTR) format(opts FormatOptions) string { return opts.formatName(p.Name) }
+
+// MX holds the data of an MX record.
+type MX struct {
+	Preference uint16
+	Exchange   DNSName
+}
+
+func (mx *MX) Type() Type { return TypeMX }
+
+func (mx *MX) Encode(rawMsg []byte) []byte {
+	return appendName(appendUint16(rawMsg, mx.Preference), mx.Exchange)
+}
+
+func (mx *MX) String() string { return mx.format(FormatOptions{}) }
+
+func (mx *MX) format(opts FormatOptions) string {
+	return strconv.Itoa(int(mx.Preference)) + " " + opts.formatName(mx.Exchange)
+}
+
+// TXT holds the character strings of a TXT record.
+type TXT struct {
+	Strings []string
+}
+
+func (txt *TXT) Type() Type { return TypeTXT }
+
+func (txt *TXT) Encode(rawMsg []byte) []byte {
+	for _, s := range txt.Strings {
+		rawMsg = appendCharacterString(rawMsg, s)
+	}
+	return rawMsg
+}
+
+func (txt *TXT) String() string { return txt.format(FormatOptions{}) }
+
+func (txt *TXT) format(opts FormatOptions) string {
+	parts := make([]string, len(txt.Strings))
+	for i, s := range txt.Strings {
+		parts[i] = quoteCharacterString(s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Joined returns all character strings concatenated. Long texts such as
+// DKIM keys are split into several strings of at most 255 octets.
+func (txt *TXT) Joined() string {
+	return strings.Join(txt.Strings, "")
+}
+
+// SRV holds the data of an SRV record (RFC 2782).
+type SRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   DNSName
+}
+
+func (srv *SRV) Type() Type { return TypeSRV }
+
+func (srv *SRV) Encode(rawMsg []byte) []byte {
+	rawMsg = appendUint16(rawMsg, srv.Priority)
+	rawMsg = appendUint16(rawMsg, srv.Weight)
+	rawMsg = appendUint16(rawMsg, srv.Port)
+	return appendName(rawMsg, srv.Target)
+}
+
+func (srv *SRV) String() string { return srv.format(FormatOptions{}) }
+
+func (srv *SRV) format(opts FormatOptions) string {
+	return fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, opts.formatName(srv.Target))
+}
+
+// SOA holds the data of an SOA record.
+type SOA struct {
+	// MName is the primary name server of the zone.
+	MName DNSName
+
+	// RName is the mailbox of the person responsible for the zone.
+	RName DNSName
+
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+
+	// Minimum is the TTL of negative responses (RFC 2308).
+	Minimum uint32
+}
+
+func (soa *SOA) Type() Type { return TypeSOA }
+
+func (soa *SOA) Encode(rawMsg []byte) []byte {
+	rawMsg = appendName(rawMsg, soa.MName)
+	rawMsg = appendName(rawMsg, soa.RName)
+	for _, v := range []uint32{soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum} {
+		rawMsg = appendUint32(rawMsg, v)
+	}
+	return rawMsg
+}
+
+func (soa *SOA) String() string { return soa.format(FormatOptions{}) }
+
+func (soa *SOA) format(opts FormatOptions) string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", opts.formatName(soa.MName), opts.formatName(soa.RName),
+		soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum)
+}
+
+// rdataDecoders decode the uncompressed Data field of the supported types.
+var rdataDecoders = map[Type]func(data []byte) (Rdata, error){
+	TypeA: func(data []byte) (Rdata, error) {
+		if len(data) != 4 {
+			return nil, ErrInvalidFormat
+		}
+		return &A{Addr: netip.AddrFrom4([4]byte(data))}, nil
+	},
+	TypeAAAA: func(data []byte) (Rdata, error) {
+		if len(data) != 16 {
+			return nil, ErrInvalidFormat
+		}
+		return &AAAA{Addr: netip.AddrFrom16([16]byte(data))}, nil
+	},
+	TypeNS: func(data []byte) (Rdata, error) {
+		name, err := readRdataNames(data, 0, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &NS{Host: name[0]}, nil
+	},
+	TypeCNAME: func(data []byte) (Rdata, error) {
+		name, err := readRdataNames(data, 0, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &CNAME{Target: name[0]}, nil
+	},
+	TypeDNAME: func(data []byte) (Rdata, error) {
+		name, err := readRdataNames(data, 0, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &DNAME{Target: name[0]}, nil
+	},
+	TypePTR: func(data []byte) (Rdata, error) {
+		name, err := readRdataNames(data, 0, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &PTR{Name: name[0]}, nil
+	},
+	TypeMX: func(data []byte) (Rdata, error) {
+		name, err := readRdataNames(data, 2, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &MX{Preference: byteToUint16(data), Exchange: name[0]}, nil
+	},
+	TypeSRV: func(data []byte) (Rdata, error) {
+		name, err := readRdataNames(data, 6, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &SRV{
+			Priority: byteToUint16(data),
+			Weight:   byteToUint16(data[2:]),
+			Port:     byteToUint16(data[4:]),
+			Target:   name[0],
+		}, nil
+	},
+	TypeSOA: func(data []byte) (Rdata, error) {
+		names, err := readRdataNames(data, 0, 2, 20)
+		if err != nil {
+			return nil, err
+		}
+		v := data[len(data)-20:]
+		return &SOA{
+			MName:   names[0],
+			RName:   names[1],
+			Serial:  byteToUint32(v),
+			Refresh: byteToUint32(v[4:]),
+			Retry:   byteToUint32(v[8:]),
+			Expire:  byteToUint32(v[12:]),
+			Minimum: byteToUint32(v[16:]),
+		}, nil
+	},
+	TypeTXT: func(data []byte) (Rdata, error) {
+		txt := new(TXT)
+		for len(data) > 0 {
+			l := int(data[0])
+			if 1+l > len(data) {
+				return nil, ErrInvalidFormat
+			}
+			txt.Strings = append(txt.Strings, string(data[1:1+l]))
+			data = data[1+l:]
+		}
+		return txt, nil
+	},
+}
+
+// readRdataNames decodes count uncompressed names that follow skip bytes
+// of fixed data. Exactly tail bytes have to follow the names.
+func readRdataNames(data []byte, skip int, count int, tail int) ([]DNSName, error) {
+	if len(data) < skip {
+		return nil, ErrInvalidFormat
+	}
+	pos := skip
+	names := make([]DNSName, count)
+	for i := range names {
+		labels, next, perr := decodeLabels(data[pos:], nil, 0, false)
+		if perr != nil {
+			return nil, ErrInvalidFormat
+		}
+		names[i] = nameFromLabels(labels)
+		pos += next
+	}
+	if len(data)-pos != tail {
+		return nil, ErrInvalidFormat
+	}
+	return names, nil
+}
+
+// Rdata decodes the Data field of rr. ErrNotImplemented is returned for
+// types without a typed representation.
+func (rr *ResourceRecord) Rdata() (Rdata, error) {
+	decode, ok := rdataDecoders[rr.Type]
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return decode(rr.Data)
+}
+
+// NewResourceRecord returns a record for name with the given data.
+func NewResourceRecord(name DNSName, class Class, ttl uint32, data Rdata) *ResourceRecord {
+	rr := &ResourceRecord{
+		Name:  name,
+		Type:  data.Type(),
+		Class: class,
+		TTL:   ttl,
+		Data:  data.Encode(nil),
+	}
+	rr.Length = uint16(len(rr.Data))
+	return rr
+}
+
+func appendName(rawMsg []byte, name DNSName) []byte {
+	return appendLabels(rawMsg, name.labels())
+}
+
+func appendLabels(rawMsg []byte, labels [][]byte) []byte {
+	for _, label := range labels {
+		rawMsg = append(rawMsg, byte(len(label)))
+		rawMsg = append(rawMsg, label...)
+	}
+	return append(rawMsg, 0)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// appendCharacterString appends s as <character-string>. Strings longer
+// than 255 octets are truncated.
+func appendCharacterString(b []byte, s string) []byte {
+	if len(s) > 0xFF {
+		s = s[:0xFF]
+	}
+	b = append(b, byte(len(s)))
+	return append(b, s...)
+}
+
+// quoteCharacterString returns s quoted for the presentation format.
+func quoteCharacterString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c > 0x7E:
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// compressedNames describes the layout of the types whose RDATA may
+// contain compressed names (RFC 3597 section 4): the number of fixed
+// octets before the names and the number of names.
+var compressedNames = map[Type]struct{ skip, count int }{
+	TypeNS:    {0, 1},
+	TypeMD:    {0, 1},
+	TypeMF:    {0, 1},
+	TypeCNAME: {0, 1},
+	TypeSOA:   {0, 2},
+	TypeMB:    {0, 1},
+	TypeMG:    {0, 1},
+	TypeMR:    {0, 1},
+	TypePTR:   {0, 1},
+	TypeMINFO: {0, 2},
+	TypeMX:    {2, 1},
+	TypeRP:    {0, 2},
+	TypeAFSDB: {2, 1},
+	TypeRT:    {2, 1},
+	TypePX:    {2, 2},
+	TypeKX:    {2, 1},
+	TypeSRV:   {6, 1},
+}
+
+// decompressRdata returns rdata with all compression pointers resolved,
+// so that it can be decoded without the message. base is the position of
+// rdata within rawMsg.
+func decompressRdata(t Type, rdata []byte, rawMsg []byte, base int, strict bool) ([]byte, *ParseError) {
+	layout, ok := compressedNames[t]
+	if !ok {
+		return rdata, nil
+	}
+
+	pos := layout.skip
+	if pos > len(rdata) {
+		return nil, newParseError(base, SectionUnknown, "invalid RDATA")
+	}
+	data := append([]byte(nil), rdata[:pos]...)
+	for i := 0; i < layout.count; i++ {
+		labels, next, perr := decodeLabels(rdata[pos:], rawMsg, base+pos, strict)
+		if perr != nil {
+			return nil, perr
+		}
+		data = appendLabels(data, labels)
+		pos += next
+	}
+	return append(data, rdata[pos:]...), nil
+}
@@ -0,0 +1,123 @@
+package dns
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestRdataRoundTrip(t *testing.T) {
+	tests := []Rdata{
+		&A{Addr: netip.MustParseAddr("192.0.2.1")},
+		&AAAA{Addr: netip.MustParseAddr("2001:db8::1")},
+		&NS{Host: "ns1.noteip.de"},
+		&CNAME{Target: "noteip.dyndns.org"},
+		&DNAME{Target: "noteip.net"},
+		&PTR{Name: "git.noteip.de"},
+		&MX{Preference: 10, Exchange: "mail.noteip.de"},
+		&TXT{Strings: []string{"v=spf1 ", "-all"}},
+		&SRV{Priority: 10, Weight: 60, Port: 5060, Target: "sip.noteip.de"},
+		&SOA{MName: "ns1.noteip.de", RName: "hostmaster.noteip.de", Serial: 2024010101, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: 300},
+	}
+
+	for _, data := range tests {
+		rr := NewResourceRecord("noteip.de", ClassIN, 300, data)
+		if rr.Type != data.Type() || int(rr.Length) != len(rr.Data) {
+			t.Fatalf("Unexpected record %v", rr)
+		}
+
+		dec, err := rr.Rdata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dec.String() != data.String() {
+			t.Fatalf("Expected %q but got %q", data, dec)
+		}
+	}
+}
+
+func TestRdataString(t *testing.T) {
+	tests := map[string]Rdata{
+		"10 mail.noteip.de.":        &MX{Preference: 10, Exchange: "mail.noteip.de"},
+		`"v=spf1 " "a\"b\\c\009"`:   &TXT{Strings: []string{"v=spf1 ", "a\"b\\c\t"}},
+		"10 60 5060 sip.noteip.de.": &SRV{Priority: 10, Weight: 60, Port: 5060, Target: "sip.noteip.de"},
+	}
+
+	for expected, data := range tests {
+		if data.String() != expected {
+			t.Fatalf("Expected %q but got %q", expected, data)
+		}
+	}
+
+	if joined := (&TXT{Strings: []string{"v=DKIM1; ", "p=abc"}}).Joined(); joined != "v=DKIM1; p=abc" {
+		t.Fatalf("Unexpected joined text %q", joined)
+	}
+}
+
+func TestRdataInvalid(t *testing.T) {
+	tests := []*ResourceRecord{
+		{Type: TypeA, Data: []byte{1, 2, 3}},
+		{Type: TypeMX, Data: []byte{0}},
+		{Type: TypeTXT, Data: []byte{5, 'a'}},
+		{Type: TypeSOA, Data: []byte{0, 0, 1, 2}},
+	}
+
+	for _, rr := range tests {
+		if _, err := rr.Rdata(); err != ErrInvalidFormat {
+			t.Fatalf("Rdata of %s should be invalid but got %v", rr.Type, err)
+		}
+	}
+
+	rr := &ResourceRecord{Type: TypeHINFO}
+	if _, err := rr.Rdata(); err != ErrNotImplemented {
+		t.Fatalf("Expected ErrNotImplemented but got %v", err)
+	}
+}
+
+func TestRdataEncodeInvalidA(t *testing.T) {
+	for _, addr := range []netip.Addr{{}, netip.MustParseAddr("2001:db8::1")} {
+		rr := NewResourceRecord("noteip.de", ClassIN, 300, &A{Addr: addr})
+		if len(rr.Data) != 0 {
+			t.Fatalf("Expected no data for %v, got %v", addr, rr.Data)
+		}
+		if _, err := rr.Rdata(); err == nil {
+			t.Fatalf("Expected an error decoding the A record for %v", addr)
+		}
+	}
+
+	rr := NewResourceRecord("noteip.de", ClassIN, 300, &A{Addr: netip.MustParseAddr("::ffff:192.0.2.1")})
+	if !bytes.Equal(rr.Data, []byte{192, 0, 2, 1}) {
+		t.Fatalf("Expected the unmapped address, got %v", rr.Data)
+	}
+}
+
+func TestReadResourceRecordDecompress(t *testing.T) {
+	// MX record for noteip.de whose exchange "mail.noteip.de" points to
+	// the owner name.
+	msg := []byte{
+		0x06, 'n', 'o', 't', 'e', 'i', 'p', 0x02, 'd', 'e', 0x00,
+		0x00, 0x0F, 0x00, 0x01, 0x00, 0x00, 0x0E, 0x10, 0x00, 0x09,
+		0x00, 0x0A, 0x04, 'm', 'a', 'i', 'l', 0xC0, 0x00,
+	}
+
+	rr, err, nextIdx := ReadResourceRecord(msg, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextIdx != len(msg) {
+		t.Fatalf("Next Index should be %d but got %d", len(msg), nextIdx)
+	}
+
+	expected := []byte{0x00, 0x0A, 0x04, 'm', 'a', 'i', 'l', 0x06, 'n', 'o', 't', 'e', 'i', 'p', 0x02, 'd', 'e', 0x00}
+	if !bytes.Equal(rr.Data, expected) || int(rr.Length) != len(expected) {
+		t.Fatalf("Expected decompressed data\n\t%x but got\n\t%x", expected, rr.Data)
+	}
+
+	data, err := rr.Rdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mx := data.(*MX); mx.Preference != 10 || mx.Exchange != "mail.noteip.de" {
+		t.Fatalf("Unexpected MX %v", mx)
+	}
+}
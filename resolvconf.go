@@ -0,0 +1,189 @@
+package dns
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultResolvConf is the location of the system resolver configuration.
+const DefaultResolvConf = "/etc/resolv.conf"
+
+// ResolverConfig holds the stub resolver settings found in resolv.conf(5).
+type ResolverConfig struct {
+	// Servers lists the name servers as host:port.
+	Servers []string
+
+	// Search is the list of domains appended to relative names.
+	Search []DNSName
+
+	// Ndots is the number of dots a name needs to be tried as absolute
+	// name before the search list is applied.
+	Ndots int
+
+	// Timeout limits a single query to one server.
+	Timeout time.Duration
+
+	// Attempts is the number of times every server is tried.
+	Attempts int
+
+	// Rotate distributes queries across all servers instead of always
+	// starting with the first one.
+	Rotate bool
+
+	// EDNS0 enables EDNS in queries.
+	EDNS0 bool
+
+	// UseVC sends queries over TCP.
+	UseVC bool
+}
+
+// Limits and defaults as used by the libc resolver.
+const (
+	defaultNdots    = 1
+	maxNdots        = 15
+	defaultTimeout  = 5 * time.Second
+	maxTimeout      = 30 * time.Second
+	defaultAttempts = 2
+	maxAttempts     = 5
+)
+
+// DefaultResolverConfig returns the configuration used when resolv.conf
+// is empty: the local name server and the libc defaults.
+func DefaultResolverConfig() *ResolverConfig {
+	return &ResolverConfig{
+		Servers:  []string{"127.0.0.1:53", "[::1]:53"},
+		Ndots:    defaultNdots,
+		Timeout:  defaultTimeout,
+		Attempts: defaultAttempts,
+	}
+}
+
+// ReadResolvConf reads the resolver configuration from path.
+func ReadResolvConf(path string) (*ResolverConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseResolvConf(f)
+}
+
+// ParseResolvConf parses a configuration in the resolv.conf(5) format. It
+// understands the nameserver, search, domain and options keywords; unknown
+// keywords and options are ignored like the libc resolver does.
+func ParseResolvConf(r io.Reader) (*ResolverConfig, error) {
+	conf := DefaultResolverConfig()
+	conf.Servers = nil
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			// Only addresses are allowed, an optional zone is kept.
+			if addr, err := netip.ParseAddr(fields[1]); err == nil {
+				conf.Servers = append(conf.Servers, net.JoinHostPort(addr.String(), DefaultPort))
+			}
+		case "domain":
+			// The last of domain and search wins.
+			conf.Search = searchList(fields[1:2])
+		case "search":
+			conf.Search = searchList(fields[1:])
+		case "options":
+			for _, opt := range fields[1:] {
+				conf.parseOption(opt)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(conf.Servers) == 0 {
+		conf.Servers = DefaultResolverConfig().Servers
+	}
+	return conf, nil
+}
+
+func searchList(domains []string) []DNSName {
+	var search []DNSName
+	for _, d := range domains {
+		name := DNSName(d)
+		if name.IsRoot() || name.Validate() != nil {
+			continue
+		}
+		search = append(search, name.Fqdn())
+	}
+	return search
+}
+
+func (conf *ResolverConfig) parseOption(opt string) {
+	key, value, _ := strings.Cut(opt, ":")
+	n, err := strconv.Atoi(value)
+	hasValue := err == nil && n >= 0
+
+	switch key {
+	case "ndots":
+		if hasValue {
+			conf.Ndots = min(n, maxNdots)
+		}
+	case "timeout":
+		if hasValue && n > 0 {
+			conf.Timeout = min(time.Duration(n)*time.Second, maxTimeout)
+		}
+	case "attempts":
+		if hasValue && n > 0 {
+			conf.Attempts = min(n, maxAttempts)
+		}
+	case "rotate":
+		conf.Rotate = true
+	case "edns0":
+		conf.EDNS0 = true
+	case "use-vc", "usevc", "tcp":
+		conf.UseVC = true
+	}
+}
+
+// NameList returns the absolute names to try for name in order. Names
+// with a trailing dot are absolute and never extended. Names with at least
+// Ndots dots are tried as given first and with the search domains
+// appended afterwards; other names are tried with the search domains
+// first.
+func (conf *ResolverConfig) NameList(name string) []DNSName {
+	n := DNSName(name)
+	if n.IsRoot() {
+		return []DNSName{RootName}
+	}
+	if n.IsFqdn() {
+		return []DNSName{n}
+	}
+
+	absolute := n.Fqdn()
+	var names []DNSName
+	for _, domain := range conf.Search {
+		candidate := DNSName(name + "." + string(domain))
+		if candidate.Validate() == nil {
+			names = append(names, candidate)
+		}
+	}
+
+	if n.CountLabels()-1 >= conf.Ndots {
+		return append([]DNSName{absolute}, names...)
+	}
+	return append(names, absolute)
+}
@@ -0,0 +1,99 @@
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testResolvConf = `# generated
+domain example.net
+search noteip.de corp.noteip.de. ; the last one wins
+nameserver 192.0.2.1
+nameserver 2001:db8::53
+nameserver not-an-address
+options ndots:2 timeout:3 attempts:9 rotate edns0 use-vc unknown
+`
+
+func TestParseResolvConf(t *testing.T) {
+	conf, err := ParseResolvConf(strings.NewReader(testResolvConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conf.Servers) != 2 || conf.Servers[0] != "192.0.2.1:53" || conf.Servers[1] != "[2001:db8::53]:53" {
+		t.Fatalf("Unexpected servers %q", conf.Servers)
+	}
+
+	if len(conf.Search) != 2 || conf.Search[0] != "noteip.de." || conf.Search[1] != "corp.noteip.de." {
+		t.Fatalf("Unexpected search list %q", conf.Search)
+	}
+
+	if conf.Ndots != 2 || conf.Timeout != 3*time.Second || conf.Attempts != maxAttempts {
+		t.Fatalf("Unexpected options %+v", conf)
+	}
+
+	if !conf.Rotate || !conf.EDNS0 || !conf.UseVC {
+		t.Fatalf("Unexpected flags %+v", conf)
+	}
+}
+
+func TestParseResolvConfDefaults(t *testing.T) {
+	conf, err := ParseResolvConf(strings.NewReader("search a.de\ndomain b.de\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conf.Search) != 1 || conf.Search[0] != "b.de." {
+		t.Fatalf("domain should replace search but got %q", conf.Search)
+	}
+
+	if len(conf.Servers) != 2 || conf.Ndots != 1 || conf.Attempts != 2 || conf.Timeout != 5*time.Second {
+		t.Fatalf("Unexpected defaults %+v", conf)
+	}
+}
+
+func TestReadResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte(testResolvConf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := ReadResolvConf(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Servers) != 2 {
+		t.Fatalf("Unexpected servers %q", conf.Servers)
+	}
+
+	if _, err := ReadResolvConf(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("Reading a missing file should fail.")
+	}
+}
+
+func TestNameList(t *testing.T) {
+	conf := &ResolverConfig{Search: []DNSName{"noteip.de.", "corp.noteip.de."}, Ndots: 1}
+
+	tests := map[string][]DNSName{
+		"git":      {"git.noteip.de.", "git.corp.noteip.de.", "git."},
+		"git.dev":  {"git.dev.", "git.dev.noteip.de.", "git.dev.corp.noteip.de."},
+		"git.dev.": {"git.dev."},
+		`git\.dev`: {`git\.dev.noteip.de.`, `git\.dev.corp.noteip.de.`, `git\.dev.`},
+		".":        {"."},
+	}
+
+	for name, expected := range tests {
+		names := conf.NameList(name)
+		if len(names) != len(expected) {
+			t.Fatalf("NameList(%q) should be %q but got %q", name, expected, names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Fatalf("NameList(%q) should be %q but got %q", name, expected, names)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver is a stub resolver that sends queries to the name servers of
+// its configuration.
+type Resolver struct {
+	// Config holds the name servers and options. It must not be modified
+	// once the Resolver is in use.
+	Config *ResolverConfig
+
+	// Hosts is consulted by LookupHost and LookupAddr if it is set.
+	Hosts *Hosts
+
+	// Order lists the sources LookupHost and LookupAddr consult. The first
+	// source with an answer wins. It defaults to the hosts file followed
+	// by DNS.
+	Order []Source
+
+	// Selector orders the servers by their round trip time and health
+	// unless Config.Rotate is set.
+	Selector ServerSelector
+
+	// next is the index of the first server for the next query if
+	// Config.Rotate is set.
+	next atomic.Uint32
+}
+
+// NewResolver returns a Resolver for conf.
+func NewResolver(conf *ResolverConfig) *Resolver {
+	return &Resolver{Config: conf}
+}
+
+// NewSystemResolver returns a Resolver configured from /etc/resolv.conf
+// that consults /etc/hosts before DNS.
+func NewSystemResolver() (*Resolver, error) {
+	conf, err := ReadResolvConf(DefaultResolvConf)
+	if err != nil {
+		return nil, err
+	}
+	r := NewResolver(conf)
+	r.Hosts = NewHosts(DefaultHostsFile)
+	r.Hosts.CheckInterval = 5 * time.Second
+	return r, nil
+}
+
+func (r *Resolver) order() []Source {
+	if len(r.Order) > 0 {
+		return r.Order
+	}
+	return []Source{SourceFiles, SourceDNS}
+}
+
+func (r *Resolver) client() *Client {
+	c := &Client{Timeout: r.Config.Timeout}
+	if r.Config.UseVC {
+		c.Net = "tcp"
+	}
+	return c
+}
+
+// Exchange sends q to the configured servers and returns the first usable
+// response. The servers are tried in the order chosen by Selector, or in
+// turns with Config.Rotate, Config.Attempts times each; responses with
+// SERVFAIL, NOTIMP or REFUSED move on to the next server. If no server
+// gives a usable response, the last response or error is returned.
+func (r *Resolver) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	conf := r.Config
+	if len(conf.Servers) == 0 {
+		return nil, ErrNoServers
+	}
+	if conf.EDNS0 && q.OPT() == nil {
+		q = q.withEDNS0(DefaultEDNSUDPSize)
+	}
+
+	servers := conf.Servers
+	if conf.Rotate {
+		start := int(r.next.Add(1)-1) % len(servers)
+		servers = append(servers[start:len(servers):len(servers)], servers[:start]...)
+	} else {
+		servers = r.Selector.Order(servers)
+	}
+	attempts := max(conf.Attempts, 1)
+
+	c := r.client()
+	var lastResp *Message
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		for _, server := range servers {
+			start := time.Now()
+			resp, err := c.Exchange(ctx, q, server)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			r.Selector.Record(server, time.Since(start), resp, err)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if isServerError(resp) {
+				lastResp = resp
+				continue
+			}
+			return resp, nil
+		}
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	return nil, lastErr
+}
+
+// ServerStats returns the statistics Selector keeps about the servers.
+func (r *Resolver) ServerStats() []ServerStats {
+	return r.Selector.Stats()
+}
+
+// withEDNS0 returns a copy of msg with an OPT record.
+func (msg *Message) withEDNS0(udpSize uint16) *Message {
+	cp := *msg
+	hdr := *msg.Header
+	cp.Header = &hdr
+	cp.Additional = append([]*ResourceRecord(nil), msg.Additional...)
+	cp.SetEDNS0(udpSize, false)
+	return &cp
+}
+
+// Query looks up name with the given type in class IN. Relative names are
+// expanded with the search list (see ResolverConfig.NameList) and the
+// candidates are tried in order until one of them exists and has records
+// of the requested type. If none does, the response for the last
+// candidate is returned.
+func (r *Resolver) Query(ctx context.Context, name string, qtype Type) (*Message, error) {
+	var lastResp *Message
+	for _, candidate := range r.Config.NameList(name) {
+		q, err := NewQuery(string(candidate), qtype, ClassIN)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := r.Exchange(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		lastResp = resp
+
+		rcode := resp.Header.ResponseCode()
+		if rcode == RCodeNameError || (rcode == RCodeNoError && len(resp.Answer) == 0) {
+			// Try the next name of the search list.
+			continue
+		}
+		return resp, nil
+	}
+
+	return lastResp, nil
+}
@@ -0,0 +1,147 @@
+package dns
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResolverQuerySearchList(t *testing.T) {
+	addr := startTestServer(t, func(q *Message, network string) *Message {
+		if q.Question[0].Name.Equal("git.corp.noteip.de") {
+			return testReply(q, RCodeNoError, testA(q.Question[0].Name, 192, 0, 2, 1))
+		}
+		return testReply(q, RCodeNameError)
+	})
+
+	r := NewResolver(&ResolverConfig{
+		Servers:  []string{addr},
+		Search:   []DNSName{"noteip.de.", "corp.noteip.de."},
+		Ndots:    1,
+		Timeout:  time.Second,
+		Attempts: 1,
+	})
+
+	resp, err := r.Query(context.Background(), "git", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Answer) != 1 || !resp.Answer[0].Name.Equal("git.corp.noteip.de") {
+		t.Fatalf("Expected an answer for git.corp.noteip.de but got\n%s", resp)
+	}
+
+	resp, err = r.Query(context.Background(), "missing", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNameError || !resp.Question[0].Name.Equal("missing.") {
+		t.Fatalf("Expected NXDOMAIN for the last candidate but got\n%s", resp)
+	}
+}
+
+func TestResolverFailover(t *testing.T) {
+	broken := startTestServer(t, func(q *Message, network string) *Message {
+		return testReply(q, RCodeServerFailure)
+	})
+	working := startTestServer(t, func(q *Message, network string) *Message {
+		if q.OPT() == nil || network != "tcp" {
+			return testReply(q, RCodeFormatError)
+		}
+		return testReply(q, RCodeNoError, testA(q.Question[0].Name, 192, 0, 2, 1))
+	})
+
+	r := NewResolver(&ResolverConfig{
+		Servers:  []string{broken, working},
+		Timeout:  time.Second,
+		Attempts: 1,
+		EDNS0:    true,
+		UseVC:    true,
+	})
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	resp, err := r.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNoError {
+		t.Fatalf("Expected NOERROR but got %s", resp.Header.ResponseCode())
+	}
+	if q.OPT() != nil {
+		t.Fatal("The query of the caller shouldn't be modified.")
+	}
+}
+
+func TestResolverRotate(t *testing.T) {
+	var mu sync.Mutex
+	counts := map[string]int{}
+	handler := func(name string) func(q *Message, network string) *Message {
+		return func(q *Message, network string) *Message {
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+			return testReply(q, RCodeNoError)
+		}
+	}
+
+	r := NewResolver(&ResolverConfig{
+		Servers:  []string{startTestServer(t, handler("a")), startTestServer(t, handler("b"))},
+		Timeout:  time.Second,
+		Attempts: 1,
+		Rotate:   true,
+	})
+
+	for i := 0; i < 4; i++ {
+		q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+		if _, err := r.Exchange(context.Background(), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counts["a"] != 2 || counts["b"] != 2 {
+		t.Fatalf("Queries should be distributed evenly but got %v", counts)
+	}
+}
+
+func TestResolverServerSelection(t *testing.T) {
+	broken := startTestServer(t, func(q *Message, network string) *Message {
+		return testReply(q, RCodeRefused)
+	})
+	working := startTestServer(t, func(q *Message, network string) *Message {
+		return testReply(q, RCodeNoError)
+	})
+
+	r := NewResolver(&ResolverConfig{
+		Servers:  []string{broken, working},
+		Timeout:  time.Second,
+		Attempts: 1,
+	})
+	r.Selector.ProbeRate = -1
+
+	for i := 0; i < 5; i++ {
+		q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+		resp, err := r.Exchange(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.ResponseCode() != RCodeNoError {
+			t.Fatalf("Expected NOERROR but got %s", resp.Header.ResponseCode())
+		}
+	}
+
+	for _, st := range r.ServerStats() {
+		switch st.Server {
+		case broken:
+			if st.Queries != 1 || st.Failures != 1 {
+				t.Errorf("Expected %s to be avoided: %+v", broken, st)
+			}
+		case working:
+			if st.Queries != 5 || st.SRTT == 0 {
+				t.Errorf("Unexpected stats of %s: %+v", working, st)
+			}
+		}
+	}
+}
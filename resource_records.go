@@ -7,24 +7,27 @@ type ResourceRecord struct {
 	Name DNSName
 
 	// This field specifies the meaning of the data in the Data field.
-	Type uint16
+	Type Type
 
 	// Class specifies the category of data in the Data field.
-	Class uint16
+	Class Class
 
 	// TTL specifies the time interval (in seconds) that the resource record
 	// may be cached before it should be discarded. Zero values are interpreted
 	// to mean that the RR can only be used for the transaction in progress and
-	// should not be cached. For example, SOA records are always distributed 
+	// should not be cached. For example, SOA records are always distributed
 	// with a zero TTL to prohibit caching. Zero values can also be used for
 	// extremely volatile data.
 	TTL uint32
 
-	// Length specifies the length of the Data field in bytes.
+	// Length specifies the length of the Data field in bytes. Encode uses
+	// the length of Data.
 	Length uint16
 
 	// The format of this informations varies according to the Type and Class
-	// of the RR.
+	// of the RR. Compressed names are resolved when a record is read, so Data
+	// never refers to other parts of the message; see Rdata for the typed
+	// representation.
 	Data []byte
 }
 
@@ -33,10 +36,10 @@ func (rr *ResourceRecord) Encode(rawMsg []byte) (newRaw []byte) {
 	newRaw = rr.Name.Encode(rawMsg)
 
 	buf := make([]byte, 10)
-	uint16ToByte(rr.Type, buf)
-	uint16ToByte(rr.Class, buf[2:4])
+	uint16ToByte(uint16(rr.Type), buf)
+	uint16ToByte(uint16(rr.Class), buf[2:4])
 	uint32ToByte(rr.TTL, buf[4:8])
-	uint16ToByte(rr.Length, buf[8:10])
+	uint16ToByte(uint16(len(rr.Data)), buf[8:10])
 
 	newRaw = append(newRaw[:], buf...)
 	newRaw = append(newRaw[:], rr.Data...)
@@ -44,27 +47,41 @@ func (rr *ResourceRecord) Encode(rawMsg []byte) (newRaw []byte) {
 	return
 }
 
+// ReadResourceRecord parses a resource record. b is the start of the record
+// and rawMsg is the whole message.
 func ReadResourceRecord(b []byte, rawMsg []byte) (rr *ResourceRecord, err error, nextIdx int) {
+	return readResourceRecord(b, rawMsg, false)
+}
+
+func readResourceRecord(b []byte, rawMsg []byte, strict bool) (rr *ResourceRecord, err error, nextIdx int) {
 	rr = new(ResourceRecord)
 
-	rr.Name, err, nextIdx = DecodeDNSName(b, rawMsg)
-	if err != nil {
-		return nil, err, 0
+	var perr *ParseError
+	base := offsetOf(b, rawMsg)
+	rr.Name, nextIdx, perr = decodeName(b, rawMsg, base, strict)
+	if perr != nil {
+		return nil, perr, 0
 	}
 	if len(b) < nextIdx+10 {
-		return nil, ErrInvalidFormat, 0
+		return nil, newParseError(base+nextIdx, SectionUnknown, "truncated record header"), 0
 	}
 
-	rr.Type = byteToUint16(b[nextIdx : nextIdx+2])
-	rr.Class = byteToUint16(b[nextIdx+2 : nextIdx+4])
+	rr.Type = Type(byteToUint16(b[nextIdx : nextIdx+2]))
+	rr.Class = Class(byteToUint16(b[nextIdx+2 : nextIdx+4]))
 	rr.TTL = byteToUint32(b[nextIdx+4 : nextIdx+8])
 	rr.Length = byteToUint16(b[nextIdx+8 : nextIdx+10])
 	start := nextIdx + 10
 	nextIdx = start + int(rr.Length)
 	if len(b) < nextIdx {
-		return nil, ErrInvalidFormat, 0
+		return nil, newParseError(base+start, SectionUnknown, "RDATA exceeds message"), 0
+	}
+	// Names in the RDATA of well-known types may be compressed. Resolve
+	// them so that Data does not depend on the message.
+	rr.Data, perr = decompressRdata(rr.Type, b[start:nextIdx], rawMsg, base+start, strict)
+	if perr != nil {
+		return nil, perr, 0
 	}
-	rr.Data = b[start:nextIdx]
+	rr.Length = uint16(len(rr.Data))
 
 	return
 }
@@ -0,0 +1,232 @@
+package dns
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Zone used for IPv4 reverse lookups.
+	ReverseZoneIPv4 DNSName = "in-addr.arpa"
+
+	// Zone used for IPv6 reverse lookups.
+	ReverseZoneIPv6 DNSName = "ip6.arpa"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// ReverseName returns the name used for PTR lookups of addr, e.g.
+// "4.2.0.192.in-addr.arpa" for 192.0.2.4. IPv4-mapped IPv6 addresses are
+// treated as IPv4 addresses.
+func ReverseName(addr netip.Addr) DNSName {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		b := addr.As4()
+		labels := make([]string, 0, 4)
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(b[i])))
+		}
+		return DNSName(strings.Join(labels, ".")) + "." + ReverseZoneIPv4
+	}
+
+	b := addr.As16()
+	return nibbleName(b[:], 32)
+}
+
+// nibbleName returns the first n nibbles of b in reverse order followed
+// by the IPv6 reverse zone.
+func nibbleName(b []byte, n int) DNSName {
+	var sb strings.Builder
+	for i := n - 1; i >= 0; i-- {
+		v := b[i/2]
+		if i%2 == 0 {
+			v >>= 4
+		}
+		sb.WriteByte(hexDigits[v&0xF])
+		sb.WriteByte('.')
+	}
+	return DNSName(sb.String()) + ReverseZoneIPv6
+}
+
+// ReverseZone returns the name of the reverse zone for prefix. IPv4
+// prefixes of 24 bits and shorter have to end on an octet boundary, longer
+// prefixes use the classless delegation labels of RFC 2317, so
+// 192.0.2.64/26 results in "64-26.2.0.192.in-addr.arpa". IPv6 prefixes
+// have to end on a nibble boundary.
+func ReverseZone(prefix netip.Prefix) (DNSName, error) {
+	if !prefix.IsValid() {
+		return "", ErrNotReverseName
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Bits()
+	addr := prefix.Addr()
+
+	if addr.Is4() {
+		b := addr.As4()
+		if bits == 32 {
+			return ReverseName(addr), nil
+		}
+		if bits > 24 {
+			first := strconv.Itoa(int(b[3])) + "-" + strconv.Itoa(bits)
+			return DNSName(first) + "." + reverseZone24(b), nil
+		}
+		if bits%8 != 0 {
+			return "", ErrNotReverseName
+		}
+		name := ReverseZoneIPv4
+		for i := 0; i < bits/8; i++ {
+			name = DNSName(strconv.Itoa(int(b[i]))) + "." + name
+		}
+		return name, nil
+	}
+
+	if bits%4 != 0 {
+		return "", ErrNotReverseName
+	}
+	if bits == 0 {
+		return ReverseZoneIPv6, nil
+	}
+	b := addr.As16()
+	return nibbleName(b[:], bits/4), nil
+}
+
+// reverseZone24 returns the reverse zone of the /24 network containing the
+// IPv4 address b.
+func reverseZone24(b [4]byte) DNSName {
+	return DNSName(strconv.Itoa(int(b[2]))+"."+strconv.Itoa(int(b[1]))+"."+strconv.Itoa(int(b[0]))) + "." + ReverseZoneIPv4
+}
+
+// ReverseNameClassless returns the name of addr within the RFC 2317
+// classless delegation of its enclosing network of the given size, e.g.
+// "65.64-26.2.0.192.in-addr.arpa" for 192.0.2.65 and 26 bits. The
+// delegating /24 zone maps the regular reverse name to this name with a
+// CNAME record.
+func ReverseNameClassless(addr netip.Addr, bits int) (DNSName, error) {
+	addr = addr.Unmap()
+	if !addr.Is4() || bits <= 24 || bits > 32 {
+		return "", ErrNotReverseName
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return "", err
+	}
+	zone, err := ReverseZone(prefix)
+	if err != nil {
+		return "", err
+	}
+	if bits == 32 {
+		return zone, nil
+	}
+	return DNSName(strconv.Itoa(int(addr.As4()[3]))) + "." + zone, nil
+}
+
+// AddrFromReverseName returns the address a reverse name stands for. Names
+// within RFC 2317 classless delegations are accepted as well.
+func AddrFromReverseName(name DNSName) (netip.Addr, error) {
+	prefix, err := PrefixFromReverseName(name)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !prefix.IsSingleIP() {
+		return netip.Addr{}, ErrNotReverseName
+	}
+	return prefix.Addr(), nil
+}
+
+// PrefixFromReverseName returns the network a reverse name or reverse zone
+// stands for, e.g. 192.0.2.0/24 for "2.0.192.in-addr.arpa" and
+// 192.0.2.64/26 for "64-26.2.0.192.in-addr.arpa".
+func PrefixFromReverseName(name DNSName) (netip.Prefix, error) {
+	labels := name.Labels()
+
+	switch {
+	case name.IsSubdomainOf(ReverseZoneIPv4):
+		return prefixFromIPv4Labels(labels[:len(labels)-2])
+	case name.IsSubdomainOf(ReverseZoneIPv6):
+		return prefixFromIPv6Labels(labels[:len(labels)-2])
+	}
+	return netip.Prefix{}, ErrNotReverseName
+}
+
+func prefixFromIPv4Labels(labels []string) (netip.Prefix, error) {
+	var b [4]byte
+	octets := 0
+	classless := -1
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := labels[i]
+		if sep := strings.IndexAny(label, "-/"); sep >= 0 && octets == 3 && classless < 0 {
+			// The classless delegation label "first-bits" or "first/bits".
+			v, err1 := strconv.ParseUint(label[:sep], 10, 8)
+			n, err2 := strconv.Atoi(label[sep+1:])
+			if err1 != nil || err2 != nil || n <= 24 || n >= 32 {
+				return netip.Prefix{}, ErrNotReverseName
+			}
+			b[3] = byte(v)
+			classless = n
+			continue
+		}
+		if octets == 4 {
+			return netip.Prefix{}, ErrNotReverseName
+		}
+		v, err := strconv.ParseUint(label, 10, 8)
+		if err != nil || (len(label) > 1 && label[0] == '0') {
+			return netip.Prefix{}, ErrNotReverseName
+		}
+		if classless >= 0 {
+			// The address within the delegation has to be part of it
+			// and is the last label.
+			if i > 0 {
+				return netip.Prefix{}, ErrNotReverseName
+			}
+			p := netip.PrefixFrom(netip.AddrFrom4(b), classless).Masked()
+			b[3] = byte(v)
+			if !p.Contains(netip.AddrFrom4(b)) {
+				return netip.Prefix{}, ErrNotReverseName
+			}
+			return netip.PrefixFrom(netip.AddrFrom4(b), 32), nil
+		}
+		b[octets] = byte(v)
+		octets++
+	}
+
+	if classless >= 0 {
+		p := netip.PrefixFrom(netip.AddrFrom4(b), classless)
+		if p.Masked() != p {
+			return netip.Prefix{}, ErrNotReverseName
+		}
+		return p, nil
+	}
+	return netip.PrefixFrom(netip.AddrFrom4(b), octets*8), nil
+}
+
+func prefixFromIPv6Labels(labels []string) (netip.Prefix, error) {
+	if len(labels) > 32 {
+		return netip.Prefix{}, ErrNotReverseName
+	}
+
+	var b [16]byte
+	nibbles := 0
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := strings.ToLower(labels[i])
+		if len(label) != 1 || !strings.Contains(hexDigits, label) {
+			return netip.Prefix{}, ErrNotReverseName
+		}
+		v := byte(strings.IndexByte(hexDigits, label[0]))
+		if nibbles%2 == 0 {
+			v <<= 4
+		}
+		b[nibbles/2] |= v
+		nibbles++
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(b), nibbles*4), nil
+}
+
+// NewReverseQuery returns a PTR query for addr.
+func NewReverseQuery(addr netip.Addr) (*Message, error) {
+	if !addr.IsValid() {
+		return nil, ErrNotReverseName
+	}
+	return NewQuery(string(ReverseName(addr)), TypePTR, ClassIN)
+}
@@ -0,0 +1,128 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestReverseName(t *testing.T) {
+	tests := map[string]DNSName{
+		"192.0.2.4":          "4.2.0.192.in-addr.arpa",
+		"::ffff:192.0.2.4":   "4.2.0.192.in-addr.arpa",
+		"2001:db8::567:89ab": "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+	}
+
+	for s, expected := range tests {
+		addr := netip.MustParseAddr(s)
+		name := ReverseName(addr)
+		if name != expected {
+			t.Fatalf("ReverseName(%s) should be %q but got %q", s, expected, name)
+		}
+
+		back, err := AddrFromReverseName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back != addr.Unmap() {
+			t.Fatalf("AddrFromReverseName(%q) should be %s but got %s", name, addr.Unmap(), back)
+		}
+	}
+}
+
+func TestReverseZone(t *testing.T) {
+	tests := map[string]DNSName{
+		"192.0.2.0/24":  "2.0.192.in-addr.arpa",
+		"10.0.0.0/8":    "10.in-addr.arpa",
+		"0.0.0.0/0":     "in-addr.arpa",
+		"192.0.2.64/26": "64-26.2.0.192.in-addr.arpa",
+		"192.0.2.77/26": "64-26.2.0.192.in-addr.arpa",
+		"192.0.2.7/32":  "7.2.0.192.in-addr.arpa",
+		"2001:db8::/32": "8.b.d.0.1.0.0.2.ip6.arpa",
+		"2001:db8::/36": "0.8.b.d.0.1.0.0.2.ip6.arpa",
+		"::/0":          "ip6.arpa",
+	}
+
+	for s, expected := range tests {
+		zone, err := ReverseZone(netip.MustParsePrefix(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zone != expected {
+			t.Fatalf("ReverseZone(%s) should be %q but got %q", s, expected, zone)
+		}
+
+		prefix, err := PrefixFromReverseName(zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefix != netip.MustParsePrefix(s).Masked() {
+			t.Fatalf("PrefixFromReverseName(%q) should be %s but got %s", zone, s, prefix)
+		}
+	}
+
+	for _, s := range []string{"192.0.0.0/20", "2001:db8::/33"} {
+		if _, err := ReverseZone(netip.MustParsePrefix(s)); err != ErrNotReverseName {
+			t.Fatalf("ReverseZone(%s) should fail but got %v", s, err)
+		}
+	}
+}
+
+func TestReverseNameClassless(t *testing.T) {
+	name, err := ReverseNameClassless(netip.MustParseAddr("192.0.2.65"), 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "65.64-26.2.0.192.in-addr.arpa" {
+		t.Fatalf("Expected '65.64-26.2.0.192.in-addr.arpa' but got %q", name)
+	}
+
+	addr, err := AddrFromReverseName(name)
+	if err != nil || addr != netip.MustParseAddr("192.0.2.65") {
+		t.Fatalf("Expected 192.0.2.65 but got %s (%v)", addr, err)
+	}
+
+	// The slash notation of RFC 2317 is accepted as well.
+	addr, err = AddrFromReverseName("65.64/26.2.0.192.in-addr.arpa")
+	if err != nil || addr != netip.MustParseAddr("192.0.2.65") {
+		t.Fatalf("Expected 192.0.2.65 but got %s (%v)", addr, err)
+	}
+
+	// 192.0.2.1 is not part of 192.0.2.64/26.
+	if _, err := AddrFromReverseName("1.64-26.2.0.192.in-addr.arpa"); err != ErrNotReverseName {
+		t.Fatalf("Expected ErrNotReverseName but got %v", err)
+	}
+
+	// Nothing may follow the address within the delegation.
+	if _, err := AddrFromReverseName("1.65.64-26.2.0.192.in-addr.arpa"); err != ErrNotReverseName {
+		t.Fatalf("Expected ErrNotReverseName but got %v", err)
+	}
+}
+
+func TestAddrFromReverseNameInvalid(t *testing.T) {
+	for _, name := range []DNSName{
+		"noteip.de",
+		"2.0.192.in-addr.arpa",
+		"256.2.0.192.in-addr.arpa",
+		"1.1.2.0.192.in-addr.arpa",
+		"g.8.b.d.0.1.0.0.2.ip6.arpa",
+	} {
+		if _, err := AddrFromReverseName(name); err != ErrNotReverseName {
+			t.Fatalf("AddrFromReverseName(%q) should fail but got %v", name, err)
+		}
+	}
+}
+
+func TestNewReverseQuery(t *testing.T) {
+	msg, err := NewReverseQuery(netip.MustParseAddr("192.0.2.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msg.Question) != 1 || msg.Question[0].Type != TypePTR || msg.Question[0].Name != "4.2.0.192.in-addr.arpa" {
+		t.Fatalf("Unexpected question %v", msg.Question)
+	}
+
+	if !msg.Header.IsQuery() || !msg.Header.IsRecursionDesired() {
+		t.Fatal("Message should be a recursive query.")
+	}
+}
@@ -0,0 +1,221 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// LocalZoneType selects how a local zone of a Router answers. The types
+// follow the local-zone types of Unbound.
+type LocalZoneType int
+
+const (
+	// LocalStatic answers from the local data only. Names without data
+	// get NXDOMAIN, names without data of the queried type NODATA.
+	LocalStatic LocalZoneType = iota
+
+	// LocalTransparent answers names with local data from it, NODATA if
+	// the queried type is missing. Other names are resolved normally.
+	LocalTransparent
+
+	// LocalRedirect answers queries for the zone and all names below it
+	// with the data of the zone apex.
+	LocalRedirect
+
+	// LocalRefuse answers with REFUSED.
+	LocalRefuse
+
+	// LocalDeny drops queries.
+	LocalDeny
+)
+
+var localZoneTypeNames = map[LocalZoneType]string{
+	LocalStatic:      "static",
+	LocalTransparent: "transparent",
+	LocalRedirect:    "redirect",
+	LocalRefuse:      "refuse",
+	LocalDeny:        "deny",
+}
+
+func (t LocalZoneType) String() string {
+	if name, ok := localZoneTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("LocalZoneType(%d)", int(t))
+}
+
+// ParseLocalZoneType parses the name of a local zone type as used by
+// Unbound, e.g. "static" or "redirect".
+func ParseLocalZoneType(s string) (LocalZoneType, error) {
+	for t, name := range localZoneTypeNames {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return 0, ErrUnknownMnemonic
+}
+
+// localZone is a zone answered by a Router itself.
+type localZone struct {
+	zone    DNSName
+	typ     LocalZoneType
+	records []*ResourceRecord
+}
+
+// Router answers queries from local zones or passes them to the Exchanger
+// of the closest zone configured for their name: forward zones send
+// queries to recursive upstreams, stub zones resolve iteratively starting
+// at fixed authoritative servers. Zones match on label boundaries and the
+// most specific zone wins. Local zones are consulted first; names of a
+// transparent local zone without local data continue to the forward and
+// stub zones. Queries that match no zone go to Default.
+//
+// CNAME targets are not routed again, the Exchanger that answered the
+// alias is responsible for them.
+//
+// The zones must not be changed while the Router is in use.
+type Router struct {
+	// Default answers queries that match no zone. Such queries are
+	// refused if it is nil.
+	Default Exchanger
+
+	routes map[DNSName]Exchanger
+	locals map[DNSName]*localZone
+}
+
+// Forward sends queries for zone and the names below it to ex, e.g. an
+// Upstream or Pool of recursive resolvers.
+func (r *Router) Forward(zone DNSName, ex Exchanger) {
+	if r.routes == nil {
+		r.routes = make(map[DNSName]Exchanger)
+	}
+	r.routes[zone.Canonical()] = ex
+}
+
+// Stub resolves names in zone iteratively starting at the authoritative
+// servers at addrs and returns the IterativeResolver, so that its
+// transport can be configured.
+func (r *Router) Stub(zone DNSName, addrs ...netip.Addr) *IterativeResolver {
+	const ttl = 3600
+	var hints []*ResourceRecord
+	for i, addr := range addrs {
+		// The servers are only known by address, their names are
+		// placeholders.
+		host := DNSName(fmt.Sprintf("stub%d.%s", i+1, zone.Canonical()))
+		hints = append(hints, NewResourceRecord(zone, ClassIN, ttl, &NS{Host: host}))
+		if addr.Is4() {
+			hints = append(hints, NewResourceRecord(host, ClassIN, ttl, &A{Addr: addr}))
+		} else {
+			hints = append(hints, NewResourceRecord(host, ClassIN, ttl, &AAAA{Addr: addr}))
+		}
+	}
+	ir := &IterativeResolver{Hints: hints}
+	r.Forward(zone, ir)
+	return ir
+}
+
+// LocalZone makes the Router answer queries for zone and the names below
+// it itself as typ defines, with records as local data.
+func (r *Router) LocalZone(zone DNSName, typ LocalZoneType, records ...*ResourceRecord) {
+	if r.locals == nil {
+		r.locals = make(map[DNSName]*localZone)
+	}
+	r.locals[zone.Canonical()] = &localZone{zone: zone, typ: typ, records: records}
+}
+
+// closestZone returns the value of the closest enclosing zone of name in
+// zones.
+func closestZone[T any](zones map[DNSName]T, name DNSName) (T, bool) {
+	zone := name.Canonical()
+	for {
+		if v, ok := zones[zone]; ok {
+			return v, true
+		}
+		if zone.IsRoot() {
+			var zero T
+			return zero, false
+		}
+		zone = zone.Parent()
+	}
+}
+
+// Exchange answers q locally or passes it on to the Exchanger of its zone.
+// It returns ErrQueryDenied for queries to LocalDeny zones.
+func (r *Router) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	if len(q.Question) != 1 {
+		return errorResponse(q, RCodeFormatError), nil
+	}
+	question := q.Question[0]
+
+	if lz, ok := closestZone(r.locals, question.Name); ok {
+		resp, err := lz.answer(q)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+	}
+
+	ex, ok := closestZone(r.routes, question.Name)
+	if !ok {
+		ex = r.Default
+	}
+	if ex == nil {
+		return errorResponse(q, RCodeRefused), nil
+	}
+	return ex.Exchange(ctx, q)
+}
+
+// answer answers q from the local zone. It returns nil if the query is to
+// be resolved normally.
+func (lz *localZone) answer(q *Message) (*Message, error) {
+	question := q.Question[0]
+	switch lz.typ {
+	case LocalDeny:
+		return nil, ErrQueryDenied
+	case LocalRefuse:
+		return errorResponse(q, RCodeRefused), nil
+	}
+
+	owner := question.Name
+	if lz.typ == LocalRedirect {
+		owner = lz.zone
+	}
+
+	exists := false
+	var answer []*ResourceRecord
+	for _, rr := range lz.records {
+		if rr.Name.IsSubdomainOf(owner) {
+			// Empty non-terminals exist too.
+			exists = true
+		}
+		if !rr.Name.Equal(owner) {
+			continue
+		}
+		if rr.Type == question.Type || (rr.Type == TypeCNAME && question.Type != TypeCNAME) || question.Type == TypeAll {
+			cp := *rr
+			cp.Name = question.Name
+			answer = append(answer, &cp)
+		}
+	}
+
+	if !exists && lz.typ == LocalTransparent {
+		return nil, nil
+	}
+
+	resp := errorResponse(q, RCodeNoError)
+	resp.Header.SetAuthoritativeAnswer(true)
+	resp.Header.SetRecursionAvailable(true)
+	resp.Answer = answer
+	if len(answer) == 0 {
+		if !exists {
+			resp.Header.SetResponseCode(RCodeNameError)
+		}
+		for _, rr := range lz.records {
+			if rr.Type == TypeSOA && rr.Name.Equal(lz.zone) {
+				resp.Authority = append(resp.Authority, rr)
+			}
+		}
+	}
+	return resp, nil
+}
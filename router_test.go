@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// namedExchanger answers every query with a TXT record holding its name.
+type namedExchanger string
+
+func (e namedExchanger) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	return testReply(q, RCodeNoError, NewResourceRecord(q.Question[0].Name, ClassIN, 60, &TXT{Strings: []string{string(e)}})), nil
+}
+
+func routerQuery(t *testing.T, r *Router, name string, qtype Type) *Message {
+	t.Helper()
+	q, err := NewQuery(name, qtype, ClassIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := r.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// answeredBy returns the name of the namedExchanger that answered resp.
+func answeredBy(resp *Message) string {
+	if len(resp.Answer) != 1 || resp.Answer[0].Type != TypeTXT {
+		return ""
+	}
+	data, _ := resp.Answer[0].Rdata()
+	return data.(*TXT).Joined()
+}
+
+func TestRouterForward(t *testing.T) {
+	r := &Router{Default: namedExchanger("default")}
+	r.Forward("corp.noteip.de", namedExchanger("corp"))
+	r.Forward("lab.corp.noteip.de.", namedExchanger("lab"))
+
+	for name, want := range map[string]string{
+		"corp.noteip.de":         "corp",
+		"git.CORP.noteip.de":     "corp",
+		"git.lab.corp.noteip.de": "lab",
+		"xcorp.noteip.de":        "default",
+		"noteip.de":              "default",
+	} {
+		if got := answeredBy(routerQuery(t, r, name, TypeTXT)); got != want {
+			t.Errorf("%s was answered by %q, want %q", name, got, want)
+		}
+	}
+
+	r.Default = nil
+	if resp := routerQuery(t, r, "noteip.de", TypeA); resp.Header.ResponseCode() != RCodeRefused {
+		t.Errorf("Expected REFUSED without default but got %v", resp)
+	}
+}
+
+func TestRouterStub(t *testing.T) {
+	h := newTestHierarchy(t)
+	r := &Router{Default: namedExchanger("default")}
+	stub := r.Stub("noteip.de", netip.MustParseAddr("127.0.0.4"))
+	stub.Port = h.port
+	stub.Client = &Client{Timeout: time.Second}
+
+	resp := routerQuery(t, r, "git.noteip.de", TypeA)
+	if len(resp.Answer) != 1 || resp.Answer[0].Type != TypeA {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+	if n := h.zones[RootName].queries.Load(); n != 0 {
+		t.Fatalf("Expected the stub zone to skip the root but got %d queries", n)
+	}
+}
+
+func TestRouterLocalZones(t *testing.T) {
+	soa := testSOAFor("corp.noteip.de")
+	r := &Router{Default: namedExchanger("default")}
+	r.LocalZone("ads.example", LocalStatic)
+	r.LocalZone("corp.noteip.de", LocalTransparent, soa,
+		testAddr("printer.lab.corp.noteip.de", "192.0.2.9"))
+	r.LocalZone("blocked.example", LocalRedirect, testAddr("blocked.example", "0.0.0.0"))
+	r.LocalZone("refused.example", LocalRefuse)
+	r.LocalZone("denied.example", LocalDeny)
+
+	if resp := routerQuery(t, r, "tracker.ads.example", TypeA); resp.Header.ResponseCode() != RCodeNameError {
+		t.Errorf("Expected NXDOMAIN from the static zone but got %v", resp)
+	}
+
+	resp := routerQuery(t, r, "printer.lab.corp.noteip.de", TypeA)
+	if len(resp.Answer) != 1 || !resp.Header.IsAuthoritativeAnswer() {
+		t.Errorf("Expected the local data but got %v", resp)
+	}
+	resp = routerQuery(t, r, "printer.lab.corp.noteip.de", TypeAAAA)
+	if resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) != 0 || len(resp.Authority) != 1 {
+		t.Errorf("Expected NODATA with SOA but got %v", resp)
+	}
+	resp = routerQuery(t, r, "lab.corp.noteip.de", TypeA)
+	if resp.Header.ResponseCode() != RCodeNoError || len(resp.Answer) != 0 {
+		t.Errorf("Expected NODATA for the empty non-terminal but got %v", resp)
+	}
+	if got := answeredBy(routerQuery(t, r, "git.corp.noteip.de", TypeTXT)); got != "default" {
+		t.Errorf("Expected names without local data to be resolved but got %q", got)
+	}
+
+	resp = routerQuery(t, r, "www.blocked.example", TypeA)
+	if len(resp.Answer) != 1 || !resp.Answer[0].Name.Equal("www.blocked.example") {
+		t.Errorf("Expected the redirected address but got %v", resp)
+	}
+	if resp := routerQuery(t, r, "www.blocked.example", TypeMX); len(resp.Answer) != 0 || resp.Header.ResponseCode() != RCodeNoError {
+		t.Errorf("Expected NODATA from the redirect zone but got %v", resp)
+	}
+
+	if resp := routerQuery(t, r, "refused.example", TypeA); resp.Header.ResponseCode() != RCodeRefused {
+		t.Errorf("Expected REFUSED but got %v", resp)
+	}
+	q, _ := NewQuery("www.denied.example", TypeA, ClassIN)
+	if _, err := r.Exchange(context.Background(), q); !errors.Is(err, ErrQueryDenied) {
+		t.Errorf("Expected ErrQueryDenied but got %v", err)
+	}
+}
+
+func TestParseLocalZoneType(t *testing.T) {
+	for _, typ := range []LocalZoneType{LocalStatic, LocalTransparent, LocalRedirect, LocalRefuse, LocalDeny} {
+		got, err := ParseLocalZoneType(typ.String())
+		if err != nil || got != typ {
+			t.Errorf("ParseLocalZoneType(%q) = %v, %v", typ, got, err)
+		}
+	}
+	if _, err := ParseLocalZoneType("nodefault"); !errors.Is(err, ErrUnknownMnemonic) {
+		t.Errorf("Expected ErrUnknownMnemonic but got %v", err)
+	}
+}
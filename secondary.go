@@ -0,0 +1,286 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultSecondaryRetry is the time a Secondary waits before it retries to
+// transfer a zone it has no SOA record for.
+const DefaultSecondaryRetry = time.Minute
+
+// Secondary maintains a copy of a zone transferred from a primary server
+// (RFC 1034 section 4.3.5). Run checks the serial of the SOA record at the
+// primary every refresh interval of the zone and transfers the changes
+// with IXFR, or the zone with AXFR, if the serial increased. Failed checks
+// are repeated after the retry interval. If no check succeeds within the
+// expire interval, the Secondary stops answering for the zone and returns
+// SERVFAIL until the next successful check.
+//
+// With a File, the zone is saved after each transfer and loaded by
+// NewSecondary, so that a restarted Secondary can answer right away. The
+// modification time of the file records the last successful check.
+//
+// A Secondary answers queries and zone transfers with its Zone, so it can
+// be the Handler of a Server.
+type Secondary struct {
+	Zone *Zone
+
+	// Primary is the address of the primary server.
+	Primary string
+
+	// Client is used to query the primary. If nil, a Client with the
+	// default settings is used.
+	Client *Client
+
+	// File is the path the zone is saved to. If empty, the zone is kept
+	// in memory only.
+	File string
+
+	mu sync.Mutex
+
+	// expires is the time the zone expires. It is zero before the zone
+	// has been checked or loaded.
+	expires time.Time
+
+	// now returns the current time and is replaced by tests.
+	now func() time.Time
+}
+
+// NewSecondary returns a Secondary for the zone at origin. If file exists,
+// the zone is loaded from it and expires after the expire interval from
+// the time the file was last checked.
+func NewSecondary(origin DNSName, primary, file string) (*Secondary, error) {
+	s := &Secondary{Zone: &Zone{Origin: origin}, Primary: primary, File: file, now: time.Now}
+	if file == "" {
+		return s, nil
+	}
+	if err := s.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Secondary) client() *Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &Client{}
+}
+
+func (s *Secondary) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// Expired reports whether the zone is not to be served, because it has
+// never been transferred or the expire interval has passed since the last
+// successful check.
+func (s *Secondary) Expired() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.expires.IsZero() || !s.clock().Before(s.expires)
+}
+
+// Exchange answers q from the zone or with SERVFAIL if it has expired.
+func (s *Secondary) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	if s.Expired() {
+		return errorResponse(q, RCodeServerFailure), nil
+	}
+	return s.Zone.Exchange(ctx, q)
+}
+
+// Transfer serves zone transfers of the zone unless it has expired.
+func (s *Secondary) Transfer(ctx context.Context, q *Message) ([]*ResourceRecord, error) {
+	if s.Expired() {
+		return nil, ErrZoneExpired
+	}
+	return s.Zone.Transfer(ctx, q)
+}
+
+// Refresh checks the serial of the SOA record at the primary and updates
+// the zone if the serial is newer than the local one (RFC 1982). The zone
+// is transferred with AXFR if the primary rejects IXFR or its deltas do not
+// fit the zone. Refresh reports whether the zone changed. A successful
+// check restarts the expire interval; ErrSerialBehind is returned if the
+// zone is still older than the primary's after the transfer.
+func (s *Secondary) Refresh(ctx context.Context) (bool, error) {
+	c := s.client()
+	serial, err := s.primarySerial(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	if soa := s.Zone.SOA(); soa == nil || CompareSerial(serial, soaSerial(soa)) > 0 {
+		changed, err = c.UpdateZone(ctx, s.Zone, s.Primary)
+		var te *TransferError
+		if errors.Is(err, ErrDeltaMismatch) || errors.As(err, &te) {
+			// The deltas do not fit the local copy or the primary does
+			// not serve IXFR, so transfer the whole zone.
+			changed, err = c.replaceZone(ctx, s.Zone, s.Primary)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	soa := s.Zone.SOA()
+	if soa == nil {
+		return changed, ErrBadTransfer
+	}
+	if CompareSerial(soaSerial(soa), serial) < 0 {
+		// The transfer did not bring the zone up to date, so the check
+		// failed and the zone keeps expiring.
+		return changed, ErrSerialBehind
+	}
+	now := s.clock()
+	if changed {
+		err = s.save()
+	} else if s.File != "" {
+		err = os.Chtimes(s.File, time.Time{}, now)
+		if errors.Is(err, os.ErrNotExist) {
+			err = s.save()
+		}
+	}
+
+	s.mu.Lock()
+	s.expires = now.Add(time.Duration(soaData(soa).Expire) * time.Second)
+	s.mu.Unlock()
+	return changed, err
+}
+
+// primarySerial queries the serial of the SOA record at the primary.
+func (s *Secondary) primarySerial(ctx context.Context) (uint32, error) {
+	q, err := NewQuery(string(s.Zone.Origin), TypeSOA, ClassIN)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client().Exchange(ctx, q, s.Primary)
+	if err != nil {
+		return 0, err
+	}
+	if rcode := resp.Header.ResponseCode(); rcode != RCodeNoError || !resp.Header.IsAuthoritativeAnswer() {
+		return 0, &TransferError{Zone: s.Zone.Origin, Rcode: rcode}
+	}
+	for _, rr := range resp.Answer {
+		if rr.Type == TypeSOA && rr.Name.Equal(s.Zone.Origin) {
+			return soaSerial(rr), nil
+		}
+	}
+	return 0, ErrBadTransfer
+}
+
+// Run keeps the zone up to date until ctx is done and returns the error of
+// ctx. The first check happens right away.
+func (s *Secondary) Run(ctx context.Context) error {
+	for {
+		timer := time.NewTimer(s.poll(ctx))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// poll refreshes the zone and returns the time until the next check: the
+// refresh interval after a successful check, the retry interval after a
+// failed one. DefaultSecondaryRetry is used without an interval.
+func (s *Secondary) poll(ctx context.Context) time.Duration {
+	_, err := s.Refresh(ctx)
+	soa := s.Zone.SOA()
+	if soa == nil {
+		return DefaultSecondaryRetry
+	}
+	interval := soaData(soa).Refresh
+	if err != nil {
+		interval = soaData(soa).Retry
+	}
+	if interval == 0 {
+		return DefaultSecondaryRetry
+	}
+	return time.Duration(interval) * time.Second
+}
+
+// save writes the zone to File as a sequence of length-prefixed AXFR
+// messages. The file is replaced atomically.
+func (s *Secondary) save() error {
+	if s.File == "" {
+		return nil
+	}
+	records, err := s.Zone.Snapshot().axfr()
+	if err != nil {
+		return err
+	}
+	q, err := NewQuery(string(s.Zone.Origin), TypeAXFR, ClassIN)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.File), filepath.Base(s.File)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	for msg := range transferMessages(q, records) {
+		if err := WriteTCPMessage(f, msg.Encode()); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.File)
+}
+
+// load reads the zone from File.
+func (s *Secondary) load() error {
+	f, err := os.Open(s.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var records []*ResourceRecord
+	for {
+		b, err := ReadTCPMessage(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		msg, err := ReadMessage(b)
+		if err != nil {
+			return err
+		}
+		records = append(records, msg.Answer...)
+	}
+	n := len(records)
+	if n < 2 || records[0].Type != TypeSOA || !records[0].Name.Equal(s.Zone.Origin) || !sameRecord(records[0], records[n-1]) {
+		return ErrBadTransfer
+	}
+	if err := s.Zone.Replace(records[:n-1]...); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.expires = fi.ModTime().Add(time.Duration(soaData(records[0]).Expire) * time.Second)
+	s.mu.Unlock()
+	return nil
+}
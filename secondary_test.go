@@ -0,0 +1,223 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPrimary serves the test zone at serial 2 with SOA timers.
+func newTestPrimary(t *testing.T) (*Zone, string) {
+	t.Helper()
+
+	z := newTestZone(t)
+	soa := *soaData(z.SOA())
+	soa.Serial, soa.Refresh, soa.Retry, soa.Expire = 2, 3600, 600, 7200
+	if err := z.Apply(&ZoneDelta{From: z.SOA(), To: NewResourceRecord(z.Origin, ClassIN, 300, &soa)}); err != nil {
+		t.Fatal(err)
+	}
+	return z, serveTest(t, &Server{Handler: z})
+}
+
+func TestSecondary(t *testing.T) {
+	primary, addr := newTestPrimary(t)
+	file := filepath.Join(t.TempDir(), "example.com.zone")
+	s, err := NewSecondary("example.com", addr, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = &Client{Timeout: time.Second}
+	clock := &testClock{t: time.Unix(1e9, 0)}
+	s.now = clock.now
+
+	resp, _ := s.Exchange(context.Background(), zoneQuery(t, primary, "www.example.com", TypeA))
+	if !s.Expired() || resp.Header.ResponseCode() != RCodeServerFailure {
+		t.Fatalf("Expected SERVFAIL before the first transfer, got %v", resp.Header)
+	}
+
+	if changed, err := s.Refresh(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected AXFR, got %v %v", changed, err)
+	}
+	resp = zoneQuery(t, s.Zone, "www.example.com", TypeA)
+	if s.Expired() || len(resp.Answer) != 1 {
+		t.Fatalf("Expected an answer after the transfer, got %v", resp)
+	}
+
+	applyDelta(t, primary, 3, nil, []*ResourceRecord{testAddr("new.example.com", "192.0.2.2")})
+	applyDelta(t, primary, 4, nil, nil)
+	if changed, err := s.Refresh(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected IXFR, got %v %v", changed, err)
+	}
+	want, _ := primary.Snapshot().axfr()
+	got, _ := s.Zone.Snapshot().axfr()
+	if !slices.Equal(recordNames(got), recordNames(want)) || soaSerial(s.Zone.SOA()) != 4 {
+		t.Fatalf("Secondary differs from primary: %v", recordNames(got))
+	}
+	if changed, err := s.Refresh(context.Background()); err != nil || changed {
+		t.Fatalf("Expected no change, got %v %v", changed, err)
+	}
+	if wait := s.poll(context.Background()); wait != time.Hour {
+		t.Fatalf("Expected the refresh interval, got %v", wait)
+	}
+
+	// A failed check is retried, and the zone expires if no check
+	// succeeds within the expire interval.
+	s.Primary = "127.0.0.1:1"
+	s.Client = &Client{Net: "tcp", Timeout: 100 * time.Millisecond}
+	if wait := s.poll(context.Background()); wait != 10*time.Minute {
+		t.Fatalf("Expected the retry interval, got %v", wait)
+	}
+	clock.advance(2*time.Hour - time.Second)
+	if s.Expired() {
+		t.Fatal("Zone expired early")
+	}
+	clock.advance(time.Second)
+	resp, _ = s.Exchange(context.Background(), zoneQuery(t, primary, "www.example.com", TypeA))
+	if !s.Expired() || resp.Header.ResponseCode() != RCodeServerFailure {
+		t.Fatalf("Expected SERVFAIL after expiry, got %v", resp.Header)
+	}
+	q, _ := NewQuery("example.com", TypeAXFR, ClassIN)
+	if _, err := s.Transfer(context.Background(), q); !errors.Is(err, ErrZoneExpired) {
+		t.Fatalf("Expected ErrZoneExpired, got %v", err)
+	}
+}
+
+// lyingPrimary announces a newer serial than it transfers if lie is set.
+type lyingPrimary struct {
+	*Zone
+	lie atomic.Bool
+}
+
+func (p *lyingPrimary) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	resp, err := p.Zone.Exchange(ctx, q)
+	if err == nil && p.lie.Load() && q.Question[0].Type == TypeSOA {
+		resp.Answer = []*ResourceRecord{withSerial(p.SOA(), 5)}
+	}
+	return resp, err
+}
+
+func TestSecondaryBehind(t *testing.T) {
+	primary, _ := newTestPrimary(t)
+	p := &lyingPrimary{Zone: primary}
+	s, _ := NewSecondary("example.com", serveTest(t, &Server{Handler: p}), "")
+	s.Client = &Client{Timeout: time.Second}
+	clock := &testClock{t: time.Unix(1e9, 0)}
+	s.now = clock.now
+
+	if _, err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transfer leaves the zone at serial 2, so the check fails and
+	// does not restart the expire interval.
+	p.lie.Store(true)
+	clock.advance(time.Hour)
+	if _, err := s.Refresh(context.Background()); !errors.Is(err, ErrSerialBehind) {
+		t.Fatalf("Expected ErrSerialBehind, got %v", err)
+	}
+	if wait := s.poll(context.Background()); wait != 10*time.Minute {
+		t.Fatalf("Expected the retry interval, got %v", wait)
+	}
+	clock.advance(time.Hour)
+	if !s.Expired() {
+		t.Fatal("Expected the zone to expire")
+	}
+}
+
+// axfrOnlyPrimary rejects IXFR queries with NOTIMP.
+type axfrOnlyPrimary struct {
+	*Zone
+}
+
+func (p *axfrOnlyPrimary) Transfer(ctx context.Context, q *Message) ([]*ResourceRecord, error) {
+	if q.Question[0].Type == TypeIXFR {
+		return nil, &TransferError{Zone: p.Origin, Rcode: RCodeNotImplemented}
+	}
+	return p.Zone.Transfer(ctx, q)
+}
+
+func TestSecondaryAXFROnly(t *testing.T) {
+	primary, _ := newTestPrimary(t)
+	s, _ := NewSecondary("example.com", serveTest(t, &Server{Handler: &axfrOnlyPrimary{primary}}), "")
+	s.Client = &Client{Timeout: time.Second}
+	if _, err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	applyDelta(t, primary, 3, nil, []*ResourceRecord{testAddr("new.example.com", "192.0.2.2")})
+	if changed, err := s.Refresh(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected AXFR after the rejected IXFR, got %v %v", changed, err)
+	}
+	want, _ := primary.Snapshot().axfr()
+	got, _ := s.Zone.Snapshot().axfr()
+	if !slices.Equal(recordNames(got), recordNames(want)) || soaSerial(s.Zone.SOA()) != 3 {
+		t.Fatalf("Secondary differs from primary: %v", recordNames(got))
+	}
+}
+
+func TestSecondaryFile(t *testing.T) {
+	primary, addr := newTestPrimary(t)
+	file := filepath.Join(t.TempDir(), "example.com.zone")
+	s, _ := NewSecondary("example.com", addr, file)
+	if _, err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restarted secondary answers from the saved zone.
+	restarted, err := NewSecondary("example.com", addr, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := primary.Snapshot().axfr()
+	got, _ := restarted.Zone.Snapshot().axfr()
+	if restarted.Expired() || !slices.Equal(recordNames(got), recordNames(want)) {
+		t.Fatalf("Unexpected zone after restart: %v", recordNames(got))
+	}
+	resp, _ := restarted.Exchange(context.Background(), zoneQuery(t, primary, "www.example.com", TypeA))
+	if len(resp.Answer) != 1 {
+		t.Fatalf("Expected an answer, got %v", resp)
+	}
+
+	// The zone expires relative to the last check.
+	old := time.Now().Add(-3 * time.Hour)
+	os.Chtimes(file, old, old)
+	if restarted, _ = NewSecondary("example.com", addr, file); !restarted.Expired() {
+		t.Fatal("Expected the loaded zone to have expired")
+	}
+	if _, err := restarted.Refresh(context.Background()); err != nil || restarted.Expired() {
+		t.Fatalf("Expected the check to renew the zone, got %v", err)
+	}
+
+	if _, err := NewSecondary("example.org", addr, file); !errors.Is(err, ErrBadTransfer) {
+		t.Fatalf("Expected ErrBadTransfer for the wrong zone, got %v", err)
+	}
+	os.WriteFile(file, []byte{0, 5, 1}, 0o644)
+	if _, err := NewSecondary("example.com", addr, file); err == nil {
+		t.Fatal("Expected an error for a truncated file")
+	}
+}
+
+func TestSecondaryRun(t *testing.T) {
+	_, addr := newTestPrimary(t)
+	s, _ := NewSecondary("example.com", addr, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Expired() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if s.Expired() {
+		t.Fatal("Run did not transfer the zone")
+	}
+}
@@ -0,0 +1,302 @@
+package dns
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProbeRate is the share of queries sent to a server other than
+	// the fastest one to keep the statistics of the others current.
+	DefaultProbeRate = 0.05
+
+	// DefaultFailureThreshold is the number of consecutive failures after
+	// which a server is considered down.
+	DefaultFailureThreshold = 3
+
+	// DefaultBackoff is how long a server is considered down the first
+	// time. The time doubles each time the server fails again after it.
+	DefaultBackoff = 5 * time.Second
+
+	// maxBackoff caps the time a server is considered down.
+	maxBackoff = 5 * time.Minute
+
+	// maxSRTT caps the smoothed RTT, which grows with every timeout.
+	maxSRTT = 10 * time.Second
+)
+
+// ServerStats are the statistics a ServerSelector keeps for a server.
+type ServerStats struct {
+	Server string
+
+	// SRTT is the smoothed round trip time. It is zero for servers that
+	// have not answered yet.
+	SRTT time.Duration
+
+	Queries  uint64
+	Failures uint64
+	Timeouts uint64
+
+	// ConsecutiveFailures counts the failures since the last success.
+	ConsecutiveFailures int
+
+	// DownUntil is the end of the time the server is skipped after it
+	// failed too often. It is zero if the server is healthy.
+	DownUntil time.Time
+}
+
+// Down reports whether the server is skipped at time t.
+func (s *ServerStats) Down(t time.Time) bool {
+	return t.Before(s.DownUntil)
+}
+
+// ServerSelector orders servers for queries by their smoothed round trip
+// time and tracks their health. Servers that failed recently come last.
+// After FailureThreshold consecutive failures a server is skipped for a
+// back-off time that doubles with every further failure (a circuit
+// breaker); when the time has passed the server is tried again and a
+// success makes it healthy. Servers that are down are only tried after all
+// others. A share of ProbeRate queries go to a random healthy server
+// first, so that slower servers are probed and their statistics stay
+// current.
+//
+// The zero value is ready to use and safe for concurrent use.
+type ServerSelector struct {
+	// ProbeRate defaults to DefaultProbeRate. Set it to a negative value
+	// to disable probing.
+	ProbeRate float64
+
+	// FailureThreshold defaults to DefaultFailureThreshold.
+	FailureThreshold int
+
+	// Backoff defaults to DefaultBackoff.
+	Backoff time.Duration
+
+	mu      sync.Mutex
+	servers map[string]*ServerStats
+
+	// now returns the current time and is replaced by tests.
+	now func() time.Time
+}
+
+func (s *ServerSelector) time() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// stats returns the statistics of server. s.mu must be held.
+func (s *ServerSelector) stats(server string) *ServerStats {
+	if s.servers == nil {
+		s.servers = make(map[string]*ServerStats)
+	}
+	st, ok := s.servers[server]
+	if !ok {
+		st = &ServerStats{Server: server}
+		s.servers[server] = st
+	}
+	return st
+}
+
+// Order returns servers in the order they should be tried: healthy
+// servers by increasing number of recent failures and smoothed RTT, with
+// servers without statistics first, followed by the servers that are
+// down.
+func (s *ServerSelector) Order(servers []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.time()
+	var healthy, down []*ServerStats
+	for _, server := range servers {
+		st := s.stats(server)
+		if st.Down(now) {
+			down = append(down, st)
+		} else {
+			healthy = append(healthy, st)
+		}
+	}
+	slices.SortStableFunc(healthy, func(a, b *ServerStats) int {
+		return cmp.Or(cmp.Compare(a.ConsecutiveFailures, b.ConsecutiveFailures), cmp.Compare(a.SRTT, b.SRTT))
+	})
+	slices.SortStableFunc(down, func(a, b *ServerStats) int { return a.DownUntil.Compare(b.DownUntil) })
+
+	rate := s.ProbeRate
+	if rate == 0 {
+		rate = DefaultProbeRate
+	}
+	if len(healthy) > 1 && rand.Float64() < rate {
+		i := 1 + rand.IntN(len(healthy)-1)
+		probe := healthy[i]
+		copy(healthy[1:i+1], healthy[:i])
+		healthy[0] = probe
+	}
+
+	order := make([]string, 0, len(servers))
+	for _, st := range append(healthy, down...) {
+		order = append(order, st.Server)
+	}
+	return order
+}
+
+// Success records a response of server after rtt.
+func (s *ServerSelector) Success(server string, rtt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stats(server)
+	st.Queries++
+	st.ConsecutiveFailures = 0
+	st.DownUntil = time.Time{}
+	if st.SRTT == 0 {
+		st.SRTT = rtt
+	} else {
+		// RFC 6298 section 2 with alpha 1/8.
+		st.SRTT += (rtt - st.SRTT) / 8
+	}
+}
+
+// Failure records that server failed to answer or answered with an
+// error. Timeouts also increase the smoothed RTT.
+func (s *ServerSelector) Failure(server string, timeout bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stats(server)
+	st.Queries++
+	st.Failures++
+	st.ConsecutiveFailures++
+	if timeout {
+		st.Timeouts++
+		st.SRTT = min(max(2*st.SRTT, time.Second), maxSRTT)
+	}
+
+	threshold := s.FailureThreshold
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+	if excess := st.ConsecutiveFailures - threshold; excess >= 0 {
+		backoff := s.Backoff
+		if backoff <= 0 {
+			backoff = DefaultBackoff
+		}
+		backoff = min(backoff<<min(excess, 16), maxBackoff)
+		st.DownUntil = s.time().Add(backoff)
+	}
+}
+
+// Record records the outcome of an exchange with server that took rtt:
+// errors and responses with SERVFAIL, NOTIMP or REFUSED count as failures.
+func (s *ServerSelector) Record(server string, rtt time.Duration, resp *Message, err error) {
+	switch {
+	case err != nil:
+		s.Failure(server, isTimeout(err))
+	case isServerError(resp):
+		s.Failure(server, false)
+	default:
+		s.Success(server, rtt)
+	}
+}
+
+// Stats returns the statistics of all servers the selector has seen,
+// sorted by server.
+func (s *ServerSelector) Stats() []ServerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]ServerStats, 0, len(s.servers))
+	for _, st := range s.servers {
+		stats = append(stats, *st)
+	}
+	slices.SortFunc(stats, func(a, b ServerStats) int { return cmp.Compare(a.Server, b.Server) })
+	return stats
+}
+
+// isServerError reports whether the response code of resp tells to try
+// another server.
+func isServerError(resp *Message) bool {
+	switch resp.Header.ResponseCode() {
+	case RCodeServerFailure, RCodeNotImplemented, RCodeRefused:
+		return true
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// Pool sends queries to the fastest healthy of several upstreams as
+// chosen by its ServerSelector. Upstreams are identified by their String
+// method, if they have one, or by type and position.
+type Pool struct {
+	Upstreams []Exchanger
+	Selector  ServerSelector
+}
+
+// NewPool returns a Pool of upstreams.
+func NewPool(upstreams ...Exchanger) *Pool {
+	return &Pool{Upstreams: upstreams}
+}
+
+// Exchange sends q to the upstreams in the order of the selector until
+// one gives a usable response. If none does, the last response or error
+// is returned.
+func (p *Pool) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	if len(p.Upstreams) == 0 {
+		return nil, ErrNoServers
+	}
+
+	names := make([]string, len(p.Upstreams))
+	byName := make(map[string]Exchanger, len(p.Upstreams))
+	for i, up := range p.Upstreams {
+		names[i] = upstreamName(up, i)
+		byName[names[i]] = up
+	}
+
+	var lastResp *Message
+	var lastErr error
+	for _, name := range p.Selector.Order(names) {
+		start := time.Now()
+		resp, err := byName[name].Exchange(ctx, q)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		p.Selector.Record(name, time.Since(start), resp, err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if isServerError(resp) {
+			lastResp = resp
+			continue
+		}
+		return resp, nil
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	return nil, lastErr
+}
+
+// Stats returns the statistics of the upstreams.
+func (p *Pool) Stats() []ServerStats {
+	return p.Selector.Stats()
+}
+
+func upstreamName(ex Exchanger, i int) string {
+	if s, ok := ex.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T#%d", ex, i)
+}
@@ -0,0 +1,128 @@
+package dns
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestServerSelectorOrder(t *testing.T) {
+	clock := &testClock{t: time.Unix(1700000000, 0)}
+	s := &ServerSelector{ProbeRate: -1, now: clock.now}
+
+	s.Success("a", 50*time.Millisecond)
+	s.Success("b", 10*time.Millisecond)
+	if got := s.Order([]string{"a", "b", "c"}); !slices.Equal(got, []string{"c", "b", "a"}) {
+		t.Fatalf("Unexpected order %v", got)
+	}
+
+	// The smoothed RTT moves slowly towards new measurements.
+	s.Success("b", 90*time.Millisecond)
+	if st := s.Stats()[1]; st.Server != "b" || st.SRTT != 20*time.Millisecond {
+		t.Fatalf("Unexpected stats %+v", st)
+	}
+
+	s.Failure("a", true)
+	st := s.Stats()[0]
+	if st.Timeouts != 1 || st.SRTT != time.Second || st.Queries != 2 {
+		t.Fatalf("Unexpected stats after a timeout %+v", st)
+	}
+}
+
+func TestServerSelectorCircuitBreaker(t *testing.T) {
+	clock := &testClock{t: time.Unix(1700000000, 0)}
+	s := &ServerSelector{ProbeRate: -1, now: clock.now}
+	servers := []string{"a", "b"}
+
+	for range DefaultFailureThreshold {
+		s.Failure("a", false)
+	}
+	if got := s.Order(servers); !slices.Equal(got, []string{"b", "a"}) {
+		t.Fatalf("Expected a to be down but got %v", got)
+	}
+	if st := s.Stats()[0]; !st.Down(clock.now()) || st.DownUntil != clock.now().Add(DefaultBackoff) {
+		t.Fatalf("Unexpected stats %+v", st)
+	}
+
+	// After the back-off a is healthy again, another failure doubles it.
+	clock.advance(DefaultBackoff)
+	if st := s.Stats()[0]; st.Down(clock.now()) {
+		t.Fatalf("Expected a to be tried again but got %+v", st)
+	}
+	s.Failure("a", false)
+	if st := s.Stats()[0]; st.DownUntil != clock.now().Add(2*DefaultBackoff) {
+		t.Fatalf("Expected a doubled back-off but got %+v", st)
+	}
+
+	clock.advance(2 * DefaultBackoff)
+	s.Success("a", time.Millisecond)
+	if st := s.Stats()[0]; st.Down(clock.now()) || st.ConsecutiveFailures != 0 || st.Failures != 4 {
+		t.Fatalf("Expected a to be healthy but got %+v", st)
+	}
+}
+
+func TestServerSelectorProbe(t *testing.T) {
+	s := &ServerSelector{ProbeRate: 1}
+	s.Success("a", time.Millisecond)
+	s.Success("b", 2*time.Millisecond)
+	s.Success("c", 3*time.Millisecond)
+
+	probed := map[string]bool{}
+	for range 100 {
+		order := s.Order([]string{"a", "b", "c"})
+		if order[0] == "a" {
+			t.Fatalf("Expected a slower server first but got %v", order)
+		}
+		probed[order[0]] = true
+	}
+	if !probed["b"] || !probed["c"] {
+		t.Fatalf("Expected both slower servers to be probed but got %v", probed)
+	}
+}
+
+// delayExchanger answers after a delay.
+type delayExchanger struct {
+	name  string
+	delay time.Duration
+	fail  bool
+}
+
+func (e *delayExchanger) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	time.Sleep(e.delay)
+	if e.fail {
+		return nil, ErrNoServers
+	}
+	return testReply(q, RCodeNoError), nil
+}
+
+func (e *delayExchanger) String() string {
+	return e.name
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(
+		&delayExchanger{name: "broken", fail: true},
+		&delayExchanger{name: "slow", delay: 20 * time.Millisecond},
+		&delayExchanger{name: "fast", delay: time.Millisecond},
+	)
+	p.Selector.ProbeRate = -1
+
+	for range 5 {
+		q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+		if _, err := p.Exchange(context.Background(), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := p.Stats()
+	if len(stats) != 3 || stats[0].Server != "broken" || stats[1].Server != "fast" {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	if stats[0].Queries != 1 || stats[0].ConsecutiveFailures != 1 {
+		t.Fatalf("Expected the broken upstream to be avoided: %+v", stats[0])
+	}
+	if stats[1].Queries != 4 || stats[2].Queries != 1 {
+		t.Fatalf("Expected the fast upstream to be preferred: %+v", stats)
+	}
+}
@@ -0,0 +1,35 @@
+package dns
+
+// CompareSerial compares the zone serial numbers a and b with the serial
+// number arithmetic of RFC 1982, in which serials wrap around after
+// 2^32 - 1. The result is -1 if a is older than b, 1 if it is newer and 0
+// if they are equal. Serials that are exactly 2^31 apart, which RFC 1982
+// leaves undefined, are older than each other.
+func CompareSerial(a, b uint32) int {
+	switch d := int32(a - b); {
+	case d == 0:
+		return 0
+	case d < 0:
+		return -1
+	}
+	return 1
+}
+
+// soaData returns the RDATA of the SOA record rr or an empty SOA if rr is
+// no valid SOA record.
+func soaData(rr *ResourceRecord) *SOA {
+	data, err := rr.Rdata()
+	if err != nil {
+		return &SOA{}
+	}
+	if soa, ok := data.(*SOA); ok {
+		return soa
+	}
+	return &SOA{}
+}
+
+// soaSerial returns the serial of the SOA record rr or 0 if rr is no valid
+// SOA record.
+func soaSerial(rr *ResourceRecord) uint32 {
+	return soaData(rr).Serial
+}
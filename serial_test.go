@@ -0,0 +1,25 @@
+package dns
+
+import "testing"
+
+func TestCompareSerial(t *testing.T) {
+	tests := []struct {
+		a, b     uint32
+		expected int
+	}{
+		{1, 1, 0},
+		{1, 2, -1},
+		{2, 1, 1},
+		{0xFFFFFFFF, 0, -1},
+		{0, 0xFFFFFFFF, 1},
+		{0x7FFFFFFF, 0, 1},
+		{0x80000001, 0, -1},
+		{0x80000000, 0, -1},
+		{0, 0x80000000, -1},
+	}
+	for _, tc := range tests {
+		if c := CompareSerial(tc.a, tc.b); c != tc.expected {
+			t.Errorf("CompareSerial(%d, %d) = %d, expected %d", tc.a, tc.b, c, tc.expected)
+		}
+	}
+}
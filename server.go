@@ -0,0 +1,367 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultServerTimeout limits the time a Server spends on a query.
+	DefaultServerTimeout = 5 * time.Second
+
+	// DefaultIdleTimeout is the time a Server keeps idle TCP connections
+	// open (RFC 7766 section 6.2.3).
+	DefaultIdleTimeout = 10 * time.Second
+)
+
+// Server answers queries received over UDP and TCP with Handler.
+//
+// The Server takes care of the wire format: responses get the Id of the
+// query, carry an OPT record if and only if the query had one, and UDP
+// responses are truncated to the payload size the client advertised
+// (512 octets without EDNS), so that the client retries over TCP.
+// Malformed queries are answered with FORMERR and queries the Handler
+// refuses with ErrQueryDenied are dropped. Zone transfers are streamed
+// over TCP if the Handler is a Transferer; IXFR is also answered over UDP.
+type Server struct {
+	// Addr is the address to listen on. It defaults to ":53".
+	Addr string
+
+	// Handler answers the queries. It is required.
+	Handler Exchanger
+
+	// UDPSize is the largest UDP payload the Server sends and advertises.
+	// It defaults to DefaultEDNSUDPSize.
+	UDPSize uint16
+
+	// Timeout limits the Handler. It defaults to DefaultServerTimeout.
+	Timeout time.Duration
+
+	// IdleTimeout closes idle TCP connections. It defaults to
+	// DefaultIdleTimeout.
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	closed    bool
+	pcs       []net.PacketConn
+	listeners []net.Listener
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup
+}
+
+// ListenAndServe listens on UDP and TCP on Addr and serves until Close is
+// called.
+func (s *Server) ListenAndServe() error {
+	if s.Handler == nil {
+		return ErrNoHandler
+	}
+	addr := s.Addr
+	if addr == "" {
+		addr = ":" + DefaultPort
+	}
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	return s.Serve(pc, l)
+}
+
+// Serve answers queries received on pc and l until Close is called. One
+// of them may be nil. ErrNoHandler is returned without a Handler.
+func (s *Server) Serve(pc net.PacketConn, l net.Listener) error {
+	if s.Handler == nil {
+		return ErrNoHandler
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	errc := make(chan error, 2)
+	n := 0
+	if pc != nil {
+		s.pcs = append(s.pcs, pc)
+		n++
+		go func() { errc <- s.servePacket(pc) }()
+	}
+	if l != nil {
+		s.listeners = append(s.listeners, l)
+		n++
+		go func() { errc <- s.serveStream(l) }()
+	}
+	s.mu.Unlock()
+
+	var err error
+	for range n {
+		if e := <-errc; err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close stops the Server and closes its connections. It waits for the
+// queries in progress.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for _, pc := range s.pcs {
+		pc.Close()
+	}
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// start registers a goroutine that Close waits for. It reports false once
+// the Server is closed, so that no goroutine is added while Close waits.
+func (s *Server) start() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) servePacket(pc net.PacketConn) error {
+	buf := make([]byte, maxMessageLen)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		b := append([]byte(nil), buf[:n]...)
+		if !s.start() {
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.wg.Done()
+			s.handle(b, true, func(resp []byte) error {
+				_, err := pc.WriteTo(resp, addr)
+				return err
+			})
+		}()
+	}
+}
+
+func (s *Server) serveStream(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		if s.conns == nil {
+			s.conns = make(map[net.Conn]bool)
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serveConn answers the queries of a TCP connection in order.
+func (s *Server) serveConn(conn net.Conn) {
+	idle := s.IdleTimeout
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		b, err := ReadTCPMessage(conn)
+		if err != nil {
+			return
+		}
+		var werr error
+		s.handle(b, false, func(resp []byte) error {
+			werr = WriteTCPMessage(conn, resp)
+			return werr
+		})
+		if werr != nil {
+			return
+		}
+	}
+}
+
+// handle answers the query in b and passes the encoded responses to
+// write as they are built, stopping at the first error of write. Dropped
+// queries get no response and only zone transfers have more than one.
+func (s *Server) handle(b []byte, udp bool, write func([]byte) error) {
+	q, err := ReadMessage(b)
+	if err != nil {
+		hdr, herr := ReadHeader(b)
+		if herr != nil || hdr.IsResponse() {
+			return
+		}
+		resp := &Message{Header: hdr}
+		resp.Header.SetResponse(true)
+		resp.Header.Flags &^= 0xF
+		resp.Header.SetResponseCode(RCodeFormatError)
+		write(resp.Encode())
+		return
+	}
+	if q.Header.IsResponse() {
+		return
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultServerTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var resps iter.Seq[*Message]
+	switch {
+	case q.Header.Opcode() != OpcodeQuery:
+		resps = single(errorResponse(q, RCodeNotImplemented))
+	case len(q.Question) != 1:
+		resps = single(errorResponse(q, RCodeFormatError))
+	case isTransfer(q.Question[0].Type):
+		resps = s.transfer(ctx, q, udp)
+	default:
+		resp, err := s.Handler.Exchange(ctx, q)
+		if errors.Is(err, ErrQueryDenied) {
+			return
+		}
+		if err != nil {
+			resp = errorResponse(q, RCodeServerFailure)
+		}
+		resps = single(resp)
+	}
+
+	limit := maxMessageLen
+	if udp {
+		limit = min(q.UDPSize(), s.udpSize())
+	}
+	for resp := range resps {
+		resp = s.prepare(q, resp)
+		resp.Truncate(limit)
+		if write(resp.Encode()) != nil {
+			return
+		}
+	}
+}
+
+// single returns an iterator over msg alone.
+func single(msg *Message) iter.Seq[*Message] {
+	return func(yield func(*Message) bool) {
+		yield(msg)
+	}
+}
+
+// transfer answers the zone transfer request q with the Transferer of the
+// Server. AXFR is only served over TCP. An IXFR response that does not fit
+// into a UDP message is replaced with the current SOA record, which tells
+// the client to retry over TCP (RFC 1995 section 2).
+func (s *Server) transfer(ctx context.Context, q *Message, udp bool) iter.Seq[*Message] {
+	t, ok := s.Handler.(Transferer)
+	if !ok || udp && q.Question[0].Type != TypeIXFR {
+		return single(errorResponse(q, RCodeNotImplemented))
+	}
+	records, err := t.Transfer(ctx, q)
+	if err != nil {
+		return single(errorResponse(q, transferRcode(err)))
+	}
+	if !udp {
+		return transferMessages(q, records)
+	}
+
+	// A UDP response has to consist of a single message.
+	var first *Message
+	for msg := range transferMessages(q, records) {
+		if first != nil {
+			first = nil
+			break
+		}
+		first = msg
+	}
+	if first == nil || len(s.prepare(q, first).Encode()) > min(q.UDPSize(), s.udpSize()) {
+		return transferMessages(q, records[:1])
+	}
+	return single(first)
+}
+
+func (s *Server) udpSize() int {
+	if s.UDPSize >= minUDPSize {
+		return int(s.UDPSize)
+	}
+	return DefaultEDNSUDPSize
+}
+
+// prepare returns a copy of resp with the Id and flags of q and an OPT
+// record if q has one.
+func (s *Server) prepare(q, resp *Message) *Message {
+	hdr := *resp.Header
+	out := &Message{Header: &hdr, Question: resp.Question, Answer: resp.Answer, Authority: resp.Authority}
+	if out.Question == nil {
+		out.Question = q.Question
+	}
+	out.Header.Id = q.Header.Id
+	out.Header.SetResponse(true)
+	out.Header.SetRecursionDesired(q.Header.IsRecursionDesired())
+
+	for _, rr := range resp.Additional {
+		if rr.Type != TypeOPT {
+			out.Additional = append(out.Additional, rr)
+		}
+	}
+	if opt := q.OPT(); opt != nil {
+		out.SetEDNS0(uint16(s.udpSize()), opt.TTL&flagDNSSECOK != 0)
+	}
+	return out
+}
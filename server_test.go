@@ -0,0 +1,183 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveTest serves s on a free local port for UDP and TCP and returns the
+// address.
+func serveTest(t *testing.T, s *Server) string {
+	t.Helper()
+
+	// The TCP port of a free UDP port may be taken, so try a few.
+	var pc net.PacketConn
+	var l net.Listener
+	for i := 0; l == nil; i++ {
+		var err error
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err != nil {
+			pc.Close()
+			if i == 9 {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(pc, l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v", err)
+		}
+	})
+	return pc.LocalAddr().String()
+}
+
+type handlerFunc func(ctx context.Context, q *Message) (*Message, error)
+
+func (f handlerFunc) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	return f(ctx, q)
+}
+
+// manyAnswers answers with n A records.
+func manyAnswers(n int) Exchanger {
+	return handlerFunc(func(ctx context.Context, q *Message) (*Message, error) {
+		var answers []*ResourceRecord
+		for i := range n {
+			answers = append(answers, testA(q.Question[0].Name, 192, 0, 2, byte(i)))
+		}
+		return testReply(q, RCodeNoError, answers...), nil
+	})
+}
+
+// exchangeRaw sends b over UDP to addr and returns the raw response.
+func exchangeRaw(t *testing.T, addr string, b []byte) []byte {
+	t.Helper()
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, maxMessageLen)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil
+	}
+	return buf[:n]
+}
+
+func TestServerTruncation(t *testing.T) {
+	addr := serveTest(t, &Server{Handler: manyAnswers(100)})
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	q.Header.SetRecursionDesired(true)
+	b := exchangeRaw(t, addr, q.Encode())
+	resp, err := ReadMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > minUDPSize || !resp.Header.IsTruncated() || resp.OPT() != nil {
+		t.Fatalf("Expected a truncated response of at most 512 octets without OPT, got %d octets: %v", len(b), resp.Header)
+	}
+	if resp.Header.Id != q.Header.Id || !resp.Header.IsRecursionDesired() {
+		t.Fatalf("Header does not match the query: %v", resp.Header)
+	}
+
+	q.SetEDNS0(4096, true)
+	b = exchangeRaw(t, addr, q.Encode())
+	resp, err = ReadMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > DefaultEDNSUDPSize || !resp.Header.IsTruncated() {
+		t.Fatalf("Expected a truncated response within the server's UDP size, got %d octets", len(b))
+	}
+	if opt := resp.OPT(); opt == nil || resp.UDPSize() != DefaultEDNSUDPSize || opt.TTL&flagDNSSECOK == 0 {
+		t.Fatalf("Unexpected OPT record %v", opt)
+	}
+
+	c := &Client{Timeout: time.Second}
+	resp, err = c.Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.IsTruncated() || len(resp.Answer) != 100 {
+		t.Fatalf("Expected all answers over TCP, got %d", len(resp.Answer))
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	addr := serveTest(t, &Server{Handler: handlerFunc(func(ctx context.Context, q *Message) (*Message, error) {
+		return nil, errors.New("Broken.")
+	})})
+
+	q, _ := NewQuery("git.noteip.de", TypeA, ClassIN)
+	b := q.Encode()
+	resp, err := ReadMessage(exchangeRaw(t, addr, b[:len(b)-2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeFormatError || resp.Header.Id != q.Header.Id {
+		t.Fatalf("Expected FORMERR for a malformed query, got %v", resp.Header)
+	}
+
+	resp, err = ReadMessage(exchangeRaw(t, addr, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeServerFailure {
+		t.Fatalf("Expected SERVFAIL for a handler error, got %v", resp.Header)
+	}
+
+	q.Header.SetOpcode(OpcodeStatus)
+	resp, err = ReadMessage(exchangeRaw(t, addr, q.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNotImplemented {
+		t.Fatalf("Expected NOTIMP for a status query, got %v", resp.Header)
+	}
+
+	q.Header.SetResponse(true)
+	if b := exchangeRaw(t, addr, q.Encode()); b != nil {
+		t.Fatal("Responses must not be answered.")
+	}
+
+	if err := (&Server{}).Serve(nil, nil); !errors.Is(err, ErrNoHandler) {
+		t.Fatalf("Expected ErrNoHandler, got %v", err)
+	}
+}
+
+func TestServerDeny(t *testing.T) {
+	r := &Router{}
+	r.LocalZone("blocked.example", LocalDeny)
+	r.LocalZone("refused.example", LocalRefuse)
+	addr := serveTest(t, &Server{Handler: r})
+
+	q, _ := NewQuery("ads.blocked.example", TypeA, ClassIN)
+	if b := exchangeRaw(t, addr, q.Encode()); b != nil {
+		t.Fatal("Denied queries must be dropped.")
+	}
+
+	q, _ = NewQuery("ads.refused.example", TypeA, ClassIN)
+	c := &Client{Net: "tcp", Timeout: time.Second}
+	resp, err := c.Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeRefused {
+		t.Fatalf("Expected REFUSED, got %v", resp.Header)
+	}
+}
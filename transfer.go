@@ -0,0 +1,237 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+)
+
+// TransferError reports a zone transfer that failed with a response code.
+// Transferers return it to make a Server answer with Rcode.
+type TransferError struct {
+	Zone  DNSName
+	Rcode Rcode
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("Zone transfer of %s failed: %s", e.Zone, e.Rcode)
+}
+
+// Transferer is implemented by handlers that serve zone transfers. A
+// Server passes AXFR queries received over TCP and IXFR queries to
+// Transfer instead of Exchange.
+type Transferer interface {
+	// Transfer returns the records to send in response to q in order,
+	// starting and ending with the SOA record of the zone, except for
+	// IXFR responses that consist of the SOA record only. A
+	// TransferError selects the response code of a failed transfer,
+	// other errors are answered with SERVFAIL.
+	Transfer(ctx context.Context, q *Message) ([]*ResourceRecord, error)
+}
+
+// isTransfer reports whether t requests a zone transfer.
+func isTransfer(t Type) bool {
+	return t == TypeAXFR || t == TypeIXFR
+}
+
+// optLen is the length of an OPT record without options.
+const optLen = 11
+
+// transferMessages packs records into responses to q that fit into TCP
+// messages (RFC 5936 section 2.2). The messages are built as the
+// iteration proceeds.
+func transferMessages(q *Message, records []*ResourceRecord) iter.Seq[*Message] {
+	return func(yield func(*Message) bool) {
+		newMessage := func() *Message {
+			msg := errorResponse(q, RCodeNoError)
+			msg.Header.SetAuthoritativeAnswer(true)
+			return msg
+		}
+
+		// Records are counted uncompressed, so the messages may end up
+		// smaller than necessary but never too large.
+		base := len(newMessage().Encode()) + optLen
+		msg, size := newMessage(), base
+		for _, rr := range records {
+			n := len(rr.Encode(nil))
+			if len(msg.Answer) > 0 && size+n > maxMessageLen {
+				if !yield(msg) {
+					return
+				}
+				msg, size = newMessage(), base
+			}
+			msg.Answer = append(msg.Answer, rr)
+			size += n
+		}
+		yield(msg)
+	}
+}
+
+// transferRcode returns the response code for a failed transfer.
+func transferRcode(err error) Rcode {
+	var te *TransferError
+	switch {
+	case errors.As(err, &te):
+		return te.Rcode
+	case errors.Is(err, ErrNotInZone):
+		return RCodeNotAuth
+	}
+	return RCodeServerFailure
+}
+
+// Transfer answers AXFR queries for the origin with the records of the
+// current snapshot and IXFR queries with the changes from the journal.
+// IXFR queries without the SOA record of the client fail with FORMERR,
+// queries for other names with NOTAUTH.
+func (z *Zone) Transfer(ctx context.Context, q *Message) ([]*ResourceRecord, error) {
+	if len(q.Question) != 1 || !isTransfer(q.Question[0].Type) {
+		return nil, &TransferError{Zone: z.Origin, Rcode: RCodeNotImplemented}
+	}
+	if !q.Question[0].Name.Equal(z.Origin) {
+		return nil, ErrNotInZone
+	}
+	if q.Question[0].Type == TypeAXFR {
+		return z.Snapshot().axfr()
+	}
+	serial, ok := ixfrSerial(q, z.Origin)
+	if !ok {
+		return nil, &TransferError{Zone: z.Origin, Rcode: RCodeFormatError}
+	}
+	return z.ixfr(serial)
+}
+
+// axfr returns the records of the tree framed by the SOA record.
+func (t *ZoneTree) axfr() ([]*ResourceRecord, error) {
+	soa := t.SOA()
+	if soa == nil {
+		return nil, &TransferError{Zone: t.origin, Rcode: RCodeServerFailure}
+	}
+	records := make([]*ResourceRecord, 0, t.size+1)
+	records = append(records, soa)
+	for _, rr := range t.Records() {
+		if rr != soa {
+			records = append(records, rr)
+		}
+	}
+	return append(records, soa), nil
+}
+
+// AXFR transfers zone from server (RFC 5936) over TCP, or TLS if Net is
+// "tcp-tls" (RFC 9103), and returns its records starting with the SOA
+// record, which is not repeated at the end. The stream has to start and
+// end with the same SOA record; otherwise ErrBadTransfer is yielded. The
+// iteration stops at the first error, which is yielded with a nil record.
+// The Timeout of c applies to each message.
+func (c *Client) AXFR(ctx context.Context, zone DNSName, server string) iter.Seq2[*ResourceRecord, error] {
+	return func(yield func(*ResourceRecord, error) bool) {
+		q, err := NewQuery(string(zone), TypeAXFR, ClassIN)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var soa *ResourceRecord
+		for resp, err := range c.transfer(ctx, q, server) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if rcode := resp.Header.ResponseCode(); rcode != RCodeNoError {
+				yield(nil, &TransferError{Zone: zone, Rcode: rcode})
+				return
+			}
+
+			for i, rr := range resp.Answer {
+				switch {
+				case soa == nil:
+					if rr.Type != TypeSOA || !rr.Name.Equal(zone) {
+						yield(nil, ErrBadTransfer)
+						return
+					}
+					soa = rr
+				case rr.Type == TypeSOA && rr.Name.Equal(zone):
+					if !sameRecord(rr, soa) || i != len(resp.Answer)-1 {
+						yield(nil, ErrBadTransfer)
+						return
+					}
+					return
+				}
+				if !yield(rr, nil) {
+					return
+				}
+			}
+			if soa == nil {
+				yield(nil, ErrBadTransfer)
+				return
+			}
+		}
+	}
+}
+
+// transfer sends q to server over a stream connection and returns the
+// responses until the iteration stops.
+func (c *Client) transfer(ctx context.Context, q *Message, server string) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		network := "tcp"
+		if c.Net == "tcp-tls" {
+			network = c.Net
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, c.timeout())
+		conn, err := c.dial(dialCtx, network, server)
+		cancel()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer conn.Close()
+		stop := context.AfterFunc(ctx, func() {
+			conn.SetDeadline(time.Unix(1, 0))
+		})
+		defer stop()
+
+		if err := WriteTCPMessage(conn, q.Encode()); err != nil {
+			yield(nil, ctxError(ctx, err))
+			return
+		}
+		for first := true; ; first = false {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			deadline := time.Now().Add(c.timeout())
+			if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+				deadline = d
+			}
+			conn.SetReadDeadline(deadline)
+
+			b, err := ReadTCPMessage(conn)
+			if err != nil {
+				if err == io.EOF {
+					// The server closed the connection before the
+					// end of the transfer.
+					err = io.ErrUnexpectedEOF
+				}
+				yield(nil, ctxError(ctx, err))
+				return
+			}
+			resp, err := ReadMessage(b)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			// Only the first message has to repeat the question
+			// (RFC 5936 section 2.2.1).
+			continued := !first && len(resp.Question) == 0 && resp.Header.IsResponse() && resp.Header.Id == q.Header.Id
+			if !continued && !IsResponseTo(q, resp) {
+				yield(nil, ErrUnexpectedResponse)
+				return
+			}
+			if !yield(resp, nil) {
+				return
+			}
+		}
+	}
+}
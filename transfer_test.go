@@ -0,0 +1,203 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newLargeZone returns a zone that needs several messages to transfer.
+func newLargeZone(t *testing.T, n int) *Zone {
+	t.Helper()
+
+	z := newTestZone(t)
+	for i := range n {
+		name := DNSName(fmt.Sprintf("host%d.large.example.com", i))
+		if err := z.Add(NewResourceRecord(name, ClassIN, 300, &TXT{Strings: []string{strings.Repeat("x", 100)}})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return z
+}
+
+func collectAXFR(c *Client, zone DNSName, addr string) ([]*ResourceRecord, error) {
+	var records []*ResourceRecord
+	for rr, err := range c.AXFR(context.Background(), zone, addr) {
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rr)
+	}
+	return records, nil
+}
+
+func TestTransferMessages(t *testing.T) {
+	z := newLargeZone(t, 2000)
+	q, _ := NewQuery("example.com", TypeAXFR, ClassIN)
+	records, err := z.Transfer(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].Type != TypeSOA || records[len(records)-1] != records[0] || len(records) != z.Snapshot().Len()+1 {
+		t.Fatalf("Transfer is not framed by the SOA record: %d records", len(records))
+	}
+
+	msgs := slices.Collect(transferMessages(q, records))
+	if len(msgs) < 2 {
+		t.Fatalf("Expected several messages, got %d", len(msgs))
+	}
+	n := 0
+	for _, msg := range msgs {
+		msg.SetEDNS0(DefaultEDNSUDPSize, false)
+		if l := len(msg.Encode()); l > maxMessageLen {
+			t.Fatalf("Message of %d octets does not fit into a TCP frame", l)
+		}
+		if !msg.Header.IsAuthoritativeAnswer() {
+			t.Fatal("Transfer messages should be authoritative.")
+		}
+		n += len(msg.Answer)
+	}
+	if n != len(records) {
+		t.Fatalf("Expected %d records in the messages, got %d", len(records), n)
+	}
+}
+
+func TestServerStreamsTransfer(t *testing.T) {
+	s := &Server{Handler: newLargeZone(t, 2000)}
+	q, _ := NewQuery("example.com", TypeAXFR, ClassIN)
+
+	n := 0
+	s.handle(q.Encode(), false, func(b []byte) error {
+		n++
+		return nil
+	})
+	if n < 2 {
+		t.Fatalf("Expected several messages, got %d", n)
+	}
+
+	// The transfer stops at the first failed write.
+	writes := 0
+	s.handle(q.Encode(), false, func(b []byte) error {
+		writes++
+		return io.ErrClosedPipe
+	})
+	if writes != 1 {
+		t.Fatalf("Expected the transfer to stop after a failed write, got %d writes", writes)
+	}
+}
+
+func TestAXFR(t *testing.T) {
+	z := newLargeZone(t, 2000)
+	addr := serveTest(t, &Server{Handler: z})
+
+	c := &Client{Timeout: time.Second}
+	records, err := collectAXFR(c, "example.com", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := z.Records()
+	if len(records) != len(expected) || records[0].Type != TypeSOA {
+		t.Fatalf("Expected %d records starting with the SOA, got %d", len(expected), len(records))
+	}
+	seen := make(map[string]bool)
+	for _, rr := range records {
+		seen[rr.String()] = true
+	}
+	for _, rr := range expected {
+		if !seen[rr.String()] {
+			t.Fatalf("Missing record %v", rr)
+		}
+	}
+
+	var te *TransferError
+	if _, err := collectAXFR(c, "example.org", addr); !errors.As(err, &te) || te.Rcode != RCodeNotAuth {
+		t.Fatalf("Expected NOTAUTH, got %v", err)
+	}
+
+	// Transfers are not served over UDP.
+	q, _ := NewQuery("example.com", TypeAXFR, ClassIN)
+	resp, err := c.Exchange(context.Background(), q, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNotImplemented {
+		t.Fatalf("Expected NOTIMP over UDP, got %v", resp.Header)
+	}
+}
+
+// serveStream answers the first query on a TCP connection with msgs and
+// closes the connection.
+func serveStream(t *testing.T, msgs func(q *Message) []*Message) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			b, err := ReadTCPMessage(conn)
+			if q, perr := ReadMessage(b); err == nil && perr == nil {
+				for _, msg := range msgs(q) {
+					WriteTCPMessage(conn, msg.Encode())
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestAXFRInvalid(t *testing.T) {
+	soa := testSOAFor("example.com")
+	other := NewResourceRecord("example.com", ClassIN, 300, &SOA{MName: "ns.example.com", Serial: 2})
+	www := testAddr("www.example.com", "192.0.2.1")
+
+	tests := []struct {
+		name    string
+		answers [][]*ResourceRecord
+		err     error
+	}{
+		{"no SOA first", [][]*ResourceRecord{{www, soa}}, ErrBadTransfer},
+		{"empty", [][]*ResourceRecord{{}}, ErrBadTransfer},
+		{"other SOA last", [][]*ResourceRecord{{soa, www}, {other}}, ErrBadTransfer},
+		{"records after SOA", [][]*ResourceRecord{{soa, www, soa, www}}, ErrBadTransfer},
+		{"incomplete", [][]*ResourceRecord{{soa, www}}, io.ErrUnexpectedEOF},
+	}
+
+	c := &Client{Timeout: time.Second}
+	for _, test := range tests {
+		addr := serveStream(t, func(q *Message) []*Message {
+			var msgs []*Message
+			for _, answers := range test.answers {
+				msgs = append(msgs, testReply(q, RCodeNoError, answers...))
+			}
+			return msgs
+		})
+		if _, err := collectAXFR(c, "example.com", addr); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// Later messages may omit the question.
+	addr := serveStream(t, func(q *Message) []*Message {
+		next := testReply(q, RCodeNoError, www, soa)
+		next.Question = nil
+		return []*Message{testReply(q, RCodeNoError, soa), next}
+	})
+	records, err := collectAXFR(c, "example.com", addr)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Unexpected result %v %v", records, err)
+	}
+}
@@ -1,7 +1,15 @@
 package dns
 
+import (
+	"strconv"
+	"strings"
+)
+
+// Type is the TYPE (or QTYPE) field of a resource record or question.
+type Type uint16
+
 const (
-	_ = iota
+	_ Type = iota
 	// A host address
 	TypeA
 	// An authoritative name server
@@ -30,22 +38,376 @@ const (
 	TypeHINFO
 	// Mailbox or mail list information
 	TypeMINFO
+	// Mail exchange
+	TypeMX
+	// Text strings
+	TypeTXT
+	// Responsible person
+	TypeRP
+	// AFS data base location
+	TypeAFSDB
+	// X.25 PSDN address
+	TypeX25
+	// ISDN address
+	TypeISDN
+	// Route through
+	TypeRT
+	// NSAP style A record
+	TypeNSAP
+	// Domain name pointer, NSAP style
+	TypeNSAPPTR
+	// Security signature
+	TypeSIG
+	// Security key
+	TypeKEY
+	// X.400 mail mapping information
+	TypePX
+	// Geographical position
+	TypeGPOS
+	// IPv6 address
+	TypeAAAA
+	// Location information
+	TypeLOC
+	// Next domain (obsolete)
+	TypeNXT
+	// Endpoint identifier
+	TypeEID
+	// Nimrod locator
+	TypeNIMLOC
+	// Server selection
+	TypeSRV
+	// ATM address
+	TypeATMA
+	// Naming authority pointer
+	TypeNAPTR
+	// Key exchanger
+	TypeKX
+	// Certificate
+	TypeCERT
+	// IPv6 address (obsolete, use AAAA)
+	TypeA6
+	// Delegation name
+	TypeDNAME
+	// Kitchen sink
+	TypeSINK
+	// EDNS option pseudo record
+	TypeOPT
+	// Address prefix list
+	TypeAPL
+	// Delegation signer
+	TypeDS
+	// SSH key fingerprint
+	TypeSSHFP
+	// IPsec key
+	TypeIPSECKEY
+	// DNSSEC signature
+	TypeRRSIG
+	// Next secure record
+	TypeNSEC
+	// DNS key
+	TypeDNSKEY
+	// DHCP identifier
+	TypeDHCID
+	// Hashed next secure record
+	TypeNSEC3
+	// NSEC3 parameters
+	TypeNSEC3PARAM
+	// TLSA certificate association
+	TypeTLSA
+	// S/MIME certificate association
+	TypeSMIMEA
+)
+
+const (
+	// Host identity protocol
+	TypeHIP Type = 55 + iota
+	// Zone status information
+	TypeNINFO
+	// Resource key
+	TypeRKEY
+	// Trust anchor link
+	TypeTALINK
+	// Child DS
+	TypeCDS
+	// Child DNSKEY
+	TypeCDNSKEY
+	// OpenPGP key
+	TypeOPENPGPKEY
+	// Child-to-parent synchronization
+	TypeCSYNC
+	// Message digest for DNS zones
+	TypeZONEMD
+	// General purpose service binding
+	TypeSVCB
+	// Service binding for HTTPS
+	TypeHTTPS
+	// Endpoint discovery for delegation synchronization
+	TypeDSYNC
+	// Hierarchical host identity tag
+	TypeHHIT
+	// UAS broadcast remote identification
+	TypeBRID
+)
 
+const (
+	// Sender policy framework
+	TypeSPF Type = 99 + iota
+	// Reserved by IANA
+	TypeUINFO
+	// Reserved by IANA
+	TypeUID
+	// Reserved by IANA
+	TypeGID
+	// Reserved by IANA
+	TypeUNSPEC
+	// Node identifier
+	TypeNID
+	// 32-bit locator
+	TypeL32
+	// 64-bit locator
+	TypeL64
+	// Locator FQDN
+	TypeLP
+	// EUI-48 address
+	TypeEUI48
+	// EUI-64 address
+	TypeEUI64
+)
+
+const (
+	// NXDOMAIN indicator for compact denial of existence
+	TypeNXNAME Type = 128
+
+	// Transaction key
+	TypeTKEY Type = 249
+	// Transaction signature
+	TypeTSIG Type = 250
+	// Incremental transfer
+	TypeIXFR Type = 251
 	// A request for a transfer of an entire zone of authority
-	TypeAXFR = 252
+	TypeAXFR Type = 252
 	// A request for mailbox-related records (MB, MG or MR)
-	TypeMAILB = 253
+	TypeMAILB Type = 253
 	// A request for mail agent RRs (MD and MF)
-	TypeMAILA = 254
+	TypeMAILA Type = 254
 	// A request for all records
-	TypeAll = 255
+	TypeAll Type = 255
+	// Uniform resource identifier
+	TypeURI Type = 256
+	// Certification authority restriction
+	TypeCAA Type = 257
+	// Application visibility and control
+	TypeAVC Type = 258
+	// Digital object architecture
+	TypeDOA Type = 259
+	// Automatic multicast tunneling relay
+	TypeAMTRELAY Type = 260
+	// Resolver information as key/value pairs
+	TypeRESINFO Type = 261
+	// Public wallet address
+	TypeWALLET Type = 262
+	// BP convergence layer adapter
+	TypeCLA Type = 263
+	// BP node number
+	TypeIPN Type = 264
+
+	// DNSSEC trust authorities
+	TypeTA Type = 32768
+	// DNSSEC lookaside validation (obsolete)
+	TypeDLV Type = 32769
 )
 
+// Class is the CLASS (or QCLASS) field of a resource record or question.
+type Class uint16
+
 const (
 	// The ARPA Internet
-	ClassIN = 1
+	ClassIN Class = 1
 	// The computer science network (CSNET)
-	ClassCS = 2
+	ClassCS Class = 2
+	// The CHAOS network
+	ClassCHAOS Class = 3
+	// Hesiod
+	ClassHESIOD Class = 4
+	// No class, used by dynamic updates (RFC 2136)
+	ClassNone Class = 254
 	// Any class
-	ClassAny = 255
+	ClassAny Class = 255
+)
+
+var typeNames = map[Type]string{
+	TypeA:          "A",
+	TypeNS:         "NS",
+	TypeMD:         "MD",
+	TypeMF:         "MF",
+	TypeCNAME:      "CNAME",
+	TypeSOA:        "SOA",
+	TypeMB:         "MB",
+	TypeMG:         "MG",
+	TypeMR:         "MR",
+	TypeNULL:       "NULL",
+	TypeWKS:        "WKS",
+	TypePTR:        "PTR",
+	TypeHINFO:      "HINFO",
+	TypeMINFO:      "MINFO",
+	TypeMX:         "MX",
+	TypeTXT:        "TXT",
+	TypeRP:         "RP",
+	TypeAFSDB:      "AFSDB",
+	TypeX25:        "X25",
+	TypeISDN:       "ISDN",
+	TypeRT:         "RT",
+	TypeNSAP:       "NSAP",
+	TypeNSAPPTR:    "NSAP-PTR",
+	TypeSIG:        "SIG",
+	TypeKEY:        "KEY",
+	TypePX:         "PX",
+	TypeGPOS:       "GPOS",
+	TypeAAAA:       "AAAA",
+	TypeLOC:        "LOC",
+	TypeNXT:        "NXT",
+	TypeEID:        "EID",
+	TypeNIMLOC:     "NIMLOC",
+	TypeSRV:        "SRV",
+	TypeATMA:       "ATMA",
+	TypeNAPTR:      "NAPTR",
+	TypeKX:         "KX",
+	TypeCERT:       "CERT",
+	TypeA6:         "A6",
+	TypeDNAME:      "DNAME",
+	TypeSINK:       "SINK",
+	TypeOPT:        "OPT",
+	TypeAPL:        "APL",
+	TypeDS:         "DS",
+	TypeSSHFP:      "SSHFP",
+	TypeIPSECKEY:   "IPSECKEY",
+	TypeRRSIG:      "RRSIG",
+	TypeNSEC:       "NSEC",
+	TypeDNSKEY:     "DNSKEY",
+	TypeDHCID:      "DHCID",
+	TypeNSEC3:      "NSEC3",
+	TypeNSEC3PARAM: "NSEC3PARAM",
+	TypeTLSA:       "TLSA",
+	TypeSMIMEA:     "SMIMEA",
+	TypeHIP:        "HIP",
+	TypeNINFO:      "NINFO",
+	TypeRKEY:       "RKEY",
+	TypeTALINK:     "TALINK",
+	TypeCDS:        "CDS",
+	TypeCDNSKEY:    "CDNSKEY",
+	TypeOPENPGPKEY: "OPENPGPKEY",
+	TypeCSYNC:      "CSYNC",
+	TypeZONEMD:     "ZONEMD",
+	TypeSVCB:       "SVCB",
+	TypeHTTPS:      "HTTPS",
+	TypeDSYNC:      "DSYNC",
+	TypeHHIT:       "HHIT",
+	TypeBRID:       "BRID",
+	TypeSPF:        "SPF",
+	TypeUINFO:      "UINFO",
+	TypeUID:        "UID",
+	TypeGID:        "GID",
+	TypeUNSPEC:     "UNSPEC",
+	TypeNID:        "NID",
+	TypeL32:        "L32",
+	TypeL64:        "L64",
+	TypeLP:         "LP",
+	TypeEUI48:      "EUI48",
+	TypeEUI64:      "EUI64",
+	TypeNXNAME:     "NXNAME",
+	TypeTKEY:       "TKEY",
+	TypeTSIG:       "TSIG",
+	TypeIXFR:       "IXFR",
+	TypeAXFR:       "AXFR",
+	TypeMAILB:      "MAILB",
+	TypeMAILA:      "MAILA",
+	TypeAll:        "ANY",
+	TypeURI:        "URI",
+	TypeCAA:        "CAA",
+	TypeAVC:        "AVC",
+	TypeDOA:        "DOA",
+	TypeAMTRELAY:   "AMTRELAY",
+	TypeRESINFO:    "RESINFO",
+	TypeWALLET:     "WALLET",
+	TypeCLA:        "CLA",
+	TypeIPN:        "IPN",
+	TypeTA:         "TA",
+	TypeDLV:        "DLV",
+}
+
+var classNames = map[Class]string{
+	ClassIN:     "IN",
+	ClassCS:     "CS",
+	ClassCHAOS:  "CH",
+	ClassHESIOD: "HS",
+	ClassNone:   "NONE",
+	ClassAny:    "ANY",
+}
+
+var (
+	typeValues  = invertNames(typeNames)
+	classValues = invertNames(classNames)
 )
+
+func init() {
+	// Accepted aliases that are never produced by String.
+	typeValues["*"] = TypeAll
+	classValues["CHAOS"] = ClassCHAOS
+	classValues["HESIOD"] = ClassHESIOD
+	classValues["*"] = ClassAny
+}
+
+func invertNames[T ~uint16](names map[T]string) map[string]T {
+	values := make(map[string]T, len(names))
+	for v, s := range names {
+		values[s] = v
+	}
+	return values
+}
+
+// String returns the mnemonic of t or the RFC 3597 generic form "TYPEnnn"
+// for types without one.
+func (t Type) String() string {
+	if s, ok := typeNames[t]; ok {
+		return s
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// ParseType converts a mnemonic such as "AAAA" or the generic form
+// "TYPE65534" to a Type. The comparison is case-insensitive.
+func ParseType(s string) (Type, error) {
+	v, err := parseMnemonic(s, "TYPE", typeValues)
+	return Type(v), err
+}
+
+// String returns the mnemonic of c or the RFC 3597 generic form "CLASSnnn"
+// for classes without one.
+func (c Class) String() string {
+	if s, ok := classNames[c]; ok {
+		return s
+	}
+	return "CLASS" + strconv.Itoa(int(c))
+}
+
+// ParseClass converts a mnemonic such as "IN", "CH" or "CHAOS" or the
+// generic form "CLASS7" to a Class. The comparison is case-insensitive.
+func ParseClass(s string) (Class, error) {
+	v, err := parseMnemonic(s, "CLASS", classValues)
+	return Class(v), err
+}
+
+// parseMnemonic looks s up in values and falls back to the generic
+// <prefix><decimal> form.
+func parseMnemonic[T ~uint16](s string, prefix string, values map[string]T) (T, error) {
+	s = strings.ToUpper(s)
+	if v, ok := values[s]; ok {
+		return v, nil
+	}
+	if rest, ok := strings.CutPrefix(s, prefix); ok {
+		if n, err := strconv.ParseUint(rest, 10, 16); err == nil {
+			return T(n), nil
+		}
+	}
+	return 0, ErrUnknownMnemonic
+}
@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestTypeString(t *testing.T) {
+	tests := map[Type]string{
+		TypeA:     "A",
+		TypeCNAME: "CNAME",
+		TypeAAAA:  "AAAA",
+		TypeAll:   "ANY",
+		TypeCAA:   "CAA",
+		TypeDLV:   "DLV",
+		65534:     "TYPE65534",
+	}
+
+	for typ, expected := range tests {
+		if typ.String() != expected {
+			t.Fatalf("Expected '%s' but got '%s'", expected, typ)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := map[string]Type{
+		"AAAA":      TypeAAAA,
+		"mx":        TypeMX,
+		"NSAP-PTR":  TypeNSAPPTR,
+		"*":         TypeAll,
+		"TYPE65534": 65534,
+		"type5":     TypeCNAME,
+	}
+
+	for s, expected := range tests {
+		typ, err := ParseType(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != expected {
+			t.Fatalf("ParseType(%q) should be %d but got %d", s, expected, typ)
+		}
+	}
+
+	for _, s := range []string{"", "FOO", "TYPE", "TYPE65536", "TYPE-1"} {
+		if _, err := ParseType(s); err != ErrUnknownMnemonic {
+			t.Fatalf("ParseType(%q) should fail but got %v", s, err)
+		}
+	}
+}
+
+func TestClassString(t *testing.T) {
+	tests := map[Class]string{
+		ClassIN:     "IN",
+		ClassCHAOS:  "CH",
+		ClassHESIOD: "HS",
+		ClassAny:    "ANY",
+		7:           "CLASS7",
+	}
+
+	for class, expected := range tests {
+		if class.String() != expected {
+			t.Fatalf("Expected '%s' but got '%s'", expected, class)
+		}
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	tests := map[string]Class{
+		"IN":     ClassIN,
+		"chaos":  ClassCHAOS,
+		"CH":     ClassCHAOS,
+		"HESIOD": ClassHESIOD,
+		"CLASS7": 7,
+	}
+
+	for s, expected := range tests {
+		class, err := ParseClass(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if class != expected {
+			t.Fatalf("ParseClass(%q) should be %d but got %d", s, expected, class)
+		}
+	}
+
+	if _, err := ParseClass("INTERNET"); err != ErrUnknownMnemonic {
+		t.Fatalf("ParseClass should fail but got %v", err)
+	}
+}
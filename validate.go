@@ -0,0 +1,218 @@
+package dns
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+)
+
+// Randomize0x20 returns name with the case of its ASCII letters chosen at
+// random. Most servers echo the query name unchanged, so the case pattern
+// adds entropy an off-path attacker has to guess in addition to the Id
+// and port (DNS 0x20, draft-vixie-dnsext-dns0x20).
+func Randomize0x20(name DNSName) DNSName {
+	labels := name.labels()
+	for _, label := range labels {
+		for i, c := range label {
+			if 'a' <= c|0x20 && c|0x20 <= 'z' && rand.IntN(2) == 1 {
+				label[i] = c ^ 0x20
+			}
+		}
+	}
+	return nameFromLabels(labels)
+}
+
+// VerifyResponse checks that resp answers q like IsResponseTo does, but
+// compares the query names case-sensitively so that the case randomized
+// by Randomize0x20 is verified.
+func VerifyResponse(q, resp *Message) error {
+	if !IsResponseTo(q, resp) {
+		return ErrUnexpectedResponse
+	}
+	for i, rq := range resp.Question {
+		if !sameCase(rq.Name, q.Question[i].Name) {
+			return ErrCaseMismatch
+		}
+	}
+	return nil
+}
+
+// sameCase reports whether a and b have the same labels, compared
+// case-sensitively.
+func sameCase(a, b DNSName) bool {
+	la, lb := a.labels(), b.labels()
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		if !bytes.Equal(la[i], lb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Unsolicited is a record of a response that does not belong into it.
+type Unsolicited struct {
+	Section Section
+	Record  *ResourceRecord
+	Reason  string
+}
+
+// FindUnsolicited returns the records of resp that a server authoritative
+// for zone has no business sending in response to the question of resp:
+//
+//   - answer records that are not part of the CNAME chain starting at the
+//     query name, or owned by names outside zone (out of bailiwick), and
+//     synthesized CNAMEs that do not match their DNAME;
+//   - authority records outside zone, and NS and SOA records that are
+//     not owned by an ancestor of the last name of the chain;
+//   - additional records outside zone and address records that are not
+//     the target of an NS, MX or SRV record in the response.
+//
+// Caching such records makes a resolver poisonable (RFC 5452 section
+// 6), so they should be removed with DropUnsolicited before caching.
+func FindUnsolicited(resp *Message, zone DNSName) []Unsolicited {
+	var found []Unsolicited
+	checkUnsolicited(resp, zone, func(section Section, rr *ResourceRecord, reason string) {
+		found = append(found, Unsolicited{Section: section, Record: rr, Reason: reason})
+	})
+	return found
+}
+
+// DropUnsolicited removes the records FindUnsolicited reports from resp
+// and returns them.
+func DropUnsolicited(resp *Message, zone DNSName) []Unsolicited {
+	dropped := FindUnsolicited(resp, zone)
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	drop := make(map[*ResourceRecord]bool, len(dropped))
+	for _, u := range dropped {
+		drop[u.Record] = true
+	}
+	keep := func(records []*ResourceRecord) []*ResourceRecord {
+		var kept []*ResourceRecord
+		for _, rr := range records {
+			if !drop[rr] {
+				kept = append(kept, rr)
+			}
+		}
+		return kept
+	}
+	resp.Answer = keep(resp.Answer)
+	resp.Authority = keep(resp.Authority)
+	resp.Additional = keep(resp.Additional)
+	return dropped
+}
+
+func checkUnsolicited(resp *Message, zone DNSName, report func(Section, *ResourceRecord, string)) {
+	if len(resp.Question) != 1 {
+		for _, section := range []struct {
+			s       Section
+			records []*ResourceRecord
+		}{{SectionAnswer, resp.Answer}, {SectionAuthority, resp.Authority}, {SectionAdditional, resp.Additional}} {
+			for _, rr := range section.records {
+				if rr.Type != TypeOPT {
+					report(section.s, rr, "no question")
+				}
+			}
+		}
+		return
+	}
+	question := resp.Question[0]
+
+	chain := cnameChain(resp.Answer, question.Name, question.Type)
+	inChain := func(name DNSName) bool {
+		return slices.ContainsFunc(chain, name.Equal)
+	}
+	final := chain[len(chain)-1]
+
+	targets := make(map[DNSName]bool)
+	addTarget := func(rr *ResourceRecord) {
+		data, err := rr.Rdata()
+		if err != nil {
+			return
+		}
+		switch data := data.(type) {
+		case *NS:
+			targets[data.Host.Canonical()] = true
+		case *MX:
+			targets[data.Exchange.Canonical()] = true
+		case *SRV:
+			targets[data.Target.Canonical()] = true
+		}
+	}
+
+	for _, rr := range resp.Answer {
+		switch {
+		case !rr.Name.IsSubdomainOf(zone):
+			report(SectionAnswer, rr, "out of bailiwick")
+		case rr.Type == TypeDNAME && slices.ContainsFunc(chain, func(name DNSName) bool {
+			return name.IsSubdomainOf(rr.Name) && !name.Equal(rr.Name)
+		}):
+			// The DNAME redirects a name of the chain.
+		case rr.Type == TypeCNAME && question.Type != TypeCNAME && !matchesDNAME(resp.Answer, rr):
+			report(SectionAnswer, rr, "does not match DNAME")
+		case !inChain(rr.Name):
+			report(SectionAnswer, rr, "not in answer chain")
+		case rr.Type != question.Type && rr.Type != TypeCNAME && rr.Type != TypeRRSIG && question.Type != TypeAll:
+			report(SectionAnswer, rr, "unexpected type")
+		default:
+			addTarget(rr)
+		}
+	}
+
+	for _, rr := range resp.Authority {
+		switch {
+		case !rr.Name.IsSubdomainOf(zone):
+			report(SectionAuthority, rr, "out of bailiwick")
+		case (rr.Type == TypeNS || rr.Type == TypeSOA) && !final.IsSubdomainOf(rr.Name):
+			report(SectionAuthority, rr, "not an ancestor of the query name")
+		default:
+			if rr.Type == TypeNS {
+				addTarget(rr)
+			}
+		}
+	}
+
+	for _, rr := range resp.Additional {
+		switch {
+		case rr.Type == TypeOPT || rr.Type == TypeTSIG:
+		case !rr.Name.IsSubdomainOf(zone):
+			report(SectionAdditional, rr, "out of bailiwick")
+		case (rr.Type == TypeA || rr.Type == TypeAAAA) && !targets[rr.Name.Canonical()]:
+			report(SectionAdditional, rr, "unsolicited address")
+		}
+	}
+}
+
+// matchesDNAME reports whether the CNAME record cname agrees with the
+// DNAME record in answers that covers its owner, if there is one.
+func matchesDNAME(answers []*ResourceRecord, cname *ResourceRecord) bool {
+	target, ok := dnameTarget(answers, cname.Name)
+	if !ok {
+		return true
+	}
+	data, err := cname.Rdata()
+	return err == nil && data.(*CNAME).Target.Equal(target)
+}
+
+// cnameChain returns name followed by the targets of the CNAME chain
+// starting at it in answers, including CNAMEs synthesized from DNAME
+// records.
+func cnameChain(answers []*ResourceRecord, name DNSName, qtype Type) []DNSName {
+	chain := []DNSName{name}
+	if qtype == TypeCNAME {
+		return chain
+	}
+	for hops := 0; hops < maxCNAMEChain; hops++ {
+		next := aliasTarget(answers, chain[len(chain)-1])
+		if next == "" || slices.ContainsFunc(chain, next.Equal) {
+			break
+		}
+		chain = append(chain, next)
+	}
+	return chain
+}
@@ -0,0 +1,205 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRandomize0x20(t *testing.T) {
+	name := DNSName("www.some-long-name.noteip.de")
+	changed := false
+	for range 10 {
+		r := Randomize0x20(name)
+		if !r.Equal(name) {
+			t.Fatalf("%s does not equal %s", r, name)
+		}
+		if r != name {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Fatal("The case was never changed.")
+	}
+	if r := Randomize0x20(`1\.2-3.`); r != `1\.2-3` {
+		t.Fatalf("Unexpected randomization of a name without letters: %s", r)
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	q, _ := NewQuery("WwW.NoteIP.de", TypeA, ClassIN)
+	resp := testReply(q, RCodeNoError)
+	if err := VerifyResponse(q, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	lower := *q
+	lower.Question = []*Question{{Name: "www.noteip.de", Type: TypeA, Class: ClassIN}}
+	if err := VerifyResponse(q, testReply(&lower, RCodeNoError)); !errors.Is(err, ErrCaseMismatch) {
+		t.Fatalf("Expected ErrCaseMismatch but got %v", err)
+	}
+
+	other := testReply(q, RCodeNoError)
+	other.Header.Id++
+	if err := VerifyResponse(q, other); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Fatalf("Expected ErrUnexpectedResponse but got %v", err)
+	}
+}
+
+func TestFindUnsolicited(t *testing.T) {
+	q, _ := NewQuery("www.noteip.de", TypeA, ClassIN)
+	resp := testReply(q, RCodeNoError,
+		NewResourceRecord("www.noteip.de", ClassIN, 60, &CNAME{Target: "git.noteip.de"}),
+		testA("git.noteip.de", 192, 0, 2, 1),
+		testA("bank.example", 192, 0, 2, 66),
+		testA("other.noteip.de", 192, 0, 2, 66),
+		NewResourceRecord("git.noteip.de", ClassIN, 60, &MX{Exchange: "mx.noteip.de"}),
+	)
+	resp.Authority = []*ResourceRecord{
+		testNS("noteip.de", "ns.noteip.de"),
+		testNS("example", "ns.noteip.de"),
+		testNS("other.noteip.de", "ns.noteip.de"),
+	}
+	resp.Additional = []*ResourceRecord{
+		testA("ns.noteip.de", 192, 0, 2, 53),
+		testA("mail.noteip.de", 192, 0, 2, 25),
+		testA("ns.example", 192, 0, 2, 66),
+	}
+	resp.SetEDNS0(DefaultEDNSUDPSize, false)
+
+	found := FindUnsolicited(resp, "noteip.de")
+	want := []struct {
+		section Section
+		name    DNSName
+	}{
+		{SectionAnswer, "bank.example"},
+		{SectionAnswer, "other.noteip.de"},
+		{SectionAnswer, "git.noteip.de"},
+		{SectionAuthority, "example"},
+		{SectionAuthority, "other.noteip.de"},
+		{SectionAdditional, "mail.noteip.de"},
+		{SectionAdditional, "ns.example"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("Expected %d unsolicited records but got %v", len(want), found)
+	}
+	for i, w := range want {
+		if found[i].Section != w.section || found[i].Record.Name != w.name {
+			t.Errorf("Record %d is %s in %s (%s), want %s in %s", i, found[i].Record.Name, found[i].Section, found[i].Reason, w.name, w.section)
+		}
+	}
+
+	DropUnsolicited(resp, "noteip.de")
+	if len(resp.Answer) != 2 || len(resp.Authority) != 1 || len(resp.Additional) != 2 {
+		t.Fatalf("Unexpected records after dropping: %v", resp)
+	}
+	if FindUnsolicited(resp, "noteip.de") != nil {
+		t.Fatal("Records remain unsolicited after dropping.")
+	}
+}
+
+func TestIterativeResolverDropsPoison(t *testing.T) {
+	h := newTestHierarchy(t)
+	// A server for example must not be believed about noteip.de.
+	example := h.zones["example"]
+	example.mu.Lock()
+	example.inject = []*ResourceRecord{
+		testAddr("git.noteip.de", "192.0.2.66"),
+		testAddr("ns1.noteip.de", "192.0.2.66"),
+	}
+	example.mu.Unlock()
+	c := NewCache(h.resolver(), 0)
+
+	q, _ := NewQuery("web.example", TypeA, ClassIN)
+	resp, err := c.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+
+	q, _ = NewQuery("git.noteip.de", TypeA, ClassIN)
+	resp, err = c.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range resp.Answer {
+		if addr, _ := recordAddr(rr); addr.String() == "192.0.2.66" {
+			t.Fatalf("Cache was poisoned: %v", resp)
+		}
+	}
+}
+
+func TestIterativeResolver0x20(t *testing.T) {
+	h := newTestHierarchy(t)
+	r := h.resolver()
+	r.Use0x20 = true
+
+	if _, err := r.Resolve(context.Background(), "git.noteip.de", TypeA); err != nil {
+		t.Fatal(err)
+	}
+	randomized := false
+	for _, z := range h.zones {
+		for _, name := range z.queried() {
+			randomized = randomized || !sameCase(name, name.Canonical())
+		}
+	}
+	if !randomized {
+		t.Fatal("No query name was randomized.")
+	}
+
+	// Responses of a server that does not preserve the case are ignored
+	// until the server has failed to echo it several times in a row.
+	h.zones["noteip.de"].lowercase.Store(true)
+	r.Client.Timeout = 100 * time.Millisecond
+	for range maxCaseMismatches {
+		if _, err := r.Resolve(context.Background(), "www2.noteip.de", TypeA); !errors.Is(err, ErrCaseMismatch) {
+			t.Fatalf("Expected ErrCaseMismatch but got %v", err)
+		}
+	}
+	resp, err := r.Resolve(context.Background(), "www2.noteip.de", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ResponseCode() != RCodeNameError {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+	names := h.zones["noteip.de"].queried()
+	if last := names[len(names)-1]; !sameCase(last, "www2.noteip.de") {
+		t.Fatalf("Expected a query without 0x20 but got %v", last)
+	}
+}
+
+func TestClientIgnoresCaseMismatch(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, maxMessageLen)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		q, _ := ReadMessage(buf[:n])
+		// A forged response with the wrong case arrives first.
+		forged := testReply(q, RCodeNoError, testAddr(q.Question[0].Name, "192.0.2.66"))
+		forged.Question = []*Question{{Name: q.Question[0].Name.Canonical(), Type: TypeA, Class: ClassIN}}
+		pc.WriteTo(forged.Encode(), addr)
+		pc.WriteTo(testReply(q, RCodeNoError, testAddr(q.Question[0].Name, "192.0.2.1")).Encode(), addr)
+	}()
+
+	q, _ := NewQuery("wWw.NoTeIp.De", TypeA, ClassIN)
+	c := &Client{Timeout: time.Second}
+	resp, err := c.exchange(context.Background(), q, pc.LocalAddr().String(), VerifyResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr, _ := recordAddr(resp.Answer[0]); addr.String() != "192.0.2.1" {
+		t.Fatalf("Expected the genuine response but got %v", resp)
+	}
+}
@@ -0,0 +1,304 @@
+package dns
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Zone holds the records of a zone and answers queries for it like an
+// authoritative server does (RFC 1034 section 4.3.2):
+//
+//   - names below a zone cut are answered with a referral to the NS
+//     records of the cut, with the addresses of the name servers as glue;
+//   - CNAME chains are followed as long as they stay in the zone, and
+//     names below a DNAME record get a synthesized CNAME (RFC 6672);
+//   - names that do not exist are answered from a matching wildcard
+//     (RFC 4592), otherwise with NXDOMAIN;
+//   - existing names without records of the queried type, including empty
+//     non-terminals, get an empty answer (NODATA).
+//
+// Negative responses carry the SOA record of the zone in the authority
+// section with the TTL limited to its minimum field (RFC 2308). The
+// addresses of the targets of NS, MX and SRV records are added to the
+// additional section.
+//
+// Queries are answered from a snapshot of the records, so that readers
+// need no locks and always see a consistent version of the zone while
+// records are added.
+//
+// Changes made with Apply are kept in a journal to answer IXFR queries
+// (RFC 1995) with the differences since the version of the client.
+type Zone struct {
+	Origin DNSName
+
+	// JournalSize is the number of deltas kept for IXFR. If zero,
+	// DefaultJournalSize is used.
+	JournalSize int
+
+	// mu serializes the writers and guards journal.
+	mu      sync.Mutex
+	tree    atomic.Pointer[ZoneTree]
+	journal []*ZoneDelta
+}
+
+// NewZone returns a zone for origin with records.
+func NewZone(origin DNSName, records ...*ResourceRecord) (*Zone, error) {
+	z := &Zone{Origin: origin}
+	if err := z.Add(records...); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Snapshot returns the current version of the records.
+func (z *Zone) Snapshot() *ZoneTree {
+	if t := z.tree.Load(); t != nil {
+		return t
+	}
+	return NewZoneTree(z.Origin)
+}
+
+// Add adds records to the zone. ErrNotInZone is returned if one of them
+// is not owned by the origin or a name below it; no record is added then.
+// Add clears the journal, as the change has no version of its own.
+func (z *Zone) Add(records ...*ResourceRecord) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	t, err := z.Snapshot().Insert(records...)
+	if err != nil {
+		return err
+	}
+	z.journal = nil
+	z.tree.Store(t)
+	return nil
+}
+
+// SOA returns the SOA record at the origin or nil if the zone has none.
+func (z *Zone) SOA() *ResourceRecord {
+	return z.Snapshot().SOA()
+}
+
+// Records returns the records of the zone sorted by owner name in
+// canonical order (RFC 4034 section 6.1).
+func (z *Zone) Records() []*ResourceRecord {
+	return z.Snapshot().Records()
+}
+
+// Exchange answers q from the zone. Queries for names outside the zone
+// are refused.
+func (z *Zone) Exchange(ctx context.Context, q *Message) (*Message, error) {
+	if len(q.Question) != 1 {
+		return errorResponse(q, RCodeFormatError), nil
+	}
+	question := q.Question[0]
+	if !question.Name.IsSubdomainOf(z.Origin) {
+		return errorResponse(q, RCodeRefused), nil
+	}
+
+	resp := errorResponse(q, RCodeNoError)
+	resp.Header.SetRecursionAvailable(false)
+	resp.Header.SetTruncated(false)
+	resp.Header.SetAuthoritativeAnswer(true)
+	t := z.Snapshot()
+	t.answer(resp, question.Name, question.Type)
+	t.addAdditional(resp)
+	return resp, nil
+}
+
+// SOA returns the SOA record at the origin or nil if the tree has none.
+func (t *ZoneTree) SOA() *ResourceRecord {
+	if soa := rrsetOf(t.root.records, TypeSOA); len(soa) > 0 {
+		return soa[0]
+	}
+	return nil
+}
+
+// answer fills the answer and authority sections of resp for name and
+// qtype, following CNAMEs and DNAMEs within the zone.
+func (t *ZoneTree) answer(resp *Message, name DNSName, qtype Type) {
+	visited := []DNSName{name}
+	for {
+		var target DNSName
+		switch cut := t.zoneCut(name, qtype); {
+		case len(cut) > 0 && cut[0].Type == TypeNS:
+			resp.Authority = append(resp.Authority, cut...)
+			if len(resp.Answer) == 0 {
+				resp.Header.SetAuthoritativeAnswer(false)
+			}
+			return
+
+		case len(cut) > 0:
+			// The DNAME redirects name (RFC 6672 section 3.2).
+			cname, err := SynthesizeCNAME(cut[0], name)
+			resp.Answer = append(resp.Answer, cut[0])
+			if err != nil {
+				resp.Header.SetResponseCode(RCodeYXDomain)
+				return
+			}
+			resp.Answer = append(resp.Answer, cname)
+			data, _ := cname.Rdata()
+			target = data.(*CNAME).Target
+
+		default:
+			var ok bool
+			if target, ok = t.answerName(resp, name, qtype); !ok {
+				return
+			}
+		}
+
+		name = target
+		if !name.IsSubdomainOf(t.origin) || slices.ContainsFunc(visited, name.Equal) || len(visited) > maxCNAMEChain {
+			return
+		}
+		visited = append(visited, name)
+	}
+}
+
+// answerName answers name, which is neither delegated nor redirected by a
+// DNAME, from its records or a wildcard. It returns the target of the
+// CNAME record of name if the chain continues.
+func (t *ZoneTree) answerName(resp *Message, name DNSName, qtype Type) (DNSName, bool) {
+	records, ok := t.Lookup(name)
+	synthesized := false
+	if !ok {
+		if records, ok = t.wildcard(name); !ok {
+			resp.Header.SetResponseCode(RCodeNameError)
+			t.addSOA(resp)
+			return "", false
+		}
+		synthesized = true
+	}
+
+	owned := func(rrs []*ResourceRecord) []*ResourceRecord {
+		if !synthesized {
+			return rrs
+		}
+		out := make([]*ResourceRecord, len(rrs))
+		for i, rr := range rrs {
+			cp := *rr
+			cp.Name = name
+			out[i] = &cp
+		}
+		return out
+	}
+
+	if answers := rrsetOf(records, qtype); len(answers) > 0 {
+		resp.Answer = append(resp.Answer, owned(answers)...)
+		return "", false
+	}
+	cname := rrsetOf(records, TypeCNAME)
+	if len(cname) == 0 || qtype == TypeCNAME {
+		t.addSOA(resp)
+		return "", false
+	}
+
+	resp.Answer = append(resp.Answer, owned(cname[:1])...)
+	data, err := cname[0].Rdata()
+	if err != nil {
+		return "", false
+	}
+	return data.(*CNAME).Target, true
+}
+
+// zoneCut returns the NS records of the closest delegation point of name
+// below the origin or the DNAME record of an ancestor of name, whichever
+// is closer to the origin. DS queries for a delegation point are answered
+// by the parent side, so the cut at name itself is ignored for them.
+func (t *ZoneTree) zoneCut(name DNSName, qtype Type) []*ResourceRecord {
+	nodes, depth := t.path(name)
+	for i, n := range nodes {
+		if i > 0 && !(i == depth && qtype == TypeDS) {
+			if ns := rrsetOf(n.records, TypeNS); len(ns) > 0 {
+				return ns
+			}
+		}
+		if i < depth {
+			if dname := rrsetOf(n.records, TypeDNAME); len(dname) > 0 {
+				return dname[:1]
+			}
+		}
+	}
+	return nil
+}
+
+// wildcard returns the records of the wildcard that matches name, which
+// does not exist: the wildcard at the closest encloser of name
+// (RFC 4592 section 3.3.1).
+func (t *ZoneTree) wildcard(name DNSName) ([]*ResourceRecord, bool) {
+	nodes, _ := t.path(name)
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	encloser := nodes[len(nodes)-1]
+	i, ok := encloser.child([]byte("*"))
+	if !ok {
+		return nil, false
+	}
+	return encloser.children[i].records, true
+}
+
+// addSOA adds the SOA record for negative responses to resp.
+func (t *ZoneTree) addSOA(resp *Message) {
+	soa := t.SOA()
+	if soa == nil {
+		return
+	}
+	cp := *soa
+	if data, err := soa.Rdata(); err == nil {
+		cp.TTL = min(cp.TTL, data.(*SOA).Minimum)
+	}
+	resp.Authority = append(resp.Authority, &cp)
+}
+
+// addAdditional adds the address records of the zone for the targets of
+// the NS, MX and SRV records in resp that are not in the answer already.
+func (t *ZoneTree) addAdditional(resp *Message) {
+	seen := make(map[DNSName]bool)
+	for _, rr := range resp.Answer {
+		if rr.Type == TypeA || rr.Type == TypeAAAA {
+			seen[rr.Name.Canonical()] = true
+		}
+	}
+
+	for _, rr := range append(slices.Clip(resp.Answer), resp.Authority...) {
+		data, err := rr.Rdata()
+		if err != nil {
+			continue
+		}
+		var target DNSName
+		switch data := data.(type) {
+		case *NS:
+			target = data.Host
+		case *MX:
+			target = data.Exchange
+		case *SRV:
+			target = data.Target
+		default:
+			continue
+		}
+
+		target = target.Canonical()
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		records, _ := t.Lookup(target)
+		resp.Additional = append(resp.Additional, rrsetOf(records, TypeA)...)
+		resp.Additional = append(resp.Additional, rrsetOf(records, TypeAAAA)...)
+	}
+}
+
+// rrsetOf returns the records of type t in records. TypeAll matches all
+// records.
+func rrsetOf(records []*ResourceRecord, t Type) []*ResourceRecord {
+	var rrset []*ResourceRecord
+	for _, rr := range records {
+		if rr.Type == t || t == TypeAll {
+			rrset = append(rrset, rr)
+		}
+	}
+	return rrset
+}
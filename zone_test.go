@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func newTestZone(t *testing.T) *Zone {
+	t.Helper()
+
+	z, err := NewZone("example.com",
+		testSOAFor("example.com"),
+		testNS("example.com", "ns1.example.com"),
+		testNS("example.com", "ns.example.net"),
+		testAddr("ns1.example.com", "192.0.2.1"),
+		testAddr("ns1.example.com", "2001:db8::1"),
+		testAddr("www.example.com", "192.0.2.10"),
+		NewResourceRecord("alias.example.com", ClassIN, 300, &CNAME{Target: "www.example.com"}),
+		NewResourceRecord("chain.example.com", ClassIN, 300, &CNAME{Target: "alias.example.com"}),
+		NewResourceRecord("out.example.com", ClassIN, 300, &CNAME{Target: "www.example.net"}),
+		NewResourceRecord("dangling.example.com", ClassIN, 300, &CNAME{Target: "missing.example.com"}),
+		NewResourceRecord("loop1.example.com", ClassIN, 300, &CNAME{Target: "loop2.example.com"}),
+		NewResourceRecord("loop2.example.com", ClassIN, 300, &CNAME{Target: "loop1.example.com"}),
+		NewResourceRecord("example.com", ClassIN, 300, &MX{Preference: 10, Exchange: "mail.example.com"}),
+		testAddr("mail.example.com", "192.0.2.25"),
+		NewResourceRecord("_sip._tcp.example.com", ClassIN, 300, &SRV{Priority: 1, Weight: 1, Port: 5060, Target: "sip.example.com"}),
+		testAddr("sip.example.com", "2001:db8::5060"),
+		NewResourceRecord("*.wild.example.com", ClassIN, 300, &TXT{Strings: []string{"wildcard"}}),
+		testAddr("*.wild.example.com", "192.0.2.42"),
+		testAddr("host.wild.example.com", "192.0.2.43"),
+		NewResourceRecord("*.cname.example.com", ClassIN, 300, &CNAME{Target: "www.example.com"}),
+		testAddr("a.b.c.example.com", "192.0.2.50"),
+		testNS("sub.example.com", "ns.sub.example.com"),
+		testNS("sub.example.com", "ns.example.net"),
+		testAddr("ns.sub.example.com", "192.0.2.53"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z
+}
+
+func zoneQuery(t *testing.T, z *Zone, name DNSName, qtype Type) *Message {
+	t.Helper()
+
+	q, _ := NewQuery(string(name), qtype, ClassIN)
+	resp, err := z.Exchange(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func recordNames(records []*ResourceRecord) []string {
+	var names []string
+	for _, rr := range records {
+		names = append(names, string(rr.Name.Canonical())+"/"+rr.Type.String())
+	}
+	return names
+}
+
+func TestZoneExchange(t *testing.T) {
+	z := newTestZone(t)
+
+	tests := []struct {
+		name       DNSName
+		qtype      Type
+		rcode      Rcode
+		aa         bool
+		answer     []string
+		authority  []string
+		additional []string
+	}{
+		{"www.example.com", TypeA, RCodeNoError, true, []string{"www.example.com/A"}, nil, nil},
+		{"WWW.Example.COM", TypeA, RCodeNoError, true, []string{"www.example.com/A"}, nil, nil},
+		{"www.example.com", TypeAAAA, RCodeNoError, true, nil, []string{"example.com/SOA"}, nil},
+		{"nothing.example.com", TypeA, RCodeNameError, true, nil, []string{"example.com/SOA"}, nil},
+		// Empty non-terminals exist.
+		{"b.c.example.com", TypeA, RCodeNoError, true, nil, []string{"example.com/SOA"}, nil},
+		{"chain.example.com", TypeA, RCodeNoError, true,
+			[]string{"chain.example.com/CNAME", "alias.example.com/CNAME", "www.example.com/A"}, nil, nil},
+		{"chain.example.com", TypeCNAME, RCodeNoError, true, []string{"chain.example.com/CNAME"}, nil, nil},
+		{"out.example.com", TypeA, RCodeNoError, true, []string{"out.example.com/CNAME"}, nil, nil},
+		{"dangling.example.com", TypeA, RCodeNameError, true,
+			[]string{"dangling.example.com/CNAME"}, []string{"example.com/SOA"}, nil},
+		{"loop1.example.com", TypeA, RCodeNoError, true,
+			[]string{"loop1.example.com/CNAME", "loop2.example.com/CNAME"}, nil, nil},
+		{"example.com", TypeNS, RCodeNoError, true,
+			[]string{"example.com/NS", "example.com/NS"}, nil, []string{"ns1.example.com/A", "ns1.example.com/AAAA"}},
+		{"example.com", TypeMX, RCodeNoError, true, []string{"example.com/MX"}, nil, []string{"mail.example.com/A"}},
+		{"_sip._tcp.example.com", TypeSRV, RCodeNoError, true,
+			[]string{"_sip._tcp.example.com/SRV"}, nil, []string{"sip.example.com/AAAA"}},
+		{"any.wild.example.com", TypeTXT, RCodeNoError, true, []string{"any.wild.example.com/TXT"}, nil, nil},
+		{"any.wild.example.com", TypeA, RCodeNoError, true, []string{"any.wild.example.com/A"}, nil, nil},
+		{"any.wild.example.com", TypeMX, RCodeNoError, true, nil, []string{"example.com/SOA"}, nil},
+		// Wildcards only match names whose closest encloser is their
+		// owner (RFC 4592 section 2.2.1).
+		{"x.host.wild.example.com", TypeA, RCodeNameError, true, nil, []string{"example.com/SOA"}, nil},
+		{"host.wild.example.com", TypeTXT, RCodeNoError, true, nil, []string{"example.com/SOA"}, nil},
+		{"foo.cname.example.com", TypeA, RCodeNoError, true,
+			[]string{"foo.cname.example.com/CNAME", "www.example.com/A"}, nil, nil},
+		{"sub.example.com", TypeA, RCodeNoError, false,
+			nil, []string{"sub.example.com/NS", "sub.example.com/NS"}, []string{"ns.sub.example.com/A"}},
+		{"deep.www.sub.example.com", TypeA, RCodeNoError, false,
+			nil, []string{"sub.example.com/NS", "sub.example.com/NS"}, []string{"ns.sub.example.com/A"}},
+		{"ns.sub.example.com", TypeA, RCodeNoError, false,
+			nil, []string{"sub.example.com/NS", "sub.example.com/NS"}, []string{"ns.sub.example.com/A"}},
+		{"sub.example.com", TypeDS, RCodeNoError, true, nil, []string{"example.com/SOA"}, nil},
+		{"www.example.org", TypeA, RCodeRefused, false, nil, nil, nil},
+	}
+
+	for _, test := range tests {
+		resp := zoneQuery(t, z, test.name, test.qtype)
+		if rcode := resp.Header.ResponseCode(); rcode != test.rcode {
+			t.Errorf("%s %s: rcode %v, expected %v", test.name, test.qtype, rcode, test.rcode)
+		}
+		if aa := resp.Header.IsAuthoritativeAnswer(); aa != test.aa {
+			t.Errorf("%s %s: AA %v, expected %v", test.name, test.qtype, aa, test.aa)
+		}
+		for _, section := range []struct {
+			got, want []string
+		}{
+			{recordNames(resp.Answer), test.answer},
+			{recordNames(resp.Authority), test.authority},
+			{recordNames(resp.Additional), test.additional},
+		} {
+			if !slices.Equal(section.got, section.want) {
+				t.Errorf("%s %s: got %v, expected %v", test.name, test.qtype, section.got, section.want)
+			}
+		}
+	}
+}
+
+func TestZoneNegativeTTL(t *testing.T) {
+	z := newTestZone(t)
+	resp := zoneQuery(t, z, "nothing.example.com", TypeA)
+	if len(resp.Authority) != 1 || resp.Authority[0].TTL != 60 {
+		t.Fatalf("Expected the SOA with the minimum TTL, got %v", resp.Authority)
+	}
+	if z.SOA().TTL != 300 {
+		t.Fatal("The SOA of the zone must not be modified.")
+	}
+}
+
+func TestZoneAdd(t *testing.T) {
+	z := newTestZone(t)
+	n := len(z.Records())
+	if err := z.Add(testAddr("www.example.com", "192.0.2.11"), testAddr("www.example.net", "192.0.2.1")); err != ErrNotInZone {
+		t.Fatalf("Expected ErrNotInZone, got %v", err)
+	}
+	if len(z.Records()) != n {
+		t.Fatal("No record must be added if one is out of zone.")
+	}
+
+	records := z.Records()
+	if !records[0].Name.Equal("example.com") {
+		t.Fatalf("Records should start with the origin, got %v", records[0])
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i-1].Name.Compare(records[i].Name) > 0 {
+			t.Fatalf("Records not in canonical order: %v before %v", records[i-1].Name, records[i].Name)
+		}
+	}
+}
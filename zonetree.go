@@ -0,0 +1,330 @@
+package dns
+
+import (
+	"bytes"
+	"iter"
+	"slices"
+)
+
+// zoneNode is a name of a zone tree. Nodes without records are empty
+// non-terminals and always have children.
+type zoneNode struct {
+	// label is the lower-cased leftmost label of name.
+	label []byte
+
+	// name is the canonical name of the node.
+	name     DNSName
+	records  []*ResourceRecord
+	children []*zoneNode // sorted by label
+}
+
+func compareLabel(n *zoneNode, label []byte) int {
+	return bytes.Compare(n.label, label)
+}
+
+// child returns the index of the child with label or where it would be
+// inserted.
+func (n *zoneNode) child(label []byte) (int, bool) {
+	return slices.BinarySearchFunc(n.children, label, compareLabel)
+}
+
+// last returns the last node of the subtree of n in canonical order that
+// has records.
+func (n *zoneNode) last() *zoneNode {
+	for i := len(n.children) - 1; i >= 0; i-- {
+		if l := n.children[i].last(); l != nil {
+			return l
+		}
+	}
+	if len(n.records) > 0 {
+		return n
+	}
+	return nil
+}
+
+// ZoneTree is an immutable index of the records of a zone. It stores the
+// names in a tree of labels, so that the children of a name are sorted in
+// canonical order (RFC 4034 section 6.1) and a depth-first walk visits the
+// names in canonical order.
+//
+// Insert and Delete return a new tree that shares the unchanged parts with
+// the old one, which stays valid. A ZoneTree can thus be read by any
+// number of goroutines without locks while a writer prepares the next
+// version.
+type ZoneTree struct {
+	origin DNSName
+	root   *zoneNode
+	size   int
+}
+
+// NewZoneTree returns an empty tree for the zone at origin.
+func NewZoneTree(origin DNSName) *ZoneTree {
+	origin = origin.Canonical()
+	return &ZoneTree{origin: origin, root: &zoneNode{name: origin}}
+}
+
+// Origin returns the canonical name of the zone apex.
+func (t *ZoneTree) Origin() DNSName {
+	return t.origin
+}
+
+// Len returns the number of records in the tree.
+func (t *ZoneTree) Len() int {
+	return t.size
+}
+
+// relative returns the lower-cased labels of name below the origin,
+// starting next to the origin. ok is false if name is not in the zone.
+func (t *ZoneTree) relative(name DNSName) (rel [][]byte, ok bool) {
+	if !name.IsSubdomainOf(t.origin) {
+		return nil, false
+	}
+	labels := name.labels()
+	labels = labels[:len(labels)-t.origin.CountLabels()]
+	rel = make([][]byte, len(labels))
+	for i, label := range labels {
+		rel[len(labels)-1-i] = lowerLabel(label)
+	}
+	return rel, true
+}
+
+// path returns the nodes from the root towards name as far as they exist
+// and the number of labels of name below the origin. name exists if
+// len(nodes) == depth+1.
+func (t *ZoneTree) path(name DNSName) (nodes []*zoneNode, depth int) {
+	rel, ok := t.relative(name)
+	if !ok {
+		return nil, -1
+	}
+	n := t.root
+	nodes = append(nodes, n)
+	for _, label := range rel {
+		i, found := n.child(label)
+		if !found {
+			break
+		}
+		n = n.children[i]
+		nodes = append(nodes, n)
+	}
+	return nodes, len(rel)
+}
+
+// node returns the node of name or nil if name does not exist.
+func (t *ZoneTree) node(name DNSName) *zoneNode {
+	nodes, depth := t.path(name)
+	if depth < 0 || len(nodes) != depth+1 {
+		return nil
+	}
+	return nodes[depth]
+}
+
+// Lookup returns the records of name. ok reports whether name exists,
+// which includes empty non-terminals.
+func (t *ZoneTree) Lookup(name DNSName) (records []*ResourceRecord, ok bool) {
+	n := t.node(name)
+	if n == nil {
+		return nil, false
+	}
+	return n.records, true
+}
+
+// ClosestEncloser returns the longest existing ancestor of name or name
+// itself if it exists (RFC 4592 section 3.3.1). ok is false if name is
+// not in the zone.
+func (t *ZoneTree) ClosestEncloser(name DNSName) (encloser DNSName, ok bool) {
+	nodes, _ := t.path(name)
+	if len(nodes) == 0 {
+		return "", false
+	}
+	return nodes[len(nodes)-1].name, true
+}
+
+// Predecessor returns the last name with records that sorts before name
+// in canonical order, wrapping around to the last name of the zone for
+// names that nothing precedes. This is the owner of the NSEC record that
+// covers name (RFC 4034 section 4.1.1). ok is false if name is not in the
+// zone or the zone is empty.
+func (t *ZoneTree) Predecessor(name DNSName) (pred DNSName, ok bool) {
+	rel, ok := t.relative(name)
+	if !ok {
+		return "", false
+	}
+
+	var found *zoneNode
+	n := t.root
+	for _, label := range rel {
+		// Ancestors sort before name, then the subtrees of the
+		// siblings with smaller labels.
+		if len(n.records) > 0 {
+			found = n
+		}
+		i, exists := n.child(label)
+		for j := i - 1; j >= 0; j-- {
+			if l := n.children[j].last(); l != nil {
+				found = l
+				break
+			}
+		}
+		if !exists {
+			break
+		}
+		n = n.children[i]
+	}
+
+	if found == nil {
+		if found = t.root.last(); found == nil {
+			return "", false
+		}
+	}
+	return found.name, true
+}
+
+// All returns the names with records and their records in canonical
+// order.
+func (t *ZoneTree) All() iter.Seq2[DNSName, []*ResourceRecord] {
+	return func(yield func(DNSName, []*ResourceRecord) bool) {
+		t.root.walk(yield)
+	}
+}
+
+func (n *zoneNode) walk(yield func(DNSName, []*ResourceRecord) bool) bool {
+	if len(n.records) > 0 && !yield(n.name, n.records) {
+		return false
+	}
+	for _, c := range n.children {
+		if !c.walk(yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Records returns all records of the tree in canonical order of their
+// owner names.
+func (t *ZoneTree) Records() []*ResourceRecord {
+	records := make([]*ResourceRecord, 0, t.size)
+	for _, rrs := range t.All() {
+		records = append(records, rrs...)
+	}
+	return records
+}
+
+// Insert returns a tree with records added. A record that equals an
+// existing one except for the TTL replaces it. ErrNotInZone is returned
+// if a record is not owned by the origin or a name below it.
+func (t *ZoneTree) Insert(records ...*ResourceRecord) (*ZoneTree, error) {
+	b := t.builder()
+	for _, rr := range records {
+		rel, ok := t.relative(rr.Name)
+		if !ok {
+			return nil, ErrNotInZone
+		}
+		b.tree.root = b.insert(b.tree.root, rel, rr)
+	}
+	return b.tree, nil
+}
+
+// Delete returns a tree without the records that equal one of records
+// except for the TTL. Names left without records and children are
+// removed.
+func (t *ZoneTree) Delete(records ...*ResourceRecord) *ZoneTree {
+	b := t.builder()
+	for _, rr := range records {
+		if rel, ok := t.relative(rr.Name); ok {
+			b.tree.root = b.delete(b.tree.root, rel, rr)
+		}
+	}
+	return b.tree
+}
+
+// treeBuilder copies the nodes of a tree on the first write. Nodes it
+// copied or created already belong to the new tree and are changed in
+// place.
+type treeBuilder struct {
+	tree  *ZoneTree
+	owned map[*zoneNode]bool
+}
+
+func (t *ZoneTree) builder() *treeBuilder {
+	return &treeBuilder{
+		tree:  &ZoneTree{origin: t.origin, root: t.root, size: t.size},
+		owned: make(map[*zoneNode]bool),
+	}
+}
+
+func (b *treeBuilder) own(n *zoneNode) *zoneNode {
+	if b.owned[n] {
+		return n
+	}
+	cp := &zoneNode{
+		label:    n.label,
+		name:     n.name,
+		records:  slices.Clone(n.records),
+		children: slices.Clone(n.children),
+	}
+	b.owned[cp] = true
+	return cp
+}
+
+func (b *treeBuilder) insert(n *zoneNode, rel [][]byte, rr *ResourceRecord) *zoneNode {
+	n = b.own(n)
+	if len(rel) == 0 {
+		if i := slices.IndexFunc(n.records, func(old *ResourceRecord) bool { return sameRecord(old, rr) }); i >= 0 {
+			n.records[i] = rr
+		} else {
+			n.records = append(n.records, rr)
+			b.tree.size++
+		}
+		return n
+	}
+
+	i, found := n.child(rel[0])
+	if !found {
+		c := &zoneNode{label: rel[0], name: nameFromLabels(append([][]byte{rel[0]}, n.name.labels()...))}
+		b.owned[c] = true
+		n.children = slices.Insert(n.children, i, c)
+	}
+	n.children[i] = b.insert(n.children[i], rel[1:], rr)
+	return n
+}
+
+// delete removes rr below n and returns the new node, which is nil if it
+// became empty.
+func (b *treeBuilder) delete(n *zoneNode, rel [][]byte, rr *ResourceRecord) *zoneNode {
+	if len(rel) == 0 {
+		i := slices.IndexFunc(n.records, func(old *ResourceRecord) bool { return sameRecord(old, rr) })
+		if i < 0 {
+			return n
+		}
+		n = b.own(n)
+		n.records = slices.Delete(n.records, i, i+1)
+		b.tree.size--
+	} else {
+		i, found := n.child(rel[0])
+		if !found {
+			return n
+		}
+		c := b.delete(n.children[i], rel[1:], rr)
+		if c == n.children[i] {
+			return n
+		}
+		n = b.own(n)
+		if c == nil {
+			n.children = slices.Delete(n.children, i, i+1)
+		} else {
+			n.children[i] = c
+		}
+	}
+
+	// The root has no label and is kept.
+	if len(n.records) == 0 && len(n.children) == 0 && n.label != nil {
+		return nil
+	}
+	return n
+}
+
+// sameRecord reports whether a and b are the same record apart from the
+// TTL.
+func sameRecord(a, b *ResourceRecord) bool {
+	return a.Type == b.Type && a.Class == b.Class && a.Name.Equal(b.Name) && bytes.Equal(a.Data, b.Data)
+}
@@ -0,0 +1,200 @@
+package dns
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// canonicalNames are the names of RFC 4034 section 6.1 in canonical order.
+var canonicalNames = []DNSName{
+	"example",
+	"a.example",
+	"yljkjljk.a.example",
+	"Z.a.example",
+	"zABC.a.EXAMPLE",
+	"z.example",
+	`\001.z.example`,
+	"*.z.example",
+	`\200.z.example`,
+}
+
+func newCanonicalTree(t *testing.T) *ZoneTree {
+	t.Helper()
+
+	tree := NewZoneTree("example")
+	for i := len(canonicalNames) - 1; i >= 0; i-- {
+		var err error
+		tree, err = tree.Insert(testA(canonicalNames[i], 192, 0, 2, byte(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tree
+}
+
+func TestZoneTreeOrder(t *testing.T) {
+	tree := newCanonicalTree(t)
+	if tree.Len() != len(canonicalNames) {
+		t.Fatalf("Expected %d records, got %d", len(canonicalNames), tree.Len())
+	}
+
+	var names []DNSName
+	for name := range tree.All() {
+		names = append(names, name)
+	}
+	if !slices.EqualFunc(names, canonicalNames, DNSName.Equal) {
+		t.Fatalf("Names not in canonical order: %v", names)
+	}
+	for i, rr := range tree.Records() {
+		if rr.Data[3] != byte(i) {
+			t.Fatalf("Record %d out of order: %v", i, rr)
+		}
+	}
+}
+
+func TestZoneTreeLookup(t *testing.T) {
+	tree := newCanonicalTree(t)
+	tree, _ = tree.Insert(testA("deep.ent.example", 192, 0, 2, 99))
+
+	if records, ok := tree.Lookup("ZABC.a.example"); !ok || len(records) != 1 {
+		t.Fatalf("Lookup failed: %v %v", records, ok)
+	}
+	if records, ok := tree.Lookup("ent.example"); !ok || len(records) != 0 {
+		t.Fatal("Empty non-terminals should exist without records.")
+	}
+	if _, ok := tree.Lookup("missing.example"); ok {
+		t.Fatal("missing.example should not exist.")
+	}
+	if _, ok := tree.Lookup("example.com"); ok {
+		t.Fatal("Names outside the zone should not exist.")
+	}
+
+	for name, expected := range map[DNSName]DNSName{
+		"a.example":               "a.example",
+		"x.y.yljkjljk.a.example":  "yljkjljk.a.example",
+		"other.ent.example":       "ent.example",
+		"b.example":               "example",
+		"example":                 "example",
+		`foo.\001.z.example`:      `\001.z.example`,
+		"host.missing.z.example":  "z.example",
+		"very.deep.ent.example.":  "deep.ent.example",
+		"a.EXAMPLE":               "a.example",
+		"ZABC.a.example":          "zabc.a.example",
+		"sub.zabc.a.example":      "zabc.a.example",
+		"yljkjljk.ent.example":    "ent.example",
+		"yljkjljk.b.ent.example.": "ent.example",
+	} {
+		encloser, ok := tree.ClosestEncloser(name)
+		if !ok || !encloser.Equal(expected) {
+			t.Errorf("Closest encloser of %s is %s, expected %s", name, encloser, expected)
+		}
+	}
+	if _, ok := tree.ClosestEncloser("example.com"); ok {
+		t.Fatal("Names outside the zone have no closest encloser.")
+	}
+}
+
+func TestZoneTreePredecessor(t *testing.T) {
+	tree := newCanonicalTree(t)
+	tree, _ = tree.Insert(testA("deep.ent.example", 192, 0, 2, 99))
+
+	for name, expected := range map[DNSName]DNSName{
+		// Existing names are preceded by the name before them.
+		"a.example":          "example",
+		"z.example":          "deep.ent.example",
+		`\200.z.example`:     "*.z.example",
+		"yljkjljk.a.example": "a.example",
+		// The origin wraps around to the last name.
+		"example": `\200.z.example`,
+		// Names that do not exist.
+		"b.example":            "zabc.a.example",
+		"0.example":            "example",
+		"aa.example":           "zabc.a.example",
+		"b.a.example":          "a.example",
+		"zz.example":           `\200.z.example`,
+		"x.yljkjljk.a.example": "yljkjljk.a.example",
+		"zzz.z.example":        "*.z.example",
+		// Empty non-terminals have no NSEC records.
+		"ent.example":   "zabc.a.example",
+		"a.ent.example": "zabc.a.example",
+	} {
+		pred, ok := tree.Predecessor(name)
+		if !ok || !pred.Equal(expected) {
+			t.Errorf("Predecessor of %s is %s, expected %s", name, pred, expected)
+		}
+	}
+
+	if _, ok := NewZoneTree("example").Predecessor("a.example"); ok {
+		t.Fatal("Empty trees have no predecessors.")
+	}
+}
+
+func TestZoneTreeCopyOnWrite(t *testing.T) {
+	old := newCanonicalTree(t)
+	extra := testA("b.b.example", 192, 0, 2, 100)
+	tree, err := old.Insert(extra, testA("a.example", 192, 0, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Len() != old.Len()+1 {
+		t.Fatalf("Expected one new record, got %d", tree.Len()-old.Len())
+	}
+	if _, ok := old.Lookup("b.b.example"); ok {
+		t.Fatal("Insert must not change the old tree.")
+	}
+
+	// Records that only differ in the TTL are replaced.
+	ttl := testA("a.example", 192, 0, 2, 1)
+	ttl.TTL = 5
+	tree, _ = tree.Insert(ttl)
+	if records, _ := tree.Lookup("a.example"); len(records) != 1 || records[0].TTL != 5 {
+		t.Fatalf("Unexpected records %v", records)
+	}
+
+	deleted := tree.Delete(extra, testA("z.example", 192, 0, 2, 5), testA("missing.example", 192, 0, 2, 1))
+	if deleted.Len() != tree.Len()-2 {
+		t.Fatalf("Expected two deleted records, got %d", tree.Len()-deleted.Len())
+	}
+	if _, ok := deleted.Lookup("b.example"); ok {
+		t.Fatal("Empty non-terminals without children should be removed.")
+	}
+	if records, ok := deleted.Lookup("z.example"); !ok || len(records) != 0 {
+		t.Fatal("z.example should be left as empty non-terminal.")
+	}
+	if _, ok := tree.Lookup("b.b.example"); !ok {
+		t.Fatal("Delete must not change the old tree.")
+	}
+
+	if _, err := tree.Insert(testA("example.com", 192, 0, 2, 1)); err != ErrNotInZone {
+		t.Fatalf("Expected ErrNotInZone, got %v", err)
+	}
+}
+
+func TestZoneConcurrentReaders(t *testing.T) {
+	z := newTestZone(t)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				resp := zoneQuery(t, z, "www.example.com", TypeA)
+				if len(resp.Answer) == 0 {
+					t.Error("Missing answer")
+					return
+				}
+			}
+		}()
+	}
+	for i := range 200 {
+		if err := z.Add(testA("www.example.com", 198, 51, 100, byte(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if records, _ := z.Snapshot().Lookup("www.example.com"); len(records) != 201 {
+		t.Fatalf("Expected 201 records, got %d", len(records))
+	}
+}